@@ -16,20 +16,87 @@ type appConfig struct {
 }
 
 type Environment struct {
-	AppName        string        `envconfig:"APP_NAME"        default:"auth"`
-	Host           string        `envconfig:"HOST"            required:"true"`
-	SQLDSN         string        `envconfig:"SQLDSN"          required:"true"`
-	MigrationsPath string        `envconfig:"MIGRATIONS_PATH" default:"internal/pkg/migrations"`
-	RedisHost      string        `envconfig:"REDIS_HOST"      required:"true"`
-	RedisPassword  string        `envconfig:"REDIS_PASSWORD"`
-	JwtSecret      string        `envconfig:"JWT_SECRET"      required:"true"`
-	ConnectTimeout time.Duration `envconfig:"CONNECT_TIMEOUT" default:"5s"`
-	ReadTimeout    time.Duration `envconfig:"READ_TIMEOUT"    default:"2s"`
-	AccessTTL      time.Duration `envconfig:"ACCESS_TTL"      default:"6h"`
-	RefreshTTL     time.Duration `envconfig:"REFRESH_TTL"     default:"24h"`
-	MetricsHost    string        `envconfig:"METRICS_HOST"    default:"localhost:8080"`
-	LogType        log.Type      `envconfig:"LOG_TYPE"        default:"console"`
-	LogLevel       log.Level     `envconfig:"LOG_LEVEL"       default:"info"`
+	AppName                string            `envconfig:"APP_NAME"                 default:"auth"`
+	Host                   string            `envconfig:"HOST"                     required:"true"`
+	SQLDSN                 string            `envconfig:"SQLDSN"                   required:"true"`
+	MigrationsPath         string            `envconfig:"MIGRATIONS_PATH"          default:"internal/pkg/migrations"`
+	RedisHost              string            `envconfig:"REDIS_HOST"               required:"true"`
+	RedisPassword          string            `envconfig:"REDIS_PASSWORD"`
+	JwtSecret              string            `envconfig:"JWT_SECRET"               required:"true"`
+	JwtKeyID               string            `envconfig:"JWT_KEY_ID"               default:"default"`
+	JwtKeyFile             string            `envconfig:"JWT_KEY_FILE"`
+	JwtRetiredKeys         map[string]string `envconfig:"JWT_RETIRED_KEYS"`
+	JwtIssuer              string            `envconfig:"JWT_ISSUER"               default:"http://localhost:8081"`
+	JwtAudience            []string          `envconfig:"JWT_AUDIENCE"`
+	TokenHashKey           string            `envconfig:"TOKEN_HASH_KEY"`
+	TokenFormat            string            `envconfig:"TOKEN_FORMAT"             default:"jwt"`
+	PasswordHashAlgorithm  string            `envconfig:"PASSWORD_HASH_ALGORITHM"  default:"bcrypt"`
+	PasswordBcryptCost     int               `envconfig:"PASSWORD_BCRYPT_COST"     default:"12"`
+	PasswordArgon2Memory   uint32            `envconfig:"PASSWORD_ARGON2_MEMORY"   default:"19456"`
+	PasswordArgon2Time     uint32            `envconfig:"PASSWORD_ARGON2_TIME"     default:"2"`
+	PasswordArgon2Threads  uint8             `envconfig:"PASSWORD_ARGON2_THREADS"  default:"1"`
+	PasswordMinLength      int               `envconfig:"PASSWORD_MIN_LENGTH"      default:"8"`
+	PasswordMaxLength      int               `envconfig:"PASSWORD_MAX_LENGTH"      default:"64"`
+	PasswordRequireLower   bool              `envconfig:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireUpper   bool              `envconfig:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireDigit   bool              `envconfig:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol  bool              `envconfig:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordForbidLogin    bool              `envconfig:"PASSWORD_FORBID_LOGIN"    default:"true"`
+	PasswordForbidCommon   bool              `envconfig:"PASSWORD_FORBID_COMMON"   default:"true"`
+	PasswordHistorySize    int               `envconfig:"PASSWORD_HISTORY_SIZE"    default:"5"`
+	PasswordRevokeSessions bool              `envconfig:"PASSWORD_REVOKE_SESSIONS" default:"true"`
+	LoginGenericErrors     bool              `envconfig:"LOGIN_GENERIC_ERRORS"     default:"true"`
+	MFAEncryptionKey       string            `envconfig:"MFA_ENCRYPTION_KEY"`
+	MFAChallengeTTL        time.Duration     `envconfig:"MFA_CHALLENGE_TTL"        default:"5m"`
+	RecoveryCodesCount     int               `envconfig:"RECOVERY_CODES_COUNT"     default:"10"`
+	TOTPIssuer             string            `envconfig:"TOTP_ISSUER"              default:"auth"`
+	WebAuthnRPID           string            `envconfig:"WEBAUTHN_RP_ID"`
+	WebAuthnRPName         string            `envconfig:"WEBAUTHN_RP_NAME"         default:"auth"`
+	WebAuthnOrigins        []string          `envconfig:"WEBAUTHN_ORIGINS"`
+	WebAuthnUserVerify     bool              `envconfig:"WEBAUTHN_USER_VERIFY"     default:"true"`
+	PasswordResetTTL       time.Duration     `envconfig:"PASSWORD_RESET_TTL"       default:"1h"`
+	EmailVerificationTTL   time.Duration     `envconfig:"EMAIL_VERIFICATION_TTL"   default:"24h"`
+	ServiceTokenTTL        time.Duration     `envconfig:"SERVICE_TOKEN_TTL"        default:"15m"`
+	NotifierType           string            `envconfig:"NOTIFIER_TYPE"`
+	NotifierFile           string            `envconfig:"NOTIFIER_FILE"            default:"notifications.log"`
+	SMTPHost               string            `envconfig:"SMTP_HOST"`
+	SMTPUsername           string            `envconfig:"SMTP_USERNAME"`
+	SMTPPassword           string            `envconfig:"SMTP_PASSWORD"`
+	SMTPFrom               string            `envconfig:"SMTP_FROM"`
+	SecurityNotifications  bool              `envconfig:"SECURITY_NOTIFICATIONS"   default:"true"`
+	OutboxInterval         time.Duration     `envconfig:"OUTBOX_INTERVAL"          default:"5s"`
+	OutboxBatchSize        int32             `envconfig:"OUTBOX_BATCH_SIZE"        default:"100"`
+	OutboxMaxAttempts      int               `envconfig:"OUTBOX_MAX_ATTEMPTS"      default:"10"`
+	OutboxRetryDelay       time.Duration     `envconfig:"OUTBOX_RETRY_DELAY"       default:"30s"`
+	OutboxMaxRetryDelay    time.Duration     `envconfig:"OUTBOX_MAX_RETRY_DELAY"   default:"1h"`
+	OutboxLease            time.Duration     `envconfig:"OUTBOX_LEASE"             default:"5m"`
+	AdminRole              string            `envconfig:"ADMIN_ROLE"               default:"admin"`
+	AdminAPIKeys           []string          `envconfig:"ADMIN_API_KEYS"`
+	AdminClientNames       []string          `envconfig:"ADMIN_CLIENT_NAMES"`
+	ThrottleLoginThreshold int64             `envconfig:"THROTTLE_LOGIN_THRESHOLD" default:"5"`
+	ThrottleIPThreshold    int64             `envconfig:"THROTTLE_IP_THRESHOLD"    default:"20"`
+	ThrottleBaseDelay      time.Duration     `envconfig:"THROTTLE_BASE_DELAY"      default:"1s"`
+	ThrottleMaxDelay       time.Duration     `envconfig:"THROTTLE_MAX_DELAY"       default:"30s"`
+	ThrottleLockout        time.Duration     `envconfig:"THROTTLE_LOCKOUT"         default:"15m"`
+	ThrottleWindow         time.Duration     `envconfig:"THROTTLE_WINDOW"          default:"15m"`
+	TrustedProxies         []string          `envconfig:"TRUSTED_PROXIES"`
+	ConnectTimeout         time.Duration     `envconfig:"CONNECT_TIMEOUT"          default:"5s"`
+	ReadTimeout            time.Duration     `envconfig:"READ_TIMEOUT"             default:"2s"`
+	AccessTTL              time.Duration     `envconfig:"ACCESS_TTL"               default:"6h"`
+	RefreshTTL             time.Duration     `envconfig:"REFRESH_TTL"              default:"24h"`
+	TLSCertFile            string            `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile             string            `envconfig:"TLS_KEY_FILE"`
+	TLSClientCAFile        string            `envconfig:"TLS_CLIENT_CA_FILE"`
+	TLSReloadInterval      time.Duration     `envconfig:"TLS_RELOAD_INTERVAL"      default:"1m"`
+	AdminHost              string            `envconfig:"ADMIN_HOST"`
+	AdminTLSCertFile       string            `envconfig:"ADMIN_TLS_CERT_FILE"`
+	AdminTLSKeyFile        string            `envconfig:"ADMIN_TLS_KEY_FILE"`
+	AdminTLSClientCAFile   string            `envconfig:"ADMIN_TLS_CLIENT_CA_FILE"`
+	MetricsHost            string            `envconfig:"METRICS_HOST"             default:"localhost:8080"`
+	DiscoveryHost          string            `envconfig:"DISCOVERY_HOST"           default:"localhost:8081"`
+	OAuthHost              string            `envconfig:"OAUTH_HOST"`
+	LogType                log.Type          `envconfig:"LOG_TYPE"                 default:"console"`
+	LogLevel               log.Level         `envconfig:"LOG_LEVEL"                default:"info"`
 }
 
 func Load() error {
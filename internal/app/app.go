@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/rs/zerolog"
@@ -10,15 +12,22 @@ import (
 	"github.com/sanches1984/msa-auth/config"
 	"github.com/sanches1984/msa-auth/internal/app/resources"
 	"github.com/sanches1984/msa-auth/internal/app/service"
+	"github.com/sanches1984/msa-auth/internal/pkg/certs"
+	"github.com/sanches1984/msa-auth/internal/pkg/discovery"
 	"github.com/sanches1984/msa-auth/internal/pkg/metrics"
+	"github.com/sanches1984/msa-auth/internal/pkg/oauth"
+	"github.com/sanches1984/msa-auth/internal/pkg/outbox"
 	"github.com/sanches1984/msa-auth/internal/pkg/repository"
 	"github.com/sanches1984/msa-auth/internal/pkg/storage"
-	"github.com/sanches1984/msa-auth/pkg/jwt"
 	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
 	api "github.com/sanches1984/msa-auth/proto/api"
+	"golang.org/x/crypto/hkdf"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"io"
 	"net"
 	"os"
 	"os/signal"
@@ -29,14 +38,26 @@ import (
 const gracefulTimeout = 2 * time.Second
 const logDBLongQueryDuration = 1 * time.Second
 
+const (
+	derivedKeySize       = 32
+	tokenHashKeyInfo     = "msa-auth token hash"
+	mfaEncryptionKeyInfo = "msa-auth mfa encryption"
+)
+
 type App struct {
-	grpc    *grpc.Server
-	db      database.IClient
-	redis   *redis.Client
-	repo    *repository.Repository
-	storage *storage.Storage
-	metrics *metrics.Service
-	logger  zerolog.Logger
+	grpc      *grpc.Server
+	admin     *grpc.Server
+	db        database.IClient
+	redis     *redis.Client
+	repo      *repository.Repository
+	storage   *storage.Storage
+	metrics   *metrics.Service
+	discovery *discovery.Service
+	oauth     *oauth.Service
+	outbox    *outbox.Dispatcher
+	tls       *certs.Reloader
+	adminTLS  *certs.Reloader
+	logger    zerolog.Logger
 }
 
 func New(logger zerolog.Logger) (*App, error) {
@@ -54,25 +75,120 @@ func New(logger zerolog.Logger) (*App, error) {
 		return app, fmt.Errorf("redis init error: %w", err)
 	}
 
-	jwtService := jwt.NewService(config.Env().AccessTTL, config.Env().RefreshTTL, config.Env().JwtSecret)
+	jwtService, err := resources.InitJwt()
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("jwt init error: %w", err)
+	}
+
+	hasher, err := resources.InitPasswordHasher()
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("password hasher init error: %w", err)
+	}
+
+	secretBox, err := secretbox.New(mfaEncryptionKey(logger))
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("secret box init error: %w", err)
+	}
+
+	webAuthn, err := resources.InitWebAuthn()
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("webauthn init error: %w", err)
+	}
+
+	notifier, err := resources.InitNotifier(logger)
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("notifier init error: %w", err)
+	}
+
+	app.tls, err = resources.InitTLS(logger)
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("tls init error: %w", err)
+	}
+
+	trustedProxies, err := resources.InitTrustedProxies()
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("trusted proxies init error: %w", err)
+	}
+
+	storageOpts, err := storageOptions(logger)
+	if err != nil {
+		app.db.Close()
+		app.redis.Close()
+		return app, fmt.Errorf("storage init error: %w", err)
+	}
+
 	app.repo = repository.New()
-	app.storage = storage.New(app.redis, jwtService)
+	app.storage = storage.New(app.redis, jwtService, storageOpts...)
 	app.metrics = metrics.NewService(config.Env().MetricsHost)
+	app.discovery = discovery.NewService(config.Env().DiscoveryHost, config.Env().JwtIssuer, jwtService)
+	securityNotifications := notifier != nil && config.Env().SecurityNotifications
+	if notifier != nil {
+		app.outbox = resources.InitDispatcher(app.repo, notifier, secretBox, app.db, logger)
+	}
 
-	app.grpc = grpc.NewServer(
-		grpc.UnaryInterceptor(
-			grpcmw.ChainUnaryServer(
-				dbmw.NewDBServerInterceptor(app.db, database.WithLogger(logger, logDBLongQueryDuration)),
-				app.metrics.AppMetricsInterceptor(),
-				app.metrics.GRPCMetricsInterceptor(),
-				log.NewLogServerInterceptor(logger),
-			),
-		),
-	)
-
+	grpcOpts := []grpc.ServerOption{app.unaryInterceptor()}
+	if app.tls != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(app.tls.TLSConfig())))
+	}
+	app.grpc = grpc.NewServer(grpcOpts...)
 	grpc_health_v1.RegisterHealthServer(app.grpc, health.NewServer())
-	api.RegisterAuthServiceServer(app.grpc, service.NewAuthService(app.repo, app.storage, app.logger))
-	api.RegisterManageServiceServer(app.grpc, service.NewManageService(app.repo, app.storage, app.logger))
+	serviceOpts := []service.Option{
+		service.WithPasswordHasher(hasher),
+		service.WithPasswordPolicy(resources.InitPasswordPolicy()),
+		service.WithPasswordHistory(config.Env().PasswordHistorySize),
+		service.WithRevokeOtherSessions(config.Env().PasswordRevokeSessions),
+		service.WithThrottler(resources.InitThrottler(app.redis)),
+		service.WithTrustedProxies(trustedProxies),
+		service.WithGenericLoginErrors(config.Env().LoginGenericErrors),
+		service.WithTOTP(config.Env().TOTPIssuer, secretBox),
+		service.WithRecoveryCodes(config.Env().RecoveryCodesCount),
+		service.WithWebAuthn(webAuthn),
+		service.WithNotifier(notifier, secretBox),
+		service.WithPasswordResetTTL(config.Env().PasswordResetTTL),
+		service.WithEmailVerificationTTL(config.Env().EmailVerificationTTL),
+		service.WithServiceTokenTTL(config.Env().ServiceTokenTTL),
+		service.WithSecurityNotifications(securityNotifications),
+	}
+	authService := service.NewAuthService(app.repo, app.storage, app.logger, serviceOpts...)
+	api.RegisterAuthServiceServer(app.grpc, authService)
+	app.oauth = resources.InitOAuth(authService, app.db, app.tls, logger)
+	manageService := service.NewManageService(app.repo, app.storage, app.logger, serviceOpts...)
+	if len(config.Env().AdminAPIKeys) == 0 {
+		logger.Warn().Msg("admin api keys aren't set, ManageService accepts only admin tokens and client certificates")
+	}
+	if config.Env().AdminHost == "" {
+		api.RegisterManageServiceServer(app.grpc, manageService)
+	} else {
+		app.adminTLS, err = resources.InitAdminTLS(logger)
+		if err != nil {
+			app.db.Close()
+			app.redis.Close()
+			return app, fmt.Errorf("admin tls init error: %w", err)
+		}
+		adminOpts := []grpc.ServerOption{app.adminUnaryInterceptor()}
+		if app.adminTLS != nil {
+			adminOpts = append(adminOpts, grpc.Creds(credentials.NewTLS(app.adminTLS.TLSConfig())))
+		}
+		// management is never exposed on the public port when admin host is set
+		app.admin = grpc.NewServer(adminOpts...)
+		grpc_health_v1.RegisterHealthServer(app.admin, health.NewServer())
+		api.RegisterManageServiceServer(app.admin, manageService)
+		app.metrics.Initialize(app.admin)
+	}
 	app.metrics.Initialize(app.grpc)
 
 	return app, nil
@@ -85,16 +201,34 @@ func (a *App) Serve() error {
 	if err != nil {
 		return err
 	}
+	var adminConn net.Listener
+	if a.admin != nil {
+		if adminConn, err = net.Listen("tcp", config.Env().AdminHost); err != nil {
+			conn.Close()
+			return err
+		}
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigCh
 		a.logger.Warn().Msg("termination signal received")
+		a.stopAdmin()
 		a.logger.Info().Msg("stop grpc server")
 		a.grpc.GracefulStop()
 	}()
 
+	if a.admin != nil {
+		go func() {
+			a.logger.Info().Str("host", config.Env().AdminHost).Msg("start admin grpc server")
+			if err := a.admin.Serve(adminConn); err != nil {
+				a.logger.Error().Err(err).Msg("admin grpc server failed")
+				a.grpc.GracefulStop()
+			}
+		}()
+	}
+
 	go func() {
 		a.logger.Info().Str("host", config.Env().MetricsHost).Msg("start metrics server")
 		if err := a.metrics.Listen(); err != nil {
@@ -102,13 +236,53 @@ func (a *App) Serve() error {
 		}
 	}()
 
+	go func() {
+		a.logger.Info().Str("host", config.Env().DiscoveryHost).Msg("start discovery server")
+		if err := a.discovery.Listen(); err != nil {
+			a.logger.Error().Err(err).Msg("discovery failed")
+		}
+	}()
+
+	if a.oauth != nil {
+		go func() {
+			a.logger.Info().Str("host", config.Env().OAuthHost).Msg("start oauth server")
+			if err := a.oauth.Listen(); err != nil {
+				a.logger.Error().Err(err).Msg("oauth failed")
+			}
+		}()
+	}
+
+	if a.outbox != nil {
+		go func() {
+			a.logger.Info().Msg("start outbox dispatcher")
+			a.outbox.Run()
+		}()
+	}
+
+	for _, reloader := range []*certs.Reloader{a.tls, a.adminTLS} {
+		if reloader != nil {
+			go reloader.Run()
+		}
+	}
+
 	a.logger.Info().Str("host", config.Env().Host).Msg("start grpc server")
 	return a.grpc.Serve(conn)
 }
 
 func (a *App) stop() {
+	// admin server is stopped here too when the public one fails
+	a.stopAdmin()
 	time.Sleep(gracefulTimeout)
 
+	if a.outbox != nil {
+		a.logger.Info().Msg("stop outbox dispatcher")
+		a.outbox.Close()
+	}
+	for _, reloader := range []*certs.Reloader{a.tls, a.adminTLS} {
+		if reloader != nil {
+			reloader.Close()
+		}
+	}
 	if a.db != nil {
 		a.logger.Info().Msg("disconnect database")
 		a.db.Close()
@@ -121,4 +295,99 @@ func (a *App) stop() {
 		a.logger.Info().Msg("stop metrics server")
 		a.metrics.Close()
 	}
+	if a.discovery != nil {
+		a.logger.Info().Msg("stop discovery server")
+		a.discovery.Close()
+	}
+	if a.oauth != nil {
+		a.logger.Info().Msg("stop oauth server")
+		a.oauth.Close()
+	}
+}
+
+func (a *App) stopAdmin() {
+	if a.admin != nil {
+		a.logger.Info().Msg("stop admin grpc server")
+		a.admin.GracefulStop()
+	}
+}
+
+// unaryInterceptor is chain of the public server. Authorization is added only if management
+// is served by it too, i.e. admin host isn't set.
+func (a *App) unaryInterceptor() grpc.ServerOption {
+	interceptors := []grpc.UnaryServerInterceptor{
+		dbmw.NewDBServerInterceptor(a.db, database.WithLogger(a.logger, logDBLongQueryDuration)),
+		a.metrics.AppMetricsInterceptor(),
+		a.metrics.GRPCMetricsInterceptor(),
+		a.logInterceptor(),
+	}
+	if config.Env().AdminHost == "" {
+		interceptors = append(interceptors, resources.InitAuthorizer(a.storage, a.repo, a.logger).UnaryServerInterceptor())
+	}
+	return grpc.UnaryInterceptor(grpcmw.ChainUnaryServer(interceptors...))
+}
+
+// adminUnaryInterceptor is chain of the admin server, authorization goes last to have logger of the request.
+func (a *App) adminUnaryInterceptor() grpc.ServerOption {
+	return grpc.UnaryInterceptor(
+		grpcmw.ChainUnaryServer(
+			dbmw.NewDBServerInterceptor(a.db, database.WithLogger(a.logger, logDBLongQueryDuration)),
+			a.metrics.GRPCMetricsInterceptor(),
+			a.logInterceptor(),
+			resources.InitAuthorizer(a.storage, a.repo, a.logger).UnaryServerInterceptor(),
+		),
+	)
+}
+
+// logInterceptor logs requests with subject of verified client certificate if there is one.
+func (a *App) logInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		logger := a.logger
+		if subject := certs.ClientSubject(ctx); subject != "" {
+			logger = logger.With().Str("client", subject).Logger()
+		}
+		return log.NewLogServerInterceptor(logger)(ctx, req, info, handler)
+	}
+}
+
+// tokenHashKey returns key of token hashes, without dedicated one it's derived from jwt secret.
+func tokenHashKey(logger zerolog.Logger) []byte {
+	if config.Env().TokenHashKey != "" {
+		return []byte(config.Env().TokenHashKey)
+	}
+	logger.Warn().Msg("token hash key is derived from jwt secret, set AUTH_TOKEN_HASH_KEY")
+	return deriveKey(tokenHashKeyInfo)
+}
+
+// mfaEncryptionKey returns key of totp secrets and tokens queued in the outbox,
+// without dedicated one it's derived from jwt secret.
+func mfaEncryptionKey(logger zerolog.Logger) []byte {
+	if config.Env().MFAEncryptionKey != "" {
+		return []byte(config.Env().MFAEncryptionKey)
+	}
+	logger.Warn().Msg("mfa encryption key is derived from jwt secret, set AUTH_MFA_ENCRYPTION_KEY")
+	return deriveKey(mfaEncryptionKeyInfo)
+}
+
+// deriveKey derives key of the given purpose from jwt secret by HKDF, so the secret isn't reused as is.
+func deriveKey(info string) []byte {
+	key := make([]byte, derivedKeySize)
+	// reading 32 bytes from HKDF-SHA256 never fails
+	_, _ = io.ReadFull(hkdf.New(sha256.New, []byte(config.Env().JwtSecret), nil, []byte(info)), key)
+	return key
+}
+
+func storageOptions(logger zerolog.Logger) ([]storage.Option, error) {
+	opts := []storage.Option{
+		storage.WithTokenHashKey(tokenHashKey(logger)),
+		storage.WithChallengeTTL(config.Env().MFAChallengeTTL),
+	}
+	switch storage.TokenFormat(config.Env().TokenFormat) {
+	case storage.TokenFormatJWT:
+	case storage.TokenFormatOpaque:
+		opts = append(opts, storage.WithOpaqueAccessTokens(config.Env().AccessTTL))
+	default:
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnknownTokenFormat, config.Env().TokenFormat)
+	}
+	return opts, nil
 }
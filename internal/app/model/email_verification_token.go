@@ -0,0 +1,26 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// EmailVerificationToken proves that the user owns Email, only its hash is kept.
+type EmailVerificationToken struct {
+	tableName struct{}  `pg:"email_verification_tokens"`
+	ID        int64     `pg:"id,pk"`
+	UserID    int64     `pg:"user_id,notnull"`
+	Email     string    `pg:"email,notnull"`
+	TokenHash string    `pg:"token_hash,notnull"`
+	ExpiresIn int32     `pg:"expires_in,notnull"`
+	Created   time.Time `pg:"created,notnull"`
+}
+
+func (t *EmailVerificationToken) BeforeInsert(ctx context.Context) (context.Context, error) {
+	t.Created = time.Now()
+	return ctx, nil
+}
+
+func (t EmailVerificationToken) IsExpired() bool {
+	return int32(time.Now().Unix()) > t.ExpiresIn
+}
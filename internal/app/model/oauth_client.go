@@ -0,0 +1,47 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type OAuthClientList []*OAuthClient
+
+// OAuthClient is confidential client of oauth token endpoint, only hash of its secret is kept.
+// Tokens of client_credentials grant are issued to ServiceAccountID.
+type OAuthClient struct {
+	tableName        struct{}  `pg:"oauth_clients"`
+	ID               int64     `pg:"id,pk"`
+	ClientID         string    `pg:"client_id,notnull"`
+	Name             string    `pg:"name,notnull"`
+	SecretHash       string    `pg:"secret_hash,notnull"`
+	GrantTypes       []string  `pg:"grant_types,array"`
+	ServiceAccountID int64     `pg:"service_account_id"`
+	Created          time.Time `pg:"created,notnull"`
+	Updated          time.Time `pg:"updated,notnull"`
+}
+
+type OAuthClientFilter struct {
+	ClientID         string
+	ServiceAccountID int64
+}
+
+func (c *OAuthClient) BeforeInsert(ctx context.Context) (context.Context, error) {
+	c.Created = time.Now()
+	c.Updated = time.Now()
+	return ctx, nil
+}
+
+func (c *OAuthClient) BeforeUpdate(ctx context.Context) (context.Context, error) {
+	c.Updated = time.Now()
+	return ctx, nil
+}
+
+func (c OAuthClient) AllowsGrant(grantType string) bool {
+	for _, t := range c.GrantTypes {
+		if t == grantType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"context"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"time"
+)
+
+type OutboxMessageList []*OutboxMessage
+
+// OutboxMessage is notification of the user queued in transaction of the event, it's delivered by dispatcher.
+type OutboxMessage struct {
+	tableName   struct{}          `pg:"notification_outbox"`
+	ID          int64             `pg:"id,pk"`
+	UserID      int64             `pg:"user_id,notnull"`
+	Kind        notifier.Kind     `pg:"kind,notnull"`
+	Data        map[string]string `pg:"data"`
+	Attempts    int               `pg:"attempts,notnull,use_zero"`
+	NextAttempt time.Time         `pg:"next_attempt,notnull"`
+	Sent        *time.Time        `pg:"sent"`
+	Error       string            `pg:"error,notnull,use_zero"`
+	Created     time.Time         `pg:"created,notnull"`
+}
+
+func (m *OutboxMessage) BeforeInsert(ctx context.Context) (context.Context, error) {
+	m.Created = time.Now()
+	if m.NextAttempt.IsZero() {
+		m.NextAttempt = m.Created
+	}
+	return ctx, nil
+}
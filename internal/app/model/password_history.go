@@ -0,0 +1,30 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordHistory keeps previous password hashes of the user to prevent their reuse.
+type PasswordHistoryList []*PasswordHistory
+
+type PasswordHistory struct {
+	tableName    struct{}  `pg:"password_history"`
+	ID           int64     `pg:"id,pk"`
+	UserID       int64     `pg:"user_id,notnull"`
+	PasswordHash string    `pg:"password_hash,notnull"`
+	Created      time.Time `pg:"created,notnull"`
+	Updated      time.Time `pg:"updated,notnull"`
+}
+
+// PasswordHistoryFilter selects the latest Limit hashes of the user.
+type PasswordHistoryFilter struct {
+	UserID int64
+	Limit  int32
+}
+
+func (h *PasswordHistory) BeforeInsert(ctx context.Context) (context.Context, error) {
+	h.Created = time.Now()
+	h.Updated = time.Now()
+	return ctx, nil
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordResetToken is single-use token to set new password without the current one, only its hash is kept.
+type PasswordResetToken struct {
+	tableName struct{}  `pg:"password_reset_tokens"`
+	ID        int64     `pg:"id,pk"`
+	UserID    int64     `pg:"user_id,notnull"`
+	TokenHash string    `pg:"token_hash,notnull"`
+	ExpiresIn int32     `pg:"expires_in,notnull"`
+	Created   time.Time `pg:"created,notnull"`
+}
+
+func (t *PasswordResetToken) BeforeInsert(ctx context.Context) (context.Context, error) {
+	t.Created = time.Now()
+	return ctx, nil
+}
+
+func (t PasswordResetToken) IsExpired() bool {
+	return int32(time.Now().Unix()) > t.ExpiresIn
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// RecoveryCode is single-use code to log in without password, only its hash is kept.
+type RecoveryCodeList []*RecoveryCode
+
+type RecoveryCode struct {
+	tableName struct{}  `pg:"recovery_codes"`
+	ID        int64     `pg:"id,pk"`
+	UserID    int64     `pg:"user_id,notnull"`
+	CodeHash  string    `pg:"code_hash,notnull"`
+	Created   time.Time `pg:"created,notnull"`
+}
+
+func (c *RecoveryCode) BeforeInsert(ctx context.Context) (context.Context, error) {
+	c.Created = time.Now()
+	return ctx, nil
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Role is named set of permissions assigned to users, Permissions aren't stored in the table itself.
+type RoleList []*Role
+
+type Role struct {
+	tableName   struct{}       `pg:"roles"`
+	ID          int64          `pg:"id,pk"`
+	Name        string         `pg:"name,notnull"`
+	Description string         `pg:"description,notnull,use_zero"`
+	Permissions PermissionList `pg:"-"`
+	Created     time.Time      `pg:"created,notnull"`
+	Updated     time.Time      `pg:"updated,notnull"`
+}
+
+// RoleFilter selects roles by name or roles assigned to the user.
+type RoleFilter struct {
+	Names  []string
+	UserID int64
+}
+
+type PermissionList []*Permission
+
+type Permission struct {
+	tableName   struct{}  `pg:"permissions"`
+	ID          int64     `pg:"id,pk"`
+	Name        string    `pg:"name,notnull"`
+	Description string    `pg:"description,notnull,use_zero"`
+	Created     time.Time `pg:"created,notnull"`
+}
+
+type PermissionFilter struct {
+	IDs   []int64
+	Names []string
+}
+
+type RolePermission struct {
+	tableName    struct{} `pg:"role_permissions"`
+	RoleID       int64    `pg:"role_id,pk"`
+	PermissionID int64    `pg:"permission_id,pk"`
+}
+
+type UserRole struct {
+	tableName struct{}  `pg:"user_roles"`
+	UserID    int64     `pg:"user_id,pk"`
+	RoleID    int64     `pg:"role_id,pk"`
+	Created   time.Time `pg:"created,notnull"`
+}
+
+func (r *Role) BeforeInsert(ctx context.Context) (context.Context, error) {
+	r.Created = time.Now()
+	r.Updated = time.Now()
+	return ctx, nil
+}
+
+func (r *Role) BeforeUpdate(ctx context.Context) (context.Context, error) {
+	r.Updated = time.Now()
+	return ctx, nil
+}
+
+func (p *Permission) BeforeInsert(ctx context.Context) (context.Context, error) {
+	p.Created = time.Now()
+	return ctx, nil
+}
+
+func (r *UserRole) BeforeInsert(ctx context.Context) (context.Context, error) {
+	r.Created = time.Now()
+	return ctx, nil
+}
+
+func (l PermissionList) Names() []string {
+	names := make([]string, 0, len(l))
+	for _, p := range l {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Grants returns sorted names of the roles and of their permissions without duplicates.
+func (l RoleList) Grants() (roles []string, permissions []string) {
+	seen := make(map[string]bool)
+	for _, r := range l {
+		roles = append(roles, r.Name)
+		for _, p := range r.Permissions {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				permissions = append(permissions, p.Name)
+			}
+		}
+	}
+	sort.Strings(roles)
+	sort.Strings(permissions)
+	return roles, permissions
+}
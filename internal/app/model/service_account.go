@@ -0,0 +1,64 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// ServiceAccount is principal of backend jobs and services, it has no password and authenticates by api keys.
+type ServiceAccountList []*ServiceAccount
+
+type ServiceAccount struct {
+	tableName   struct{}  `pg:"service_accounts"`
+	ID          int64     `pg:"id,pk"`
+	Name        string    `pg:"name,notnull"`
+	Description string    `pg:"description,notnull,use_zero"`
+	Created     time.Time `pg:"created,notnull"`
+	Updated     time.Time `pg:"updated,notnull"`
+}
+
+type ServiceAccountFilter struct {
+	ID   int64
+	Name string
+}
+
+// APIKey is long-lived key of service account, only its hash is kept. Prefix is public part of the key
+// to find it without hash.
+type APIKeyList []*APIKey
+
+type APIKey struct {
+	tableName        struct{}   `pg:"api_keys"`
+	ID               int64      `pg:"id,pk"`
+	ServiceAccountID int64      `pg:"service_account_id,notnull"`
+	Prefix           string     `pg:"prefix,notnull"`
+	KeyHash          string     `pg:"key_hash,notnull"`
+	Created          time.Time  `pg:"created,notnull"`
+	LastUsed         *time.Time `pg:"last_used"`
+	Revoked          *time.Time `pg:"revoked"`
+}
+
+type APIKeyFilter struct {
+	ID               int64
+	ServiceAccountID int64
+	Prefix           string
+}
+
+func (a *ServiceAccount) BeforeInsert(ctx context.Context) (context.Context, error) {
+	a.Created = time.Now()
+	a.Updated = time.Now()
+	return ctx, nil
+}
+
+func (a *ServiceAccount) BeforeUpdate(ctx context.Context) (context.Context, error) {
+	a.Updated = time.Now()
+	return ctx, nil
+}
+
+func (k *APIKey) BeforeInsert(ctx context.Context) (context.Context, error) {
+	k.Created = time.Now()
+	return ctx, nil
+}
+
+func (k APIKey) IsRevoked() bool {
+	return k.Revoked != nil
+}
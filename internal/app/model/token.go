@@ -8,13 +8,16 @@ import (
 
 type RefreshTokenList []*RefreshToken
 
+// RefreshToken is kept as hash, ClientID is oauth client the token is issued to by token endpoint,
+// it's empty for tokens of grpc methods.
 type RefreshToken struct {
 	tableName struct{}  `pg:"refresh_tokens"`
 	ID        int64     `pg:"id,pk"`
 	UserID    int64     `pg:"user_id,notnull"`
 	SessionID uuid.UUID `pg:"session_id,notnull"`
-	Token     string    `pg:"token,notnull"`
+	TokenHash string    `pg:"token_hash,notnull"`
 	ExpiresIn int32     `pg:"expires_in,notnull"`
+	ClientID  string    `pg:"client_id,notnull,use_zero"`
 	Created   time.Time `pg:"created,notnull"`
 	Updated   time.Time `pg:"updated,notnull"`
 }
@@ -46,3 +49,14 @@ func (tl RefreshTokenList) Sessions() []string {
 	}
 	return sessions
 }
+
+// Except returns tokens of all sessions but the given one.
+func (tl RefreshTokenList) Except(sessionID uuid.UUID) RefreshTokenList {
+	tokens := make(RefreshTokenList, 0, len(tl))
+	for _, t := range tl {
+		if t.SessionID != sessionID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
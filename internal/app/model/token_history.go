@@ -0,0 +1,31 @@
+package model
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+// RefreshTokenHistory keeps refresh tokens superseded by rotation to detect their reuse.
+type RefreshTokenHistoryList []*RefreshTokenHistory
+
+type RefreshTokenHistory struct {
+	tableName struct{}  `pg:"refresh_token_history"`
+	ID        int64     `pg:"id,pk"`
+	UserID    int64     `pg:"user_id,notnull"`
+	SessionID uuid.UUID `pg:"session_id,notnull"`
+	TokenHash string    `pg:"token_hash,notnull"`
+	Created   time.Time `pg:"created,notnull"`
+	Updated   time.Time `pg:"updated,notnull"`
+}
+
+type RefreshTokenHistoryFilter struct {
+	UserID    int64
+	SessionID uuid.UUID
+}
+
+func (h *RefreshTokenHistory) BeforeInsert(ctx context.Context) (context.Context, error) {
+	h.Created = time.Now()
+	h.Updated = time.Now()
+	return ctx, nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// UserTOTP is totp second factor of the user, secret is encrypted. It's pending until enabled by the first code.
+type UserTOTP struct {
+	tableName    struct{}  `pg:"user_totp"`
+	ID           int64     `pg:"id,pk"`
+	UserID       int64     `pg:"user_id,notnull"`
+	Secret       string    `pg:"secret,notnull"`
+	Enabled      bool      `pg:"enabled,notnull,use_zero"`
+	LastUsedStep int64     `pg:"last_used_step,notnull,use_zero"`
+	Created      time.Time `pg:"created,notnull"`
+	Updated      time.Time `pg:"updated,notnull"`
+}
+
+func (t *UserTOTP) BeforeInsert(ctx context.Context) (context.Context, error) {
+	t.Created = time.Now()
+	t.Updated = time.Now()
+	return ctx, nil
+}
+
+func (t *UserTOTP) BeforeUpdate(ctx context.Context) (context.Context, error) {
+	t.Updated = time.Now()
+	return ctx, nil
+}
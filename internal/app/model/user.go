@@ -3,12 +3,13 @@ package model
 import (
 	"context"
 	"github.com/sanches1984/gopkg-pg-orm/repository/opt"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sanches1984/msa-auth/pkg/password"
 	"time"
 )
 
 type UserList []*User
 type UserOrder int
+type EmailStatus int
 
 const (
 	UserOrderCreatedAsc UserOrder = iota
@@ -17,19 +18,31 @@ const (
 	UserOrderLoginDesc
 )
 
+const (
+	EmailStatusAny EmailStatus = iota
+	EmailStatusVerified
+	EmailStatusUnverified
+)
+
+// User has optional email, it's stored in lower case and may be used as login once verified.
 type User struct {
-	tableName    struct{}   `pg:"users"`
-	ID           int64      `pg:"id,pk"`
-	Login        string     `pg:"login,notnull"`
-	PasswordHash string     `pg:"password_hash,notnull"`
-	Created      time.Time  `pg:"created,notnull"`
-	Updated      time.Time  `pg:"updated,notnull"`
-	Deleted      *time.Time `pg:"deleted"`
+	tableName       struct{}   `pg:"users"`
+	ID              int64      `pg:"id,pk"`
+	Login           string     `pg:"login,notnull"`
+	PasswordHash    string     `pg:"password_hash,notnull"`
+	Email           string     `pg:"email"`
+	EmailVerified   bool       `pg:"email_verified,notnull,use_zero"`
+	EmailVerifiedAt *time.Time `pg:"email_verified_at"`
+	Created         time.Time  `pg:"created,notnull"`
+	Updated         time.Time  `pg:"updated,notnull"`
+	Deleted         *time.Time `pg:"deleted"`
 }
 
 type UserFilter struct {
 	ID          int64
 	Login       string
+	Email       string
+	EmailStatus EmailStatus
 	Order       UserOrder
 	ShowDeleted bool
 }
@@ -50,17 +63,39 @@ func (u *User) SetDeleted(t time.Time) {
 	u.Deleted = &now
 }
 
-func (u *User) SetHashByPassword(password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+// SetEmail replaces email of the user, new email isn't verified.
+func (u *User) SetEmail(email string) {
+	if u.Email == email {
+		return
+	}
+	u.Email = email
+	u.EmailVerified = false
+	u.EmailVerifiedAt = nil
+}
+
+func (u *User) VerifyEmail(email string) {
+	now := time.Now()
+	u.Email = email
+	u.EmailVerified = true
+	u.EmailVerifiedAt = &now
+}
+
+func (u *User) SetHashByPassword(hasher *password.Hasher, pwd string) error {
+	hash, err := hasher.Hash(pwd)
 	if err != nil {
 		return err
 	}
-	u.PasswordHash = string(hash)
+	u.PasswordHash = hash
 	return nil
 }
 
-func (u *User) IsPasswordCorrect(password string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+func (u *User) IsPasswordCorrect(hasher *password.Hasher, pwd string) bool {
+	return hasher.Verify(u.PasswordHash, pwd)
+}
+
+// IsPasswordHashOutdated tells if password hash must be upgraded to current algorithm and params.
+func (u *User) IsPasswordHashOutdated(hasher *password.Hasher) bool {
+	return hasher.NeedsRehash(u.PasswordHash)
 }
 
 func (uo UserOrder) GetOptFn() opt.FnOpt {
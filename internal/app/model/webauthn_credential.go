@@ -0,0 +1,38 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// WebAuthnCredential is public key credential (passkey) of the user, CredentialID is base64url encoded.
+type WebAuthnCredentialList []*WebAuthnCredential
+
+type WebAuthnCredential struct {
+	tableName    struct{}   `pg:"webauthn_credentials"`
+	ID           int64      `pg:"id,pk"`
+	UserID       int64      `pg:"user_id,notnull"`
+	CredentialID string     `pg:"credential_id,notnull"`
+	PublicKey    []byte     `pg:"public_key,notnull"`
+	SignCount    int64      `pg:"sign_count,notnull,use_zero"`
+	Name         string     `pg:"name,notnull,use_zero"`
+	LastUsed     *time.Time `pg:"last_used"`
+	Created      time.Time  `pg:"created,notnull"`
+	Updated      time.Time  `pg:"updated,notnull"`
+}
+
+type WebAuthnCredentialFilter struct {
+	UserID       int64
+	CredentialID string
+}
+
+func (c *WebAuthnCredential) BeforeInsert(ctx context.Context) (context.Context, error) {
+	c.Created = time.Now()
+	c.Updated = time.Now()
+	return ctx, nil
+}
+
+func (c *WebAuthnCredential) BeforeUpdate(ctx context.Context) (context.Context, error) {
+	c.Updated = time.Now()
+	return ctx, nil
+}
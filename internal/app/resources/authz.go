@@ -0,0 +1,19 @@
+package resources
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/internal/pkg/authz"
+)
+
+// manageServiceName is full name of grpc service available to admins only.
+const manageServiceName = "auth.ManageService"
+
+func InitAuthorizer(storage authz.Storage, repo authz.Repository, logger zerolog.Logger) *authz.Authorizer {
+	return authz.New(storage, repo, logger, authz.Config{
+		Services:    []string{manageServiceName},
+		AdminRole:   config.Env().AdminRole,
+		APIKeys:     config.Env().AdminAPIKeys,
+		ClientNames: config.Env().AdminClientNames,
+	})
+}
@@ -0,0 +1,64 @@
+package resources
+
+import (
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"time"
+)
+
+const secretKeyID = "secret"
+
+func InitJwt() (*jwt.Service, error) {
+	keys, err := jwt.NewKeyring(jwt.NewHMACKey(secretKeyID, []byte(config.Env().JwtSecret)))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []jwt.Option{
+		jwt.WithIssuer(config.Env().JwtIssuer),
+		jwt.WithAudience(config.Env().JwtAudience...),
+	}
+	if config.Env().JwtKeyFile != "" {
+		retiredUntil := time.Now().Add(accessKeysRetainTTL())
+		accessKeys, err := loadKeyring(config.Env().JwtKeyID, config.Env().JwtKeyFile, config.Env().JwtRetiredKeys, retiredUntil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, jwt.WithAccessKeys(accessKeys))
+	}
+
+	return jwt.NewService(config.Env().AccessTTL, config.Env().RefreshTTL, keys, opts...), nil
+}
+
+// loadKeyring loads active key and retired ones, which verify tokens until the given time.
+func loadKeyring(activeID, activeFile string, retiredFiles map[string]string, retiredUntil time.Time) (*jwt.Keyring, error) {
+	active, err := jwt.LoadKeyFile(activeID, activeFile)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwt.NewKeyring(active)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, file := range retiredFiles {
+		key, err := jwt.LoadKeyFile(id, file)
+		if err != nil {
+			return nil, err
+		}
+		if err := keys.Retire(key, retiredUntil); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// accessKeysRetainTTL is the longest lifetime of tokens signed by access keys,
+// retired keys are forgotten when it passes since startup.
+func accessKeysRetainTTL() time.Duration {
+	if config.Env().ServiceTokenTTL > config.Env().AccessTTL {
+		return config.Env().ServiceTokenTTL
+	}
+	return config.Env().AccessTTL
+}
@@ -0,0 +1,49 @@
+package resources
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	database "github.com/sanches1984/gopkg-pg-orm"
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/internal/app/service"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/outbox"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+)
+
+// InitNotifier returns nil if notifier type isn't set, so password reset and security notifications are off.
+func InitNotifier(logger zerolog.Logger) (service.Notifier, error) {
+	switch notifier.Type(config.Env().NotifierType) {
+	case "":
+		return nil, nil
+	case notifier.TypeLog:
+		return notifier.NewLog(logger), nil
+	case notifier.TypeFile:
+		return notifier.NewFile(config.Env().NotifierFile), nil
+	case notifier.TypeStdout:
+		return notifier.NewStdout(), nil
+	case notifier.TypeSMTP:
+		return notifier.NewSMTP(notifier.SMTPConfig{
+			Addr:     config.Env().SMTPHost,
+			Username: config.Env().SMTPUsername,
+			Password: config.Env().SMTPPassword,
+			From:     config.Env().SMTPFrom,
+		}), nil
+	default:
+		return nil, notifier.ErrUnknownType
+	}
+}
+
+// InitDispatcher returns dispatcher of the outbox, every poll gets its own database connection.
+func InitDispatcher(repo outbox.Repository, n outbox.Notifier, box *secretbox.Box, db database.IClient, logger zerolog.Logger) *outbox.Dispatcher {
+	return outbox.New(repo, n, box, logger, outbox.Config{
+		Interval:      config.Env().OutboxInterval,
+		BatchSize:     config.Env().OutboxBatchSize,
+		MaxAttempts:   config.Env().OutboxMaxAttempts,
+		RetryDelay:    config.Env().OutboxRetryDelay,
+		MaxRetryDelay: config.Env().OutboxMaxRetryDelay,
+		Lease:         config.Env().OutboxLease,
+	}, outbox.WithContext(func(ctx context.Context) context.Context {
+		return database.NewContext(ctx, db.WrapWithContext(ctx))
+	}))
+}
@@ -0,0 +1,25 @@
+package resources
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	database "github.com/sanches1984/gopkg-pg-orm"
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/internal/pkg/certs"
+	"github.com/sanches1984/msa-auth/internal/pkg/oauth"
+)
+
+// InitOAuth returns http server of oauth token endpoint, nil if it's off. Every request gets its own database connection.
+// The endpoint uses certificates of grpc server if they are set.
+func InitOAuth(issuer oauth.Issuer, db database.IClient, reloader *certs.Reloader, logger zerolog.Logger) *oauth.Service {
+	if config.Env().OAuthHost == "" {
+		return nil
+	}
+	opts := []oauth.Option{oauth.WithContext(func(ctx context.Context) context.Context {
+		return database.NewContext(ctx, db.WrapWithContext(ctx))
+	})}
+	if reloader != nil {
+		opts = append(opts, oauth.WithTLS(reloader.TLSConfig()))
+	}
+	return oauth.NewService(config.Env().OAuthHost, issuer, logger, opts...)
+}
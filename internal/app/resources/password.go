@@ -0,0 +1,35 @@
+package resources
+
+import (
+	"fmt"
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/pkg/password"
+)
+
+func InitPasswordHasher() (*password.Hasher, error) {
+	switch algorithm := password.Algorithm(config.Env().PasswordHashAlgorithm); algorithm {
+	case password.AlgorithmBcrypt:
+		return password.New(password.WithBcrypt(config.Env().PasswordBcryptCost))
+	case password.AlgorithmArgon2id:
+		params := password.DefaultArgon2Params
+		params.Memory = config.Env().PasswordArgon2Memory
+		params.Time = config.Env().PasswordArgon2Time
+		params.Threads = config.Env().PasswordArgon2Threads
+		return password.New(password.WithArgon2id(params))
+	default:
+		return nil, fmt.Errorf("%w: %s", password.ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+func InitPasswordPolicy() password.Policy {
+	return password.Policy{
+		MinLength:     config.Env().PasswordMinLength,
+		MaxLength:     config.Env().PasswordMaxLength,
+		RequireLower:  config.Env().PasswordRequireLower,
+		RequireUpper:  config.Env().PasswordRequireUpper,
+		RequireDigit:  config.Env().PasswordRequireDigit,
+		RequireSymbol: config.Env().PasswordRequireSymbol,
+		ForbidLogin:   config.Env().PasswordForbidLogin,
+		ForbidCommon:  config.Env().PasswordForbidCommon,
+	}
+}
@@ -0,0 +1,40 @@
+package resources
+
+import (
+	"fmt"
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"net"
+)
+
+func InitThrottler(redis throttle.Redis) *throttle.Throttler {
+	return throttle.New(redis, throttle.Config{
+		LoginThreshold: config.Env().ThrottleLoginThreshold,
+		IPThreshold:    config.Env().ThrottleIPThreshold,
+		BaseDelay:      config.Env().ThrottleBaseDelay,
+		MaxDelay:       config.Env().ThrottleMaxDelay,
+		Lockout:        config.Env().ThrottleLockout,
+		Window:         config.Env().ThrottleWindow,
+	})
+}
+
+// InitTrustedProxies parses networks of trusted proxies, single addresses are accepted too.
+func InitTrustedProxies() ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, 0, len(config.Env().TrustedProxies))
+	for _, value := range config.Env().TrustedProxies {
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
@@ -0,0 +1,31 @@
+package resources
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/internal/pkg/certs"
+)
+
+// initReloader returns nil without certFile, so the server is plain. Client certificates are required
+// and verified by clientCAFile if it's set.
+func initReloader(certFile, keyFile, clientCAFile string, logger zerolog.Logger) (*certs.Reloader, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	return certs.New(certs.Config{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: clientCAFile,
+		Interval:     config.Env().TLSReloadInterval,
+	}, logger)
+}
+
+// InitTLS returns certificates of public server, nil if public server is plain.
+func InitTLS(logger zerolog.Logger) (*certs.Reloader, error) {
+	return initReloader(config.Env().TLSCertFile, config.Env().TLSKeyFile, config.Env().TLSClientCAFile, logger)
+}
+
+// InitAdminTLS returns certificates of admin server, nil if admin server is plain.
+func InitAdminTLS(logger zerolog.Logger) (*certs.Reloader, error) {
+	return initReloader(config.Env().AdminTLSCertFile, config.Env().AdminTLSKeyFile, config.Env().AdminTLSClientCAFile, logger)
+}
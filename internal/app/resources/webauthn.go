@@ -0,0 +1,20 @@
+package resources
+
+import (
+	"github.com/sanches1984/msa-auth/config"
+	"github.com/sanches1984/msa-auth/pkg/webauthn"
+)
+
+// InitWebAuthn returns nil if relying party isn't configured, so webauthn is off.
+func InitWebAuthn() (*webauthn.WebAuthn, error) {
+	if config.Env().WebAuthnRPID == "" {
+		return nil, nil
+	}
+	return webauthn.New(webauthn.Config{
+		RPID:                    config.Env().WebAuthnRPID,
+		RPName:                  config.Env().WebAuthnRPName,
+		Origins:                 config.Env().WebAuthnOrigins,
+		Timeout:                 config.Env().MFAChallengeTTL,
+		RequireUserVerification: config.Env().WebAuthnUserVerify,
+	})
+}
@@ -2,12 +2,18 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"github.com/rs/zerolog"
 	log "github.com/sanches1984/gopkg-logger"
 	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/metrics"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
 	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
 	"github.com/sanches1984/msa-auth/pkg/redis"
 	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
 	"time"
 )
 
@@ -17,13 +23,15 @@ type AuthService struct {
 	repo    Repository
 	storage Storage
 	logger  zerolog.Logger
+	options
 }
 
-func NewAuthService(repo Repository, storage Storage, logger zerolog.Logger) *AuthService {
+func NewAuthService(repo Repository, storage Storage, logger zerolog.Logger, opts ...Option) *AuthService {
 	return &AuthService{
 		repo:    repo,
 		storage: storage,
 		logger:  logger,
+		options: newOptions(opts),
 	}
 }
 
@@ -31,55 +39,47 @@ func (s *AuthService) Login(ctx context.Context, r *api.LoginRequest) (*api.Toke
 	if r.GetLogin() == "" || r.GetPassword() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
-	user, err := s.repo.GetUser(ctx, model.UserFilter{Login: r.GetLogin()})
+	throttleKeys := s.loginThrottleKeys(ctx, r.GetLogin())
+	if err := s.checkThrottle(ctx, throttleKeys); err != nil {
+		return nil, convert(err)
+	}
+
+	user, err := userByLogin(ctx, s.repo, r.GetLogin())
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't get user by login")
 		return nil, convert(err)
 	} else if user == nil {
+		// compare password anyway, response time must not tell if user exists
+		s.hasher.VerifyDummy(r.GetPassword())
 		log.WithContext(ctx, s.logger).Info().Str("login", r.GetLogin()).Msg("user not found")
-		return nil, convert(errors.ErrUserNotFound)
+		s.failThrottle(ctx, throttleKeys)
+		return nil, convert(s.loginError(errors.ErrUserNotFound))
 	}
 
-	if !user.IsPasswordCorrect(r.GetPassword()) {
-		return nil, convert(errors.ErrIncorrectPassword)
+	if !user.IsPasswordCorrect(s.hasher, r.GetPassword()) {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("incorrect password")
+		if s.failThrottle(ctx, throttleKeys) {
+			s.notifyLockout(ctx, user.ID)
+		}
+		return nil, convert(s.loginError(errors.ErrIncorrectPassword))
 	}
-
-	session, err := s.storage.CreateSession(user.ID, r.GetData())
-	if err != nil {
-		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't create session")
-		return nil, convert(err)
+	s.resetThrottle(ctx, throttleKeys)
+	if user.IsPasswordHashOutdated(s.hasher) {
+		s.rehashPassword(ctx, user, r.GetPassword())
 	}
 
-	if err := s.repo.CreateRefreshToken(ctx, &model.RefreshToken{
-		UserID:    session.UserID,
-		SessionID: session.ID,
-		Token:     session.Refresh.Value,
-		ExpiresIn: session.Refresh.ExpiresIn,
-	}); err != nil {
-		_ = s.storage.DeleteSession(session.Access.Value)
-		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't create refresh token")
+	resp, err := s.completeLogin(ctx, user.ID, r.GetData())
+	if err != nil {
 		return nil, convert(err)
 	}
-
-	s.logger.Info().Int64("user_id", session.UserID).Msg("login")
-	return &api.TokenResponse{
-		SessionId: session.ID.String(),
-		Access: &api.Token{
-			Token:     session.Access.Value,
-			ExpiresIn: session.Access.ExpiresIn,
-		},
-		Refresh: &api.Token{
-			Token:     session.Refresh.Value,
-			ExpiresIn: session.Refresh.ExpiresIn,
-		},
-	}, nil
+	return resp, nil
 }
 
 func (s *AuthService) Logout(ctx context.Context, r *api.LogoutRequest) (*api.LogoutResponse, error) {
 	if r.GetToken() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
-	userID, sessionID, err := s.storage.DecodeToken(r.GetToken())
+	userID, sessionID, err := s.decodeToken(r.GetToken(), jwt.TokenTypeAccess)
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get session")
 		return nil, convert(err)
@@ -100,10 +100,10 @@ func (s *AuthService) Logout(ctx context.Context, r *api.LogoutRequest) (*api.Lo
 }
 
 func (s *AuthService) ChangePassword(ctx context.Context, r *api.ChangePasswordRequest) (*api.ChangePasswordResponse, error) {
-	if r.GetToken() == "" || r.GetNewPassword() == "" {
+	if r.GetToken() == "" || r.GetOldPassword() == "" || r.GetNewPassword() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
-	userID, _, err := s.storage.DecodeToken(r.GetToken())
+	userID, sessionID, err := s.decodeToken(r.GetToken(), jwt.TokenTypeAccess)
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't decode token")
 		return nil, convert(err)
@@ -128,61 +128,128 @@ func (s *AuthService) ChangePassword(ctx context.Context, r *api.ChangePasswordR
 		return nil, convert(errors.ErrUserNotFound)
 	}
 
-	err = user.SetHashByPassword(r.GetNewPassword())
+	if err := s.verifyPassword(ctx, user, r.GetOldPassword()); err != nil {
+		return nil, convert(err)
+	}
+	if err := s.checkPassword("new_password", user.Login, r.GetNewPassword()); err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Int64("user_id", userID).Msg("password policy violated")
+		return nil, convert(err)
+	}
+	if err := s.checkPasswordHistory(ctx, user, r.GetNewPassword()); err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Int64("user_id", userID).Msg("password reuse")
+		return nil, convert(err)
+	}
+
+	history := &model.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}
+	err = user.SetHashByPassword(s.hasher, r.GetNewPassword())
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't set password hash")
 		return nil, convert(err)
 	}
 
-	err = s.repo.UpdateUserPassword(ctx, user)
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreatePasswordHistory(ctx, history); err != nil {
+			return err
+		}
+		if err := s.repo.UpdateUserPassword(ctx, user); err != nil {
+			return err
+		}
+		return s.notify(ctx, s.repo, user.ID, notifier.KindPasswordChanged, s.clientDetails(ctx))
+	})
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't change user password")
 		return nil, convert(err)
 	}
 
-	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("password changed")
-	return &api.ChangePasswordResponse{Changed: true}, nil
+	resp := &api.ChangePasswordResponse{Changed: true}
+	if s.revokeSessions {
+		revoked, err := s.revokeOtherSessions(ctx, userID, sessionID)
+		if err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't revoke other sessions")
+			return nil, convert(err)
+		}
+		resp.RevokedSessionId = revoked.Sessions()
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Int("revoked", len(resp.RevokedSessionId)).Msg("password changed")
+	return resp, nil
 }
 
 func (s *AuthService) NewAccessTokenByRefreshToken(ctx context.Context, r *api.NewAccessTokenByRefreshTokenRequest) (*api.TokenResponse, error) {
 	if r.GetRefreshToken() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
-	userID, sessionID, err := s.storage.DecodeToken(r.GetRefreshToken())
+	userID, sessionID, err := s.decodeToken(r.GetRefreshToken(), jwt.TokenTypeRefresh)
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't decode token")
 		return nil, convert(err)
 	}
 
-	refreshToken, err := s.repo.GetRefreshToken(ctx, model.RefreshTokenFilter{UserID: userID, SessionID: sessionID})
-	if err != nil {
-		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get refresh token")
-		return nil, convert(err)
-	} else if refreshToken == nil {
-		log.WithContext(ctx, s.logger).Warn().Int64("user_id", userID).Msg("refresh token not found")
-		return nil, convert(errors.ErrBadRequest)
-	} else if refreshToken.Token != r.GetRefreshToken() {
-		return nil, convert(errors.ErrTokenInvalid)
-	} else if refreshToken.IsExpired() {
-		log.WithContext(ctx, s.logger).Warn().Int64("user_id", userID).Msg("refresh token has expired")
-		return nil, convert(errors.ErrTokenExpired)
-	}
-
 	sessionData, err := s.storage.GetSessionDataByUUID(sessionID)
 	if err != nil {
 		log.WithContext(ctx, s.logger).Warn().Err(err).Int64("user_id", userID).Msg("can't get session data")
 	}
 
-	session, err := s.storage.RefreshSession(userID, sessionID, sessionData)
-	if err != nil {
-		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't refresh session")
+	// the token is rotated under row lock, so only one of concurrent refreshes with the same token succeeds
+	// and the other one is handled as reuse
+	var session *storage.Session
+	var superseded bool
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		refreshToken, err := s.repo.LockRefreshToken(ctx, model.RefreshTokenFilter{UserID: userID, SessionID: sessionID})
+		if err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get refresh token")
+			return err
+		} else if refreshToken == nil {
+			log.WithContext(ctx, s.logger).Warn().Int64("user_id", userID).Msg("refresh token not found")
+			return errors.ErrBadRequest
+		} else if !s.storage.MatchToken(refreshToken.TokenHash, r.GetRefreshToken()) {
+			superseded = true
+			return errors.ErrTokenInvalid
+		} else if refreshToken.ClientID != oauthClientID(ctx) {
+			log.WithContext(ctx, s.logger).Warn().Int64("user_id", userID).Str("client_id", oauthClientID(ctx)).Msg("refresh token of another client")
+			return errors.ErrTokenInvalid
+		} else if refreshToken.IsExpired() {
+			log.WithContext(ctx, s.logger).Warn().Int64("user_id", userID).Msg("refresh token has expired")
+			return errors.ErrTokenExpired
+		}
+
+		// grants are read again, so changed roles take effect on refresh
+		grants, err := userGrants(ctx, s.repo, userID)
+		if err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user grants")
+			return err
+		}
+		if session, err = s.storage.IssueSession(userID, sessionID, grants); err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't issue session tokens")
+			return err
+		}
+
+		if err := s.repo.CreateRefreshTokenHistory(ctx, &model.RefreshTokenHistory{
+			UserID:    userID,
+			SessionID: sessionID,
+			TokenHash: refreshToken.TokenHash,
+		}); err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't update refresh token")
+			return err
+		}
+
+		refreshToken.TokenHash = s.storage.HashToken(session.Refresh.Value)
+		refreshToken.ExpiresIn = session.Refresh.ExpiresIn
+		if err := s.repo.UpdateRefreshToken(ctx, refreshToken); err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't update refresh token")
+			return err
+		}
+		return nil
+	})
+	if superseded {
+		return nil, convert(s.checkRefreshTokenReuse(ctx, userID, sessionID, r.GetRefreshToken()))
+	} else if err != nil {
 		return nil, convert(err)
 	}
 
-	refreshToken.Token = session.Refresh.Value
-	refreshToken.ExpiresIn = session.Refresh.ExpiresIn
-	if err := s.repo.UpdateRefreshToken(ctx, refreshToken); err != nil {
-		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't update refresh token")
+	// tokens are replaced in redis only after the rotation is committed
+	if err := s.storage.RefreshSession(session, sessionData); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't refresh session")
 		return nil, convert(err)
 	}
 
@@ -204,9 +271,15 @@ func (s *AuthService) ValidateToken(ctx context.Context, r *api.ValidateTokenReq
 	if r.GetToken() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
-	userID, sessionID, err := s.storage.DecodeToken(r.GetToken())
+	userID, sessionID, err := s.decodeToken(r.GetToken(), jwt.TokenTypeAccess)
+	if err == errors.ErrAccessTokenRequired {
+		// access tokens of service accounts have their own type, so user methods don't accept them
+		if accountID, tokenID, err := s.storage.DecodeToken(r.GetToken(), jwt.TokenTypeService); err == nil {
+			return s.validateServiceToken(ctx, accountID, tokenID)
+		}
+	}
 	if err != nil {
-		return nil, convert(errors.ErrTokenInvalid)
+		return nil, convert(err)
 	}
 	sessionData, err := s.storage.GetSessionData(r.GetToken())
 	if err != nil {
@@ -222,11 +295,19 @@ func (s *AuthService) ValidateToken(ctx context.Context, r *api.ValidateTokenReq
 		return nil, convert(errors.ErrTokenInvalid)
 	}
 
+	grants, err := s.storage.DecodeGrants(r.GetToken())
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't decode grants")
+		return nil, convert(err)
+	}
+
 	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("validate token")
 	return &api.ValidateTokenResponse{
-		UserId:    userID,
-		SessionId: sessionID.String(),
-		Data:      sessionData,
+		UserId:      userID,
+		SessionId:   sessionID.String(),
+		Data:        sessionData,
+		Roles:       grants.Roles,
+		Permissions: grants.Permissions,
 	}, nil
 }
 
@@ -234,13 +315,24 @@ func (s *AuthService) UpdateSessionData(ctx context.Context, r *api.UpdateSessio
 	if r.GetToken() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
-	err := s.storage.UpdateSessionData(r.GetToken(), r.GetData())
-	if err != nil {
+	// token of ended session must not bring it back
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err == errors.ErrSessionNotFound {
+		return nil, convert(errors.ErrTokenInvalid)
+	} else if err != nil {
+		return nil, convert(err)
+	}
+
+	err = s.storage.UpdateSessionData(r.GetToken(), r.GetData())
+	if err == redis.ErrRecordNotFound {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("session not found")
+		return nil, convert(errors.ErrTokenInvalid)
+	} else if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't update session data")
 		return nil, convert(err)
 	}
 
-	log.WithContext(ctx, s.logger).Info().Msg("update session data")
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("update session data")
 	return &api.UpdateSessionDataResponse{Updated: true}, nil
 }
 
@@ -249,7 +341,7 @@ func (s *AuthService) GetUserSessions(ctx context.Context, r *api.GetUserSession
 		return nil, convert(errors.ErrBadRequest)
 	}
 
-	userID, _, err := s.storage.DecodeToken(r.GetToken())
+	userID, _, err := s.decodeToken(r.GetToken(), jwt.TokenTypeAccess)
 	if err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't decode token")
 		return nil, convert(err)
@@ -271,3 +363,210 @@ func (s *AuthService) GetUserSessions(ctx context.Context, r *api.GetUserSession
 
 	return &api.GetUserSessionsResponse{Sessions: sessions}, nil
 }
+
+// completeLogin returns mfa challenge if the user has second factor, otherwise session.
+func (s *AuthService) completeLogin(ctx context.Context, userID int64, data []byte) (*api.TokenResponse, error) {
+	challenge, err := s.mfaChallenge(ctx, userID, data)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create mfa challenge")
+		return nil, err
+	} else if challenge != nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("mfa required")
+		return &api.TokenResponse{Mfa: challenge}, nil
+	}
+
+	return s.createSession(ctx, userID, data)
+}
+
+// createSession starts session of authenticated user and keeps its refresh token.
+func (s *AuthService) createSession(ctx context.Context, userID int64, data []byte) (*api.TokenResponse, error) {
+	grants, err := userGrants(ctx, s.repo, userID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user grants")
+		return nil, err
+	}
+
+	session, err := s.storage.CreateSession(userID, grants, data)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create session")
+		return nil, err
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateRefreshToken(ctx, &model.RefreshToken{
+			UserID:    session.UserID,
+			SessionID: session.ID,
+			TokenHash: s.storage.HashToken(session.Refresh.Value),
+			ExpiresIn: session.Refresh.ExpiresIn,
+			ClientID:  oauthClientID(ctx),
+		}); err != nil {
+			return err
+		}
+		return s.notify(ctx, s.repo, session.UserID, notifier.KindNewLogin, s.clientDetails(ctx))
+	})
+	if err != nil {
+		_ = s.storage.DeleteSession(session.Access.Value)
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create refresh token")
+		return nil, err
+	}
+
+	s.logger.Info().Int64("user_id", session.UserID).Msg("login")
+	return &api.TokenResponse{
+		SessionId: session.ID.String(),
+		Access: &api.Token{
+			Token:     session.Access.Value,
+			ExpiresIn: session.Access.ExpiresIn,
+		},
+		Refresh: &api.Token{
+			Token:     session.Refresh.Value,
+			ExpiresIn: session.Refresh.ExpiresIn,
+		},
+	}, nil
+}
+
+// revokeOtherSessions terminates all sessions of the user except the current one.
+func (s *AuthService) revokeOtherSessions(ctx context.Context, userID int64, current uuid.UUID) (model.RefreshTokenList, error) {
+	tokens, err := s.repo.GetRefreshTokens(ctx, model.RefreshTokenFilter{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens = tokens.Except(current)
+	if err := deleteSessions(s.storage, tokens); err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if err := s.repo.DeleteRefreshToken(ctx, model.RefreshTokenFilter{UserID: userID, SessionID: t.SessionID}); err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+// checkPasswordHistory rejects the current password and recent ones kept in password history.
+func (s *AuthService) checkPasswordHistory(ctx context.Context, user *model.User, password string) error {
+	if s.passwordHistory <= 0 {
+		return nil
+	}
+
+	reused := user.IsPasswordCorrect(s.hasher, password)
+	if !reused && s.passwordHistory > 1 {
+		history, err := s.repo.GetPasswordHistory(ctx, model.PasswordHistoryFilter{
+			UserID: user.ID,
+			Limit:  int32(s.passwordHistory - 1),
+		})
+		if err != nil {
+			return err
+		}
+		for _, h := range history {
+			if s.hasher.Verify(h.PasswordHash, password) {
+				reused = true
+				break
+			}
+		}
+	}
+
+	if reused {
+		return &errors.ValidationError{Violations: []errors.FieldViolation{{
+			Field:       "new_password",
+			Description: fmt.Sprintf("not_reused: must differ from the last %d passwords", s.passwordHistory),
+		}}}
+	}
+	return nil
+}
+
+// loginError hides the reason of failed login in generic errors mode.
+func (s *AuthService) loginError(err error) error {
+	if s.genericErrors {
+		return errors.ErrInvalidCredentials
+	}
+	return err
+}
+
+// rehashPassword upgrades hash made by outdated algorithm or params, login doesn't fail on errors.
+func (s *AuthService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	if err := user.SetHashByPassword(s.hasher, password); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't set password hash")
+		return
+	}
+	if err := s.repo.UpdateUserPassword(ctx, user); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't upgrade password hash")
+		return
+	}
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("password hash upgraded")
+}
+
+// checkRefreshTokenReuse treats superseded refresh token as stolen and revokes the whole session.
+func (s *AuthService) checkRefreshTokenReuse(ctx context.Context, userID int64, sessionID uuid.UUID, token string) error {
+	history, err := s.repo.GetRefreshTokenHistory(ctx, model.RefreshTokenHistoryFilter{UserID: userID, SessionID: sessionID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get refresh token history")
+		return err
+	}
+
+	var superseded *model.RefreshTokenHistory
+	for _, h := range history {
+		if s.storage.MatchToken(h.TokenHash, token) {
+			superseded = h
+			break
+		}
+	}
+	if superseded == nil {
+		return errors.ErrTokenInvalid
+	}
+
+	log.WithContext(ctx, s.logger).Warn().
+		Str("event", metrics.EventRefreshTokenReuse).
+		Int64("user_id", userID).
+		Str("session_id", sessionID.String()).
+		Time("superseded", superseded.Created).
+		Msg("security event: refresh token reuse, session revoked")
+	metrics.SecurityEvent(metrics.EventRefreshTokenReuse)
+
+	if err := s.storage.DeleteSessionByUUID(sessionID); err != nil && err != redis.ErrRecordNotFound {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't delete session")
+		return err
+	}
+	if err := s.repo.DeleteRefreshToken(ctx, model.RefreshTokenFilter{UserID: userID, SessionID: sessionID}); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't delete refresh token")
+		return err
+	}
+
+	return errors.ErrRefreshTokenReused
+}
+
+// sessionUser returns user of access token with active session.
+func (s *AuthService) sessionUser(ctx context.Context, token string) (int64, error) {
+	userID, _, err := s.decodeToken(token, jwt.TokenTypeAccess)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't decode token")
+		return 0, err
+	}
+
+	if _, err := s.storage.GetSessionData(token); err == redis.ErrRecordNotFound {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("session not found")
+		return 0, errors.ErrSessionNotFound
+	} else if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get session data")
+		return 0, err
+	}
+	return userID, nil
+}
+
+// decodeToken accepts tokens of the given type only and hides jwt errors behind client errors.
+func (s *AuthService) decodeToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error) {
+	userID, sessionID, err := s.storage.DecodeToken(token, typ)
+	switch {
+	case err == nil:
+		return userID, sessionID, nil
+	case err == jwt.ErrInvalidType && typ == jwt.TokenTypeRefresh:
+		return 0, uuid.Nil, errors.ErrRefreshTokenRequired
+	case err == jwt.ErrInvalidType:
+		return 0, uuid.Nil, errors.ErrAccessTokenRequired
+	case err == jwt.ErrTokenExpired:
+		return 0, uuid.Nil, errors.ErrTokenExpired
+	default:
+		return 0, uuid.Nil, errors.ErrTokenInvalid
+	}
+}
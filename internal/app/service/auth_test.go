@@ -1,26 +1,46 @@
 package service
 
 import (
+	"context"
 	"github.com/golang/mock/gomock"
 	"github.com/rs/zerolog"
+	"github.com/sanches1984/msa-auth/internal/app/model"
 	"github.com/sanches1984/msa-auth/internal/app/service/mocks"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	errs "github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
 	"testing"
+	"time"
 )
 
 type AuthSuite struct {
 	suite.Suite
 
-	ctrl    *gomock.Controller
-	repo    *mocks.MockRepository
-	storage *mocks.MockStorage
-	logger  zerolog.Logger
+	ctrl      *gomock.Controller
+	repo      *mocks.MockRepository
+	storage   *mocks.MockStorage
+	throttler *mocks.MockThrottler
+	logger    zerolog.Logger
 }
 
 func (s *AuthSuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
 	s.repo = mocks.NewMockRepository(s.ctrl)
 	s.storage = mocks.NewMockStorage(s.ctrl)
+	s.throttler = mocks.NewMockThrottler(s.ctrl)
 	s.logger = zerolog.Nop()
 }
 
@@ -28,16 +48,155 @@ func (s *AuthSuite) TearDownTest() {
 	s.ctrl.Finish()
 }
 
+func (s *AuthSuite) expectTransaction(ctx context.Context) {
+	s.repo.EXPECT().WithTransaction(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	}).Times(1)
+}
+
 func TestAuthService(t *testing.T) {
 	suite.Run(t, new(AuthSuite))
 }
 
+func (s *AuthSuite) newUser(hasher *password.Hasher, pwd string) *model.User {
+	user := &model.User{ID: 123, Login: "login"}
+	s.Require().NoError(user.SetHashByPassword(hasher, pwd))
+	return user
+}
+
+func (s *AuthSuite) expectSession(ctx context.Context, userID int64) {
+	sessionID := uuid.NewV4()
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{UserID: userID}).Return(nil, nil).Times(1)
+	s.storage.EXPECT().CreateSession(userID, jwt.Grants{}, []byte("data")).Return(&storage.Session{
+		ID:      sessionID,
+		UserID:  userID,
+		Access:  storage.Token{Value: "access", ExpiresIn: 111},
+		Refresh: storage.Token{Value: "refresh", ExpiresIn: 222},
+	}, nil).Times(1)
+	s.storage.EXPECT().HashToken("refresh").Return("refresh_hash").Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().CreateRefreshToken(ctx, &model.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: "refresh_hash",
+		ExpiresIn: 222,
+	}).Return(nil).Times(1)
+}
+
 func (s *AuthSuite) TestLogin_Success() {
-	// todo
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(user, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, user.ID).Return(nil, nil).Times(1)
+	s.expectSession(ctx, user.ID)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "password",
+		Data:     []byte("data"),
+	})
+	s.NoError(err)
+	s.Equal("access", resp.Access.Token)
+	s.Equal("refresh", resp.Refresh.Token)
+}
+
+func (s *AuthSuite) TestLogin_Rehash() {
+	ctx := context.Background()
+	legacy, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	hasher, err := password.New(password.WithArgon2id(password.Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLength: 16, KeyLength: 32}))
+	s.Require().NoError(err)
+	user := s.newUser(legacy, "password")
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(user, nil).Times(1)
+	s.repo.EXPECT().UpdateUserPassword(ctx, user).DoAndReturn(func(ctx context.Context, user *model.User) error {
+		s.Equal(password.AlgorithmArgon2id, password.Identify(user.PasswordHash))
+		s.True(user.IsPasswordCorrect(hasher, "password"))
+		return nil
+	}).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, user.ID).Return(nil, nil).Times(1)
+	s.expectSession(ctx, user.ID)
+
+	_, err = NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "password",
+		Data:     []byte("data"),
+	})
+	s.NoError(err)
 }
 
 func (s *AuthSuite) TestLogin_Error() {
-	// todo
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(s.newUser(hasher, "password"), nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "wrong",
+	})
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *AuthSuite) TestLogin_GenericErrors() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "unknown"}).Return(nil, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(s.newUser(hasher, "password"), nil).Times(1)
+
+	service := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithGenericLoginErrors(true))
+	for _, login := range []string{"unknown", "login"} {
+		resp, err := service.Login(ctx, &api.LoginRequest{Login: login, Password: "wrong"})
+		s.Nil(resp)
+		s.Equal(codes.Unauthenticated, status.Code(err))
+		s.EqualError(err, errs.ErrInvalidCredentials.Error())
+	}
+}
+
+func (s *AuthSuite) TestLogin_Throttled() {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", "10.0.0.1, 192.168.0.2"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 5000}})
+	_, proxies, err := net.ParseCIDR("192.168.0.0/16")
+	s.Require().NoError(err)
+	keys := []throttle.Key{throttle.LoginKey("login"), throttle.IPKey("10.0.0.1")}
+	s.throttler.EXPECT().Check(keys).Return(time.Minute, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithThrottler(s.throttler), WithTrustedProxies([]*net.IPNet{proxies})).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "password",
+	})
+	s.Nil(resp)
+	st := status.Convert(err)
+	s.Equal(codes.ResourceExhausted, st.Code())
+	s.Require().Len(st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.RetryInfo)
+	s.Require().True(ok)
+	s.Equal(time.Minute, info.RetryDelay.AsDuration())
+}
+
+func (s *AuthSuite) TestLogin_FailureCounted() {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	keys := []throttle.Key{throttle.LoginKey("login"), throttle.IPKey("10.0.0.1")}
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+
+	s.throttler.EXPECT().Check(keys).Return(time.Duration(0), nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(s.newUser(hasher, "password"), nil).Times(1)
+	s.throttler.EXPECT().Fail(keys).Return([]throttle.Lockout{{Key: keys[0], Failures: 5, Locked: true}}, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithThrottler(s.throttler)).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "wrong",
+	})
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
 }
 
 func (s *AuthSuite) TestLogout_Success() {
@@ -45,39 +204,274 @@ func (s *AuthSuite) TestLogout_Success() {
 }
 
 func (s *AuthSuite) TestLogout_Error() {
-	// todo
+	ctx := context.Background()
+	s.storage.EXPECT().DecodeToken("refresh", jwt.TokenTypeAccess).Return(int64(0), uuid.Nil, jwt.ErrInvalidType).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).Logout(ctx, &api.LogoutRequest{Token: "refresh"})
+	s.Nil(resp)
+	s.EqualError(err, errs.ErrAccessTokenRequired.Error())
+	s.Equal(codes.Unauthenticated, status.Code(err))
 }
 
 func (s *AuthSuite) TestChangePassword_Success() {
-	// todo
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "old-password")
+	oldHash := user.PasswordHash
+	previous := s.newUser(hasher, "previous-password")
+
+	sessionID := uuid.NewV4()
+	otherSessionID := uuid.NewV4()
+
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(user.ID, sessionID, nil).Times(1)
+	s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(1)
+	s.repo.EXPECT().GetPasswordHistory(ctx, model.PasswordHistoryFilter{UserID: user.ID, Limit: 2}).
+		Return(model.PasswordHistoryList{{UserID: user.ID, PasswordHash: previous.PasswordHash}}, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().CreatePasswordHistory(ctx, &model.PasswordHistory{UserID: user.ID, PasswordHash: oldHash}).Return(nil).Times(1)
+	s.repo.EXPECT().UpdateUserPassword(ctx, user).Return(nil).Times(1)
+	s.repo.EXPECT().GetRefreshTokens(ctx, model.RefreshTokenFilter{UserID: user.ID}).Return(model.RefreshTokenList{
+		{UserID: user.ID, SessionID: sessionID},
+		{UserID: user.ID, SessionID: otherSessionID},
+	}, nil).Times(1)
+	s.storage.EXPECT().DeleteSessionByUUID(otherSessionID).Return(nil).Times(1)
+	s.repo.EXPECT().DeleteRefreshToken(ctx, model.RefreshTokenFilter{UserID: user.ID, SessionID: otherSessionID}).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithPasswordHistory(3)).
+		ChangePassword(ctx, &api.ChangePasswordRequest{
+			Token:       "access",
+			OldPassword: "old-password",
+			NewPassword: "new-password",
+		})
+	s.NoError(err)
+	s.True(resp.Changed)
+	s.Equal([]string{otherSessionID.String()}, resp.RevokedSessionId)
+	s.True(user.IsPasswordCorrect(hasher, "new-password"))
 }
 
 func (s *AuthSuite) TestChangePassword_Error() {
-	// todo
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	previous := s.newUser(hasher, "previous-password")
+
+	cases := []struct {
+		oldPassword string
+		newPassword string
+		history     bool
+		code        codes.Code
+	}{
+		{oldPassword: "wrong-password", newPassword: "new-password", code: codes.PermissionDenied},
+		{oldPassword: "old-password", newPassword: "short", code: codes.InvalidArgument},
+		{oldPassword: "old-password", newPassword: "old-password", code: codes.InvalidArgument},
+		{oldPassword: "old-password", newPassword: "previous-password", history: true, code: codes.InvalidArgument},
+	}
+
+	for n, c := range cases {
+		user := s.newUser(hasher, "old-password")
+		s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(user.ID, uuid.NewV4(), nil).Times(1)
+		s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(1)
+		s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(1)
+		if c.history {
+			s.repo.EXPECT().GetPasswordHistory(ctx, gomock.Any()).
+				Return(model.PasswordHistoryList{{UserID: user.ID, PasswordHash: previous.PasswordHash}}, nil).Times(1)
+		}
+
+		resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithPasswordHistory(3)).
+			ChangePassword(ctx, &api.ChangePasswordRequest{
+				Token:       "access",
+				OldPassword: c.oldPassword,
+				NewPassword: c.newPassword,
+			})
+		s.Nilf(resp, "case %d", n)
+		s.Equalf(c.code, status.Code(err), "case %d", n)
+	}
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).ChangePassword(ctx, &api.ChangePasswordRequest{
+		Token:       "access",
+		NewPassword: "new-password",
+	})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *AuthSuite) TestChangePassword_Throttled() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "old-password")
+	keys := []throttle.Key{throttle.LoginKey("login")}
+	service := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithThrottler(s.throttler))
+	req := &api.ChangePasswordRequest{Token: "access", OldPassword: "wrong-password", NewPassword: "new-password"}
+
+	// wrong old password counts as failed login
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(user.ID, uuid.NewV4(), nil).Times(2)
+	s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(2)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(2)
+	s.throttler.EXPECT().Check(keys).Return(time.Duration(0), nil).Times(1)
+	s.throttler.EXPECT().Fail(keys).Return(nil, nil).Times(1)
+	resp, err := service.ChangePassword(ctx, req)
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	s.throttler.EXPECT().Check(keys).Return(time.Minute, nil).Times(1)
+	resp, err = service.ChangePassword(ctx, req)
+	s.Nil(resp)
+	s.Equal(codes.ResourceExhausted, status.Code(err))
 }
 
 func (s *AuthSuite) TestNewAccessTokenByRefreshToken_Success() {
-	// todo
+	ctx := context.Background()
+	sessionID := uuid.NewV4()
+	expiresIn := int32(time.Now().Add(time.Hour).Unix())
+	refreshToken := &model.RefreshToken{ID: 1, UserID: 123, SessionID: sessionID, TokenHash: "hash", ExpiresIn: expiresIn}
+	session := &storage.Session{
+		ID:      sessionID,
+		UserID:  123,
+		Access:  storage.Token{Value: "new_access", ExpiresIn: expiresIn},
+		Refresh: storage.Token{Value: "new_refresh", ExpiresIn: expiresIn},
+	}
+
+	s.storage.EXPECT().DecodeToken("refresh", jwt.TokenTypeRefresh).Return(int64(123), sessionID, nil).Times(1)
+	s.storage.EXPECT().GetSessionDataByUUID(sessionID).Return([]byte("data"), nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().LockRefreshToken(ctx, model.RefreshTokenFilter{UserID: 123, SessionID: sessionID}).Return(refreshToken, nil).Times(1)
+	s.storage.EXPECT().MatchToken("hash", "refresh").Return(true).Times(1)
+	// roles changed since login are picked up
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{UserID: 123}).Return(model.RoleList{
+		{ID: 1, Name: "editor", Permissions: model.PermissionList{{ID: 2, Name: "posts:write"}, {ID: 1, Name: "posts:read"}}},
+		{ID: 2, Name: "admin", Permissions: model.PermissionList{{ID: 1, Name: "posts:read"}}},
+	}, nil).Times(1)
+	grants := jwt.Grants{Roles: []string{"admin", "editor"}, Permissions: []string{"posts:read", "posts:write"}}
+	s.storage.EXPECT().IssueSession(int64(123), sessionID, grants).Return(session, nil).Times(1)
+	s.repo.EXPECT().CreateRefreshTokenHistory(ctx, &model.RefreshTokenHistory{UserID: 123, SessionID: sessionID, TokenHash: "hash"}).Return(nil).Times(1)
+	s.storage.EXPECT().HashToken("new_refresh").Return("new_hash").Times(1)
+	update := s.repo.EXPECT().UpdateRefreshToken(ctx, &model.RefreshToken{ID: 1, UserID: 123, SessionID: sessionID, TokenHash: "new_hash", ExpiresIn: expiresIn}).Return(nil).Times(1)
+	// redis session is replaced after the rotation
+	s.storage.EXPECT().RefreshSession(session, []byte("data")).Return(nil).After(update).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).NewAccessTokenByRefreshToken(ctx, &api.NewAccessTokenByRefreshTokenRequest{
+		RefreshToken: "refresh",
+	})
+	s.NoError(err)
+	s.Equal(&api.TokenResponse{
+		SessionId: sessionID.String(),
+		Access:    &api.Token{Token: "new_access", ExpiresIn: expiresIn},
+		Refresh:   &api.Token{Token: "new_refresh", ExpiresIn: expiresIn},
+	}, resp)
+}
+
+func (s *AuthSuite) TestNewAccessTokenByRefreshToken_Reuse() {
+	ctx := context.Background()
+	sessionID := uuid.NewV4()
+	filter := model.RefreshTokenFilter{UserID: 123, SessionID: sessionID}
+
+	s.storage.EXPECT().DecodeToken("old_refresh", jwt.TokenTypeRefresh).Return(int64(123), sessionID, nil).Times(1)
+	s.storage.EXPECT().GetSessionDataByUUID(sessionID).Return([]byte("data"), nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().LockRefreshToken(ctx, filter).Return(&model.RefreshToken{UserID: 123, SessionID: sessionID, TokenHash: "hash"}, nil).Times(1)
+	s.storage.EXPECT().MatchToken("hash", "old_refresh").Return(false).Times(1)
+	s.repo.EXPECT().GetRefreshTokenHistory(ctx, model.RefreshTokenHistoryFilter{UserID: 123, SessionID: sessionID}).Return(model.RefreshTokenHistoryList{
+		{UserID: 123, SessionID: sessionID, TokenHash: "older_hash"},
+		{UserID: 123, SessionID: sessionID, TokenHash: "old_hash"},
+	}, nil).Times(1)
+	s.storage.EXPECT().MatchToken("older_hash", "old_refresh").Return(false).Times(1)
+	s.storage.EXPECT().MatchToken("old_hash", "old_refresh").Return(true).Times(1)
+	s.storage.EXPECT().DeleteSessionByUUID(sessionID).Return(nil).Times(1)
+	s.repo.EXPECT().DeleteRefreshToken(ctx, filter).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).NewAccessTokenByRefreshToken(ctx, &api.NewAccessTokenByRefreshTokenRequest{
+		RefreshToken: "old_refresh",
+	})
+	s.Nil(resp)
+	s.EqualError(err, errs.ErrRefreshTokenReused.Error())
+	s.Equal(codes.Unauthenticated, status.Code(err))
 }
 
 func (s *AuthSuite) TestNewAccessTokenByRefreshToken_Error() {
-	// todo
+	ctx := context.Background()
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeRefresh).Return(int64(0), uuid.Nil, jwt.ErrInvalidType).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).NewAccessTokenByRefreshToken(ctx, &api.NewAccessTokenByRefreshTokenRequest{
+		RefreshToken: "access",
+	})
+	s.Nil(resp)
+	s.EqualError(err, errs.ErrRefreshTokenRequired.Error())
+	s.Equal(codes.Unauthenticated, status.Code(err))
 }
 
 func (s *AuthSuite) TestValidateToken_Success() {
-	// todo
+	ctx := context.Background()
+	sessionID := uuid.NewV4()
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(int64(123), sessionID, nil).Times(1)
+	s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123}, nil).Times(1)
+	s.storage.EXPECT().DecodeGrants("access").Return(jwt.Grants{Roles: []string{"admin"}, Permissions: []string{"users:write"}}, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).ValidateToken(ctx, &api.ValidateTokenRequest{Token: "access"})
+	s.NoError(err)
+	s.Equal(&api.ValidateTokenResponse{
+		UserId:      123,
+		SessionId:   sessionID.String(),
+		Data:        []byte("data"),
+		Roles:       []string{"admin"},
+		Permissions: []string{"users:write"},
+	}, resp)
 }
 
 func (s *AuthSuite) TestValidateToken_Error() {
-	// todo
+	ctx := context.Background()
+	cases := []struct {
+		decodeErr error
+		err       error
+	}{
+		{decodeErr: jwt.ErrInvalidType, err: errs.ErrAccessTokenRequired},
+		{decodeErr: jwt.ErrTokenExpired, err: errs.ErrTokenExpired},
+		{decodeErr: jwt.ErrInvalidIssuer, err: errs.ErrTokenInvalid},
+	}
+
+	for n, c := range cases {
+		s.storage.EXPECT().DecodeToken("token", jwt.TokenTypeAccess).Return(int64(0), uuid.Nil, c.decodeErr).Times(1)
+		if c.decodeErr == jwt.ErrInvalidType {
+			s.storage.EXPECT().DecodeToken("token", jwt.TokenTypeService).Return(int64(0), uuid.Nil, c.decodeErr).Times(1)
+		}
+
+		resp, err := NewAuthService(s.repo, s.storage, s.logger).ValidateToken(ctx, &api.ValidateTokenRequest{Token: "token"})
+		s.Nilf(resp, "case %d", n)
+		s.EqualErrorf(err, c.err.Error(), "case %d", n)
+		s.Equalf(codes.Unauthenticated, status.Code(err), "case %d", n)
+	}
 }
 
 func (s *AuthSuite) TestUpdateSessionData_Success() {
-	// todo
+	ctx := context.Background()
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(int64(123), uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(1)
+	s.storage.EXPECT().UpdateSessionData("access", []byte("new")).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).UpdateSessionData(ctx, &api.UpdateSessionDataRequest{Token: "access", Data: []byte("new")})
+	s.Require().NoError(err)
+	s.True(resp.Updated)
 }
 
 func (s *AuthSuite) TestUpdateSessionData_Error() {
-	// todo
+	ctx := context.Background()
+	service := NewAuthService(s.repo, s.storage, s.logger)
+
+	// token of ended session
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(int64(123), uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("access").Return(nil, redis.ErrRecordNotFound).Times(1)
+	_, err := service.UpdateSessionData(ctx, &api.UpdateSessionDataRequest{Token: "access", Data: []byte("new")})
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	// session ended before update
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(int64(123), uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(1)
+	s.storage.EXPECT().UpdateSessionData("access", []byte("new")).Return(redis.ErrRecordNotFound).Times(1)
+	_, err = service.UpdateSessionData(ctx, &api.UpdateSessionDataRequest{Token: "access", Data: []byte("new")})
+	s.Equal(codes.Unauthenticated, status.Code(err))
 }
 
 func (s *AuthSuite) TestGetUserSessions_Success() {
@@ -3,8 +3,10 @@ package service
 import (
 	dberr "github.com/sanches1984/gopkg-pg-orm/errors"
 	"github.com/sanches1984/msa-auth/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type GRPCError interface {
@@ -35,12 +37,25 @@ func convert(err error) GRPCError {
 		}
 	}
 
+	if v, ok := err.(*errors.ValidationError); ok {
+		return newValidationError(v)
+	}
+	if v, ok := err.(*errors.RetryAfterError); ok {
+		return newRetryAfterError(v)
+	}
+
 	switch err {
-	case errors.ErrUserNotFound:
+	case errors.ErrUserNotFound, errors.ErrRoleNotFound, errors.ErrPermissionNotFound,
+		errors.ErrServiceAccountNotFound, errors.ErrAPIKeyNotFound, errors.ErrOAuthClientNotFound:
 		return newGRPCError(err, codes.NotFound)
-	case errors.ErrIncorrectPassword:
+	case errors.ErrIncorrectPassword, errors.ErrIncorrectCode:
 		return newGRPCError(err, codes.PermissionDenied)
-	case errors.ErrSessionNotFound, errors.ErrTokenExpired, errors.ErrTokenInvalid:
+	case errors.ErrMFANotConfigured, errors.ErrMFANotEnabled, errors.ErrMFAAlreadyEnabled, errors.ErrWebAuthnNotConfigured,
+		errors.ErrNotifierNotConfigured, errors.ErrEmailAlreadyVerified:
+		return newGRPCError(err, codes.FailedPrecondition)
+	case errors.ErrSessionNotFound, errors.ErrTokenExpired, errors.ErrTokenInvalid,
+		errors.ErrAccessTokenRequired, errors.ErrRefreshTokenRequired, errors.ErrRefreshTokenReused,
+		errors.ErrInvalidCredentials, errors.ErrCredentialInvalid, errors.ErrAPIKeyInvalid:
 		return newGRPCError(err, codes.Unauthenticated)
 	case errors.ErrBadRequest:
 		return newGRPCError(err, codes.InvalidArgument)
@@ -56,6 +71,32 @@ func newGRPCError(err error, code codes.Code) grpcError {
 	}
 }
 
+// newValidationError returns InvalidArgument with violations in BadRequest details.
+func newValidationError(err *errors.ValidationError) grpcError {
+	details := &errdetails.BadRequest{}
+	for _, v := range err.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, err.Error())
+	if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+		st = withDetails
+	}
+	return grpcError{err: err, status: st}
+}
+
+// newRetryAfterError returns ResourceExhausted with delay in RetryInfo details.
+func newRetryAfterError(err *errors.RetryAfterError) grpcError {
+	st := status.New(codes.ResourceExhausted, err.Error())
+	if withDetails, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(err.RetryAfter)}); detailsErr == nil {
+		st = withDetails
+	}
+	return grpcError{err: err, status: st}
+}
+
 func (e grpcError) Error() string {
 	return e.err.Error()
 }
@@ -9,6 +9,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"testing"
+	"time"
 )
 
 type ConverterSuite struct {
@@ -74,14 +75,46 @@ func (s *ConverterSuite) TestConvert() {
 			err:  errs.ErrSessionNotFound,
 			code: codes.Unauthenticated,
 		},
+		{
+			err:  errs.ErrAccessTokenRequired,
+			code: codes.Unauthenticated,
+		},
+		{
+			err:  errs.ErrRefreshTokenRequired,
+			code: codes.Unauthenticated,
+		},
+		{
+			err:  errs.ErrRefreshTokenReused,
+			code: codes.Unauthenticated,
+		},
+		{
+			err:  errs.ErrInvalidCredentials,
+			code: codes.Unauthenticated,
+		},
 		{
 			err:  errs.ErrIncorrectPassword,
 			code: codes.PermissionDenied,
 		},
+		{
+			err:  errs.ErrIncorrectCode,
+			code: codes.PermissionDenied,
+		},
+		{
+			err:  errs.ErrMFANotEnabled,
+			code: codes.FailedPrecondition,
+		},
 		{
 			err:  errs.ErrBadRequest,
 			code: codes.InvalidArgument,
 		},
+		{
+			err:  &errs.ValidationError{Violations: []errs.FieldViolation{{Field: "password", Description: "too short"}}},
+			code: codes.InvalidArgument,
+		},
+		{
+			err:  &errs.RetryAfterError{Err: errs.ErrTooManyAttempts, RetryAfter: time.Minute},
+			code: codes.ResourceExhausted,
+		},
 		{
 			err:  errors.New("test"),
 			code: codes.Internal,
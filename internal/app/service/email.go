@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const defaultEmailVerificationTTL = 24 * time.Hour
+
+// SendEmailVerification queues verification token to the email of access token user in the outbox. New email replaces
+// unverified one at once, verified email is replaced only when the new one is confirmed.
+func (s *AuthService) SendEmailVerification(ctx context.Context, r *api.SendEmailVerificationRequest) (*api.SendEmailVerificationResponse, error) {
+	if r.GetToken() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.notifier == nil {
+		return nil, convert(errors.ErrNotifierNotConfigured)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: userID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("user not found")
+		return nil, convert(errors.ErrUserNotFound)
+	}
+
+	email := user.Email
+	if r.GetEmail() != "" {
+		if email, err = normalizeEmail("email", r.GetEmail()); err != nil {
+			return nil, convert(err)
+		}
+	}
+	if email == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if email == user.Email && user.EmailVerified {
+		return nil, convert(errors.ErrEmailAlreadyVerified)
+	}
+
+	token, err := random.String(sentTokenSize)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't generate verification token")
+		return nil, convert(err)
+	}
+	expiresAt := time.Now().Add(s.verifyTTL)
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if !user.EmailVerified && user.Email != email {
+			user.SetEmail(email)
+			if err := s.repo.UpdateUserEmail(ctx, user); err != nil {
+				return err
+			}
+		}
+		err := s.repo.CreateEmailVerificationToken(ctx, &model.EmailVerificationToken{
+			UserID:    userID,
+			Email:     email,
+			TokenHash: s.storage.HashToken(token),
+			ExpiresIn: int32(expiresAt.Unix()),
+		})
+		if err != nil {
+			return err
+		}
+		return s.queueToken(ctx, s.repo, userID, notifier.KindEmailVerification, token, expiresAt, email)
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create verification token")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("email verification queued")
+	return &api.SendEmailVerificationResponse{Sent: true}, nil
+}
+
+// ConfirmEmail marks email of verification token as verified, so it may be used as login.
+func (s *AuthService) ConfirmEmail(ctx context.Context, r *api.ConfirmEmailRequest) (*api.ConfirmEmailResponse, error) {
+	if r.GetToken() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+
+	token, err := s.repo.GetEmailVerificationToken(ctx, s.storage.HashToken(r.GetToken()))
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get verification token")
+		return nil, convert(err)
+	} else if token == nil {
+		log.WithContext(ctx, s.logger).Info().Msg("verification token not found")
+		return nil, convert(errors.ErrTokenInvalid)
+	} else if token.IsExpired() {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", token.UserID).Msg("verification token has expired")
+		return nil, convert(errors.ErrTokenExpired)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: token.UserID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", token.UserID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", token.UserID).Msg("user not found")
+		return nil, convert(errors.ErrTokenInvalid)
+	}
+
+	user.VerifyEmail(token.Email)
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateUserEmail(ctx, user); err != nil {
+			return err
+		}
+		return s.repo.DeleteEmailVerificationTokens(ctx, user.ID)
+	})
+	if err != nil {
+		// unique index tells that another user has verified the email first
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't verify email")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("email verified")
+	return &api.ConfirmEmailResponse{Email: user.Email, Verified: true}, nil
+}
+
+// userByLogin finds user by login or, if there is no such login, by verified email.
+func userByLogin(ctx context.Context, repo Repository, login string) (*model.User, error) {
+	user, err := repo.GetUser(ctx, model.UserFilter{Login: login})
+	if err != nil || user != nil || !strings.Contains(login, "@") {
+		return user, err
+	}
+	return repo.GetUser(ctx, model.UserFilter{Email: strings.ToLower(login), EmailStatus: model.EmailStatusVerified})
+}
+
+// normalizeEmail returns bare address in lower case or errors.ValidationError for field.
+func normalizeEmail(field, email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", &errors.ValidationError{Violations: []errors.FieldViolation{{
+			Field:       field,
+			Description: "format: must be email address",
+		}}}
+	}
+	return strings.ToLower(addr.Address), nil
+}
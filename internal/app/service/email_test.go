@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/app/service/mocks"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+func (s *AuthSuite) TestSendEmailVerification_Success() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+	user := &model.User{ID: 123, Login: "login"}
+
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(user, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).DoAndReturn(func(token string) string {
+		return "hash:" + token
+	}).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().UpdateUserEmail(ctx, user).Return(nil).Times(1)
+	var stored *model.EmailVerificationToken
+	s.repo.EXPECT().CreateEmailVerificationToken(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, t *model.EmailVerificationToken) error {
+		stored = t
+		return nil
+	}).Times(1)
+	s.repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, m *model.OutboxMessage) error {
+		s.Equal(notifier.KindEmailVerification, m.Kind)
+		s.Equal(int64(123), m.UserID)
+		s.Equal("user@example.com", m.Data[notifier.DataEmail])
+		token, err := box.Open(m.Data[notifier.DataToken], notifier.TokenAdditionalData(123, notifier.KindEmailVerification))
+		s.Require().NoError(err)
+		s.Equal("hash:"+string(token), stored.TokenHash)
+		return nil
+	}).Times(1)
+
+	// the token is queued in transaction, not sent by notifier
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithNotifier(mocks.NewMockNotifier(s.ctrl), box)).SendEmailVerification(ctx, &api.SendEmailVerificationRequest{
+		Token: "access",
+		Email: " User@Example.com",
+	})
+	s.Require().NoError(err)
+	s.True(resp.Sent)
+	s.Equal("user@example.com", user.Email)
+	s.False(user.EmailVerified)
+	s.Equal("user@example.com", stored.Email)
+}
+
+func (s *AuthSuite) TestSendEmailVerification_Error() {
+	ctx := context.Background()
+	service := NewAuthService(s.repo, s.storage, s.logger, WithNotifier(mocks.NewMockNotifier(s.ctrl), nil))
+
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	resp, err := service.SendEmailVerification(ctx, &api.SendEmailVerificationRequest{Token: "access", Email: "Name <user@example.com>"})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{
+		ID:            123,
+		Login:         "login",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	}, nil).Times(1)
+	resp, err = service.SendEmailVerification(ctx, &api.SendEmailVerificationRequest{Token: "access", Email: "user@example.com"})
+	s.Nil(resp)
+	s.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func (s *AuthSuite) TestConfirmEmail_Success() {
+	ctx := context.Background()
+	user := &model.User{ID: 123, Login: "login", Email: "old@example.com", EmailVerified: true}
+
+	s.storage.EXPECT().HashToken("token").Return("token_hash").Times(1)
+	s.repo.EXPECT().GetEmailVerificationToken(ctx, "token_hash").Return(&model.EmailVerificationToken{
+		UserID:    123,
+		Email:     "new@example.com",
+		ExpiresIn: int32(time.Now().Add(time.Hour).Unix()),
+	}, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(user, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().UpdateUserEmail(ctx, user).Return(nil).Times(1)
+	s.repo.EXPECT().DeleteEmailVerificationTokens(ctx, int64(123)).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).ConfirmEmail(ctx, &api.ConfirmEmailRequest{Token: "token"})
+	s.Require().NoError(err)
+	s.Equal("new@example.com", resp.Email)
+	s.True(resp.Verified)
+	s.True(user.EmailVerified)
+	s.NotNil(user.EmailVerifiedAt)
+}
+
+func (s *AuthSuite) TestConfirmEmail_Error() {
+	ctx := context.Background()
+
+	s.storage.EXPECT().HashToken("expired").Return("expired_hash").Times(1)
+	s.repo.EXPECT().GetEmailVerificationToken(ctx, "expired_hash").Return(&model.EmailVerificationToken{
+		UserID:    123,
+		ExpiresIn: int32(time.Now().Add(-time.Minute).Unix()),
+	}, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).ConfirmEmail(ctx, &api.ConfirmEmailRequest{Token: "expired"})
+	s.Nil(resp)
+	s.EqualError(err, "token has expired")
+}
+
+func (s *AuthSuite) TestLogin_ByEmail() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "User@Example.com"}).Return(nil, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Email: "user@example.com", EmailStatus: model.EmailStatusVerified}).Return(user, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, user.ID).Return(nil, nil).Times(1)
+	s.expectSession(ctx, user.ID)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).Login(ctx, &api.LoginRequest{
+		Login:    "User@Example.com",
+		Password: "password",
+		Data:     []byte("data"),
+	})
+	s.Require().NoError(err)
+	s.Equal("access", resp.Access.Token)
+}
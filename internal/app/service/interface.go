@@ -4,30 +4,106 @@ import (
 	"context"
 	"github.com/sanches1984/gopkg-pg-orm/pager"
 	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
 	storage2 "github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
 	uuid "github.com/satori/go.uuid"
+	"time"
 )
 
 type Repository interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	GetUsers(ctx context.Context, filter model.UserFilter, pgr pager.Pager) (model.UserList, error)
 	GetUser(ctx context.Context, filter model.UserFilter) (*model.User, error)
 	CreateUser(ctx context.Context, user *model.User) error
 	UpdateUserPassword(ctx context.Context, user *model.User) error
+	UpdateUserEmail(ctx context.Context, user *model.User) error
 	DeleteUser(ctx context.Context, user *model.User) error
 	GetRefreshTokens(ctx context.Context, filter model.RefreshTokenFilter) (model.RefreshTokenList, error)
 	GetRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) (*model.RefreshToken, error)
+	LockRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) (*model.RefreshToken, error)
 	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
 	UpdateRefreshToken(ctx context.Context, token *model.RefreshToken) error
 	DeleteRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) error
+	GetRefreshTokenHistory(ctx context.Context, filter model.RefreshTokenHistoryFilter) (model.RefreshTokenHistoryList, error)
+	CreateRefreshTokenHistory(ctx context.Context, history *model.RefreshTokenHistory) error
+	GetPasswordHistory(ctx context.Context, filter model.PasswordHistoryFilter) (model.PasswordHistoryList, error)
+	CreatePasswordHistory(ctx context.Context, history *model.PasswordHistory) error
+	GetUserTOTP(ctx context.Context, userID int64) (*model.UserTOTP, error)
+	CreateUserTOTP(ctx context.Context, totp *model.UserTOTP) error
+	UpdateUserTOTP(ctx context.Context, totp *model.UserTOTP) error
+	UseTOTPStep(ctx context.Context, totp *model.UserTOTP, step int64) (bool, error)
+	DeleteUserTOTP(ctx context.Context, userID int64) error
+	GetRecoveryCodes(ctx context.Context, userID int64) (model.RecoveryCodeList, error)
+	CreateRecoveryCodes(ctx context.Context, userID int64, codes model.RecoveryCodeList) error
+	DeleteRecoveryCode(ctx context.Context, code *model.RecoveryCode) (bool, error)
+	GetWebAuthnCredentials(ctx context.Context, filter model.WebAuthnCredentialFilter) (model.WebAuthnCredentialList, error)
+	GetWebAuthnCredential(ctx context.Context, filter model.WebAuthnCredentialFilter) (*model.WebAuthnCredential, error)
+	CreateWebAuthnCredential(ctx context.Context, credential *model.WebAuthnCredential) error
+	UpdateWebAuthnCredential(ctx context.Context, credential *model.WebAuthnCredential) error
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error)
+	CreatePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) error
+	DeletePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) (bool, error)
+	DeletePasswordResetTokens(ctx context.Context, userID int64) error
+	GetEmailVerificationToken(ctx context.Context, tokenHash string) (*model.EmailVerificationToken, error)
+	CreateEmailVerificationToken(ctx context.Context, token *model.EmailVerificationToken) error
+	DeleteEmailVerificationTokens(ctx context.Context, userID int64) error
+	CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error
+	GetRoles(ctx context.Context, filter model.RoleFilter) (model.RoleList, error)
+	GetRole(ctx context.Context, name string) (*model.Role, error)
+	CreateRole(ctx context.Context, role *model.Role) error
+	UpdateRole(ctx context.Context, role *model.Role) error
+	DeleteRole(ctx context.Context, role *model.Role) error
+	GetPermissions(ctx context.Context, filter model.PermissionFilter) (model.PermissionList, error)
+	CreatePermission(ctx context.Context, permission *model.Permission) error
+	DeletePermission(ctx context.Context, permission *model.Permission) error
+	CreateUserRole(ctx context.Context, userRole *model.UserRole) error
+	DeleteUserRole(ctx context.Context, userRole *model.UserRole) error
+	GetServiceAccounts(ctx context.Context, filter model.ServiceAccountFilter) (model.ServiceAccountList, error)
+	GetServiceAccount(ctx context.Context, filter model.ServiceAccountFilter) (*model.ServiceAccount, error)
+	CreateServiceAccount(ctx context.Context, account *model.ServiceAccount) error
+	DeleteServiceAccount(ctx context.Context, account *model.ServiceAccount) error
+	GetAPIKeys(ctx context.Context, filter model.APIKeyFilter) (model.APIKeyList, error)
+	GetAPIKey(ctx context.Context, filter model.APIKeyFilter) (*model.APIKey, error)
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	UpdateAPIKey(ctx context.Context, key *model.APIKey) error
+	GetOAuthClients(ctx context.Context, filter model.OAuthClientFilter) (model.OAuthClientList, error)
+	GetOAuthClient(ctx context.Context, filter model.OAuthClientFilter) (*model.OAuthClient, error)
+	CreateOAuthClient(ctx context.Context, client *model.OAuthClient) error
+	UpdateOAuthClient(ctx context.Context, client *model.OAuthClient) error
+	DeleteOAuthClient(ctx context.Context, client *model.OAuthClient) error
 }
 
 type Storage interface {
-	DecodeToken(token string) (int64, uuid.UUID, error)
+	HashToken(token string) string
+	MatchToken(hash, token string) bool
+	DecodeToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error)
+	DecodeGrants(token string) (jwt.Grants, error)
+	CreateServiceToken(accountID int64, ttl time.Duration) (storage2.Token, error)
 	GetSessionData(token string) ([]byte, error)
 	GetSessionDataByUUID(sessionID uuid.UUID) ([]byte, error)
-	CreateSession(userID int64, userData []byte) (*storage2.Session, error)
-	RefreshSession(userID int64, sessionID uuid.UUID, userData []byte) (*storage2.Session, error)
+	CreateSession(userID int64, grants jwt.Grants, userData []byte) (*storage2.Session, error)
+	IssueSession(userID int64, sessionID uuid.UUID, grants jwt.Grants) (*storage2.Session, error)
+	RefreshSession(session *storage2.Session, userData []byte) error
 	UpdateSessionData(token string, userData []byte) error
 	DeleteSession(token string) error
 	DeleteSessionByUUID(sessionID uuid.UUID) error
+	CreateMFAChallenge(challenge storage2.MFAChallenge) (storage2.Token, error)
+	GetMFAChallenge(token string) (*storage2.MFAChallenge, error)
+	SpendMFAChallenge(token string) (bool, error)
+	CreateWebAuthnChallenge(challenge storage2.WebAuthnChallenge) (storage2.Token, error)
+	GetWebAuthnChallenge(token string) (*storage2.WebAuthnChallenge, error)
+	DeleteWebAuthnChallenge(token string) error
+}
+
+type Throttler interface {
+	Check(keys ...throttle.Key) (time.Duration, error)
+	Fail(keys ...throttle.Key) ([]throttle.Lockout, error)
+	Reset(keys ...throttle.Key) error
+	Lockouts(keys ...throttle.Key) ([]throttle.Lockout, error)
+}
+
+type Notifier interface {
+	Send(msg notifier.Message) error
 }
@@ -8,7 +8,8 @@ import (
 	"github.com/sanches1984/msa-auth/internal/app/model"
 	"github.com/sanches1984/msa-auth/pkg/errors"
 	api "github.com/sanches1984/msa-auth/proto/api"
-	"golang.org/x/sync/errgroup"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -18,13 +19,15 @@ type ManageService struct {
 	repo    Repository
 	storage Storage
 	logger  zerolog.Logger
+	options
 }
 
-func NewManageService(repo Repository, storage Storage, logger zerolog.Logger) *ManageService {
+func NewManageService(repo Repository, storage Storage, logger zerolog.Logger, opts ...Option) *ManageService {
 	return &ManageService{
 		repo:    repo,
 		storage: storage,
 		logger:  logger,
+		options: newOptions(opts),
 	}
 }
 
@@ -32,8 +35,19 @@ func (s *ManageService) CreateUser(ctx context.Context, r *api.CreateUserRequest
 	if r.GetLogin() == "" || r.GetPassword() == "" {
 		return nil, convert(errors.ErrBadRequest)
 	}
+	if err := s.checkPassword("password", r.GetLogin(), r.GetPassword()); err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Str("login", r.GetLogin()).Msg("password policy violated")
+		return nil, convert(err)
+	}
 	user := &model.User{Login: r.GetLogin()}
-	if err := user.SetHashByPassword(r.GetPassword()); err != nil {
+	if r.GetEmail() != "" {
+		email, err := normalizeEmail("email", r.GetEmail())
+		if err != nil {
+			return nil, convert(err)
+		}
+		user.SetEmail(email)
+	}
+	if err := user.SetHashByPassword(s.hasher, r.GetPassword()); err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't set password hash")
 		return nil, convert(err)
 	}
@@ -66,14 +80,7 @@ func (s *ManageService) DeleteUser(ctx context.Context, r *api.DeleteUserRequest
 		return nil, convert(err)
 	}
 
-	eg := errgroup.Group{}
-	for _, token := range tokens {
-		sessionID := token.SessionID
-		eg.Go(func() error {
-			return s.storage.DeleteSessionByUUID(sessionID)
-		})
-	}
-	if err := eg.Wait(); err != nil {
+	if err := deleteSessions(s.storage, tokens); err != nil {
 		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", r.GetUserId()).Msg("can't delete session")
 		return nil, convert(err)
 	}
@@ -93,9 +100,11 @@ func (s *ManageService) DeleteUser(ctx context.Context, r *api.DeleteUserRequest
 
 func (s *ManageService) GetUsers(ctx context.Context, r *api.GetUsersRequest) (*api.GetUsersResponse, error) {
 	filter := model.UserFilter{
-		ID:    r.GetUserId(),
-		Login: r.GetLogin(),
-		Order: model.UserOrder(int(r.GetOrder())),
+		ID:          r.GetUserId(),
+		Login:       r.GetLogin(),
+		Email:       strings.ToLower(strings.TrimSpace(r.GetEmail())),
+		EmailStatus: model.EmailStatus(int(r.GetEmailStatus())),
+		Order:       model.UserOrder(int(r.GetOrder())),
 	}
 	pgr := pager.NewPagerWithPageSize(r.GetPage(), r.GetPageSize())
 
@@ -108,10 +117,15 @@ func (s *ManageService) GetUsers(ctx context.Context, r *api.GetUsersRequest) (*
 	userList := make([]*api.User, 0, len(users))
 	for _, u := range users {
 		user := &api.User{
-			Id:      u.ID,
-			Login:   u.Login,
-			Created: u.Created.Format(time.RFC3339),
-			Updated: u.Updated.Format(time.RFC3339),
+			Id:            u.ID,
+			Login:         u.Login,
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Created:       u.Created.Format(time.RFC3339),
+			Updated:       u.Updated.Format(time.RFC3339),
+		}
+		if u.EmailVerifiedAt != nil {
+			user.EmailVerifiedAt = u.EmailVerifiedAt.Format(time.RFC3339)
 		}
 		if u.Deleted != nil {
 			user.Deleted = u.Deleted.Format(time.RFC3339)
@@ -122,3 +136,46 @@ func (s *ManageService) GetUsers(ctx context.Context, r *api.GetUsersRequest) (*
 	log.WithContext(ctx, s.logger).Info().Int("count", len(userList)).Msg("get user list")
 	return &api.GetUsersResponse{Users: userList}, nil
 }
+
+func (s *ManageService) GetLockouts(ctx context.Context, r *api.GetLockoutsRequest) (*api.GetLockoutsResponse, error) {
+	if s.throttler == nil {
+		return &api.GetLockoutsResponse{}, nil
+	}
+
+	lockouts, err := s.throttler.Lockouts(lockoutKeys(r.GetLogin(), r.GetIp())...)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get lockouts")
+		return nil, convert(err)
+	}
+
+	list := make([]*api.Lockout, 0, len(lockouts))
+	for _, l := range lockouts {
+		list = append(list, &api.Lockout{
+			Kind:       string(l.Kind),
+			Value:      l.Value,
+			Failures:   l.Failures,
+			RetryAfter: int32(math.Ceil(l.RetryAfter.Seconds())),
+			Locked:     l.Locked,
+		})
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int("count", len(list)).Msg("get lockouts")
+	return &api.GetLockoutsResponse{Lockouts: list}, nil
+}
+
+func (s *ManageService) ClearLockout(ctx context.Context, r *api.ClearLockoutRequest) (*api.ClearLockoutResponse, error) {
+	keys := lockoutKeys(r.GetLogin(), r.GetIp())
+	if len(keys) == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.throttler == nil {
+		return &api.ClearLockoutResponse{}, nil
+	}
+
+	if err := s.throttler.Reset(keys...); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Str("ip", r.GetIp()).Msg("can't clear lockout")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("login", r.GetLogin()).Str("ip", r.GetIp()).Msg("lockout cleared")
+	return &api.ClearLockoutResponse{Cleared: true}, nil
+}
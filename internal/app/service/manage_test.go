@@ -8,10 +8,14 @@ import (
 	"github.com/sanches1984/gopkg-pg-orm/pager"
 	"github.com/sanches1984/msa-auth/internal/app/model"
 	"github.com/sanches1984/msa-auth/internal/app/service/mocks"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
 	errs "github.com/sanches1984/msa-auth/pkg/errors"
 	api "github.com/sanches1984/msa-auth/proto/api"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"testing"
 	"time"
 )
@@ -19,16 +23,18 @@ import (
 type ManageSuite struct {
 	suite.Suite
 
-	ctrl    *gomock.Controller
-	repo    *mocks.MockRepository
-	storage *mocks.MockStorage
-	logger  zerolog.Logger
+	ctrl      *gomock.Controller
+	repo      *mocks.MockRepository
+	storage   *mocks.MockStorage
+	throttler *mocks.MockThrottler
+	logger    zerolog.Logger
 }
 
 func (s *ManageSuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
 	s.repo = mocks.NewMockRepository(s.ctrl)
 	s.storage = mocks.NewMockStorage(s.ctrl)
+	s.throttler = mocks.NewMockThrottler(s.ctrl)
 	s.logger = zerolog.Nop()
 }
 
@@ -50,7 +56,7 @@ func (s *ManageSuite) TestCreateUser_Success() {
 
 	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateUser(ctx, &api.CreateUserRequest{
 		Login:    "login",
-		Password: "password",
+		Password: "correct-horse",
 	})
 
 	s.NoError(err)
@@ -64,13 +70,33 @@ func (s *ManageSuite) TestCreateUser_Error() {
 
 	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateUser(ctx, &api.CreateUserRequest{
 		Login:    "login",
-		Password: "password",
+		Password: "correct-horse",
 	})
 
 	s.Nil(resp)
 	s.EqualError(err, repoErr.Error())
 }
 
+func (s *ManageSuite) TestCreateUser_PasswordPolicy() {
+	ctx := context.Background()
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateUser(ctx, &api.CreateUserRequest{
+		Login:    "login",
+		Password: "login1",
+	})
+
+	s.Nil(resp)
+	st := status.Convert(err)
+	s.Equal(codes.InvalidArgument, st.Code())
+	s.Require().Len(st.Details(), 1)
+	details, ok := st.Details()[0].(*errdetails.BadRequest)
+	s.Require().True(ok)
+	s.Require().Len(details.FieldViolations, 2)
+	s.Equal("password", details.FieldViolations[0].Field)
+	s.Equal("min_length: must be at least 8 characters long", details.FieldViolations[0].Description)
+	s.Equal("no_login: must not contain login", details.FieldViolations[1].Description)
+}
+
 func (s *ManageSuite) TestDeleteUser_Success() {
 	ctx := context.Background()
 	sessionID1 := uuid.NewV4()
@@ -132,6 +158,33 @@ func (s *ManageSuite) TestGetUsers_Success() {
 	}}, resp)
 }
 
+func (s *ManageSuite) TestGetUsers_ByEmail() {
+	ctx := context.Background()
+	now := time.Now()
+	users := model.UserList{
+		&model.User{ID: 1, Login: "login1", Email: "user@example.com", EmailVerified: true, EmailVerifiedAt: &now, Created: now, Updated: now},
+	}
+
+	s.repo.EXPECT().GetUsers(ctx, model.UserFilter{Email: "user@example.com", EmailStatus: model.EmailStatusVerified}, pager.NewPagerWithPageSize(0, 0)).Return(users, nil)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).GetUsers(ctx, &api.GetUsersRequest{
+		Email:       "User@Example.com",
+		EmailStatus: api.GetUsersRequest_VERIFIED,
+	})
+	s.NoError(err)
+	s.Equal(&api.GetUsersResponse{Users: []*api.User{
+		{
+			Id:              1,
+			Login:           "login1",
+			Email:           "user@example.com",
+			EmailVerified:   true,
+			EmailVerifiedAt: now.Format(time.RFC3339),
+			Created:         now.Format(time.RFC3339),
+			Updated:         now.Format(time.RFC3339),
+		},
+	}}, resp)
+}
+
 func (s *ManageSuite) TestGetUsers_Error() {
 	ctx := context.Background()
 	dbErr := errors.New("internal")
@@ -142,3 +195,37 @@ func (s *ManageSuite) TestGetUsers_Error() {
 	s.Nil(resp)
 	s.EqualError(err, dbErr.Error())
 }
+
+func (s *ManageSuite) TestGetLockouts_Success() {
+	ctx := context.Background()
+	s.throttler.EXPECT().Lockouts(throttle.LoginKey("login")).Return([]throttle.Lockout{
+		{Key: throttle.LoginKey("login"), Failures: 5, RetryAfter: 1500 * time.Millisecond, Locked: true},
+	}, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger, WithThrottler(s.throttler)).GetLockouts(ctx, &api.GetLockoutsRequest{Login: "login"})
+	s.NoError(err)
+	s.Require().Len(resp.Lockouts, 1)
+	s.Equal("login", resp.Lockouts[0].Kind)
+	s.Equal("login", resp.Lockouts[0].Value)
+	s.Equal(int64(5), resp.Lockouts[0].Failures)
+	s.Equal(int32(2), resp.Lockouts[0].RetryAfter)
+	s.True(resp.Lockouts[0].Locked)
+}
+
+func (s *ManageSuite) TestClearLockout_Success() {
+	ctx := context.Background()
+	s.throttler.EXPECT().Reset(throttle.LoginKey("login"), throttle.IPKey("127.0.0.1")).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger, WithThrottler(s.throttler)).ClearLockout(ctx, &api.ClearLockoutRequest{
+		Login: "login",
+		Ip:    "127.0.0.1",
+	})
+	s.NoError(err)
+	s.True(resp.Cleared)
+}
+
+func (s *ManageSuite) TestClearLockout_Error() {
+	resp, err := NewManageService(s.repo, s.storage, s.logger, WithThrottler(s.throttler)).ClearLockout(context.Background(), &api.ClearLockoutRequest{})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
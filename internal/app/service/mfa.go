@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/sanches1984/msa-auth/pkg/totp"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"strconv"
+	"time"
+)
+
+const (
+	mfaMethodTOTP     = "totp"
+	mfaMethodWebAuthn = "webauthn"
+)
+
+// totpSkew accepts codes of one previous and one next period to tolerate clock drift.
+const totpSkew = 1
+
+// EnrollTOTP generates new totp secret of the user, it's pending until confirmed by the first code.
+func (s *AuthService) EnrollTOTP(ctx context.Context, r *api.EnrollTOTPRequest) (*api.EnrollTOTPResponse, error) {
+	if r.GetToken() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.secretBox == nil {
+		return nil, convert(errors.ErrMFANotConfigured)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: userID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("user not found")
+		return nil, convert(errors.ErrUserNotFound)
+	}
+
+	current, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user totp")
+		return nil, convert(err)
+	} else if current != nil && current.Enabled {
+		return nil, convert(errors.ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't generate totp secret")
+		return nil, convert(err)
+	}
+	sealed, err := s.secretBox.Seal([]byte(secret), totpAdditionalData(userID))
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't encrypt totp secret")
+		return nil, convert(err)
+	}
+	if err := s.repo.CreateUserTOTP(ctx, &model.UserTOTP{UserID: userID, Secret: sealed}); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create user totp")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("totp enrolled")
+	return &api.EnrollTOTPResponse{
+		Secret: secret,
+		Uri:    totp.URI(s.totpIssuer, user.Login, secret),
+	}, nil
+}
+
+func (s *AuthService) ConfirmTOTP(ctx context.Context, r *api.ConfirmTOTPRequest) (*api.ConfirmTOTPResponse, error) {
+	if r.GetToken() == "" || r.GetCode() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.secretBox == nil {
+		return nil, convert(errors.ErrMFANotConfigured)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	userTOTP, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user totp")
+		return nil, convert(err)
+	} else if userTOTP == nil {
+		return nil, convert(errors.ErrMFANotEnabled)
+	} else if userTOTP.Enabled {
+		return nil, convert(errors.ErrMFAAlreadyEnabled)
+	}
+
+	if err := s.verifyTOTPCode(ctx, userTOTP, r.GetCode()); err != nil {
+		return nil, convert(err)
+	}
+	userTOTP.Enabled = true
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateUserTOTP(ctx, userTOTP); err != nil {
+			return err
+		}
+		return s.notify(ctx, s.repo, userID, notifier.KindMFAEnabled, map[string]string{"method": mfaMethodTOTP})
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't update user totp")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("totp enabled")
+	return &api.ConfirmTOTPResponse{Enabled: true}, nil
+}
+
+func (s *AuthService) DisableTOTP(ctx context.Context, r *api.DisableTOTPRequest) (*api.DisableTOTPResponse, error) {
+	if r.GetToken() == "" || r.GetCode() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.secretBox == nil {
+		return nil, convert(errors.ErrMFANotConfigured)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	userTOTP, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user totp")
+		return nil, convert(err)
+	} else if userTOTP == nil || !userTOTP.Enabled {
+		return nil, convert(errors.ErrMFANotEnabled)
+	}
+
+	if err := s.verifyTOTPCode(ctx, userTOTP, r.GetCode()); err != nil {
+		return nil, convert(err)
+	}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteUserTOTP(ctx, userID); err != nil {
+			return err
+		}
+		return s.notify(ctx, s.repo, userID, notifier.KindMFADisabled, map[string]string{"method": mfaMethodTOTP})
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't delete user totp")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("totp disabled")
+	return &api.DisableTOTPResponse{Disabled: true}, nil
+}
+
+// VerifyMFA exchanges mfa challenge of login and second factor code for session.
+func (s *AuthService) VerifyMFA(ctx context.Context, r *api.VerifyMFARequest) (*api.TokenResponse, error) {
+	if r.GetMfaToken() == "" || r.GetCode() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.secretBox == nil {
+		return nil, convert(errors.ErrMFANotConfigured)
+	}
+
+	challenge, err := s.storage.GetMFAChallenge(r.GetMfaToken())
+	if err == redis.ErrRecordNotFound {
+		return nil, convert(errors.ErrTokenInvalid)
+	} else if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get mfa challenge")
+		return nil, convert(err)
+	}
+	// the challenge is spent before the code is checked, so concurrent requests can't try more codes with it
+	if spent, err := s.storage.SpendMFAChallenge(r.GetMfaToken()); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", challenge.UserID).Msg("can't delete mfa challenge")
+		return nil, convert(err)
+	} else if !spent {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", challenge.UserID).Msg("mfa challenge already used")
+		return nil, convert(errors.ErrTokenInvalid)
+	}
+
+	userTOTP, err := s.repo.GetUserTOTP(ctx, challenge.UserID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", challenge.UserID).Msg("can't get user totp")
+		return nil, convert(err)
+	} else if userTOTP == nil || !userTOTP.Enabled {
+		return nil, convert(errors.ErrMFANotEnabled)
+	}
+
+	if err := s.verifyTOTPCode(ctx, userTOTP, r.GetCode()); err != nil {
+		return nil, convert(err)
+	}
+
+	resp, err := s.createSession(ctx, challenge.UserID, challenge.Data)
+	if err != nil {
+		return nil, convert(err)
+	}
+	return resp, nil
+}
+
+// mfaChallenge returns challenge for users with enabled second factor, nil means session can be created.
+func (s *AuthService) mfaChallenge(ctx context.Context, userID int64, data []byte) (*api.MFAChallenge, error) {
+	userTOTP, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if userTOTP == nil || !userTOTP.Enabled {
+		return nil, nil
+	}
+
+	token, err := s.storage.CreateMFAChallenge(storage.MFAChallenge{UserID: userID, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return &api.MFAChallenge{
+		Token:     token.Value,
+		ExpiresIn: token.ExpiresIn,
+		Methods:   []string{mfaMethodTOTP},
+	}, nil
+}
+
+// verifyTOTPCode checks the code and saves its step, so every code is accepted once even by concurrent requests.
+// Wrong and replayed codes are throttled per user.
+func (s *AuthService) verifyTOTPCode(ctx context.Context, userTOTP *model.UserTOTP, code string) error {
+	keys := []throttle.Key{throttle.MFAKey(userTOTP.UserID)}
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return err
+	}
+
+	secret, err := s.secretBox.Open(userTOTP.Secret, totpAdditionalData(userTOTP.UserID))
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userTOTP.UserID).Msg("can't decrypt totp secret")
+		return err
+	}
+
+	// the step is saved only if it's after the last used one, so replayed code is rejected like wrong one
+	var used bool
+	if step, ok := totp.Validate(string(secret), code, time.Now(), totpSkew); ok && step > userTOTP.LastUsedStep {
+		if used, err = s.repo.UseTOTPStep(ctx, userTOTP, step); err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userTOTP.UserID).Msg("can't update user totp")
+			return err
+		}
+	}
+	if !used {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userTOTP.UserID).Msg("incorrect totp code")
+		if s.failThrottle(ctx, keys) {
+			s.notifyLockout(ctx, userTOTP.UserID)
+		}
+		return errors.ErrIncorrectCode
+	}
+
+	s.resetThrottle(ctx, keys)
+	return nil
+}
+
+// totpAdditionalData binds encrypted secret to the user, so it can't be copied to another row.
+func totpAdditionalData(userID int64) []byte {
+	return []byte(strconv.FormatInt(userID, 10))
+}
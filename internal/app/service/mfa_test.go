@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+	"github.com/sanches1984/msa-auth/pkg/totp"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+func (s *AuthSuite) newUserTOTP(box *secretbox.Box, userID int64, enabled bool) (*model.UserTOTP, string) {
+	secret, err := totp.GenerateSecret()
+	s.Require().NoError(err)
+	sealed, err := box.Seal([]byte(secret), totpAdditionalData(userID))
+	s.Require().NoError(err)
+	return &model.UserTOTP{ID: 1, UserID: userID, Secret: sealed, Enabled: enabled}, secret
+}
+
+// expectTOTPStep expects step of the code to be saved, used is false when concurrent request saved it first.
+func (s *AuthSuite) expectTOTPStep(ctx context.Context, userTOTP *model.UserTOTP, used bool) {
+	s.repo.EXPECT().UseTOTPStep(ctx, userTOTP, gomock.Any()).DoAndReturn(func(ctx context.Context, t *model.UserTOTP, step int64) (bool, error) {
+		s.Greater(step, t.LastUsedStep)
+		if used {
+			t.LastUsedStep = step
+		}
+		return used, nil
+	}).Times(1)
+}
+
+func (s *AuthSuite) currentCode(secret string) string {
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	s.Require().NoError(err)
+	return code
+}
+
+func (s *AuthSuite) expectActiveSession(userID int64) {
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeAccess).Return(userID, uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("access").Return([]byte("data"), nil).Times(1)
+}
+
+func (s *AuthSuite) TestLogin_MFAChallenge() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(user, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, user.ID).Return(&model.UserTOTP{UserID: user.ID, Enabled: true}, nil).Times(1)
+	s.storage.EXPECT().CreateMFAChallenge(storage.MFAChallenge{UserID: user.ID, Data: []byte("data")}).
+		Return(storage.Token{Value: "challenge", ExpiresIn: 333}, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "password",
+		Data:     []byte("data"),
+	})
+	s.NoError(err)
+	s.Nil(resp.Access)
+	s.Nil(resp.Refresh)
+	s.Equal(&api.MFAChallenge{Token: "challenge", ExpiresIn: 333, Methods: []string{mfaMethodTOTP}}, resp.Mfa)
+}
+
+func (s *AuthSuite) TestEnrollTOTP_Success() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+
+	var created *model.UserTOTP
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(nil, nil).Times(1)
+	s.repo.EXPECT().CreateUserTOTP(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, t *model.UserTOTP) error {
+		created = t
+		return nil
+	}).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithTOTP("auth", box)).EnrollTOTP(ctx, &api.EnrollTOTPRequest{Token: "access"})
+	s.Require().NoError(err)
+	s.True(strings.HasPrefix(resp.Uri, "otpauth://totp/auth:login?"))
+	s.Contains(resp.Uri, "secret="+resp.Secret)
+
+	s.False(created.Enabled)
+	s.NotContains(created.Secret, resp.Secret)
+	secret, err := box.Open(created.Secret, totpAdditionalData(123))
+	s.NoError(err)
+	s.Equal(resp.Secret, string(secret))
+}
+
+func (s *AuthSuite) TestEnrollTOTP_Error() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).EnrollTOTP(ctx, &api.EnrollTOTPRequest{Token: "access"})
+	s.Nil(resp)
+	s.Equal(codes.FailedPrecondition, status.Code(err))
+
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(&model.UserTOTP{UserID: 123, Enabled: true}, nil).Times(1)
+
+	resp, err = NewAuthService(s.repo, s.storage, s.logger, WithTOTP("auth", box)).EnrollTOTP(ctx, &api.EnrollTOTPRequest{Token: "access"})
+	s.Nil(resp)
+	s.EqualError(err, "mfa is already enabled")
+	s.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func (s *AuthSuite) TestConfirmTOTP_Success() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+	userTOTP, secret := s.newUserTOTP(box, 123, false)
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	s.Require().NoError(err)
+
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(userTOTP, nil).Times(1)
+	s.expectTOTPStep(ctx, userTOTP, true)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().UpdateUserTOTP(ctx, userTOTP).DoAndReturn(func(ctx context.Context, t *model.UserTOTP) error {
+		s.True(t.Enabled)
+		s.Equal(step, t.LastUsedStep)
+		return nil
+	}).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithTOTP("auth", box)).ConfirmTOTP(ctx, &api.ConfirmTOTPRequest{
+		Token: "access",
+		Code:  code,
+	})
+	s.NoError(err)
+	s.True(resp.Enabled)
+}
+
+func (s *AuthSuite) TestDisableTOTP_Error() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+	userTOTP, _ := s.newUserTOTP(box, 123, true)
+
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(userTOTP, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithTOTP("auth", box)).DisableTOTP(ctx, &api.DisableTOTPRequest{
+		Token: "access",
+		Code:  "000000x",
+	})
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *AuthSuite) TestVerifyMFA_Success() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+	userTOTP, secret := s.newUserTOTP(box, 123, true)
+
+	s.storage.EXPECT().GetMFAChallenge("challenge").Return(&storage.MFAChallenge{UserID: 123, Data: []byte("data")}, nil).Times(1)
+	s.storage.EXPECT().SpendMFAChallenge("challenge").Return(true, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(userTOTP, nil).Times(1)
+	s.expectTOTPStep(ctx, userTOTP, true)
+	s.expectSession(ctx, 123)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithTOTP("auth", box)).VerifyMFA(ctx, &api.VerifyMFARequest{
+		MfaToken: "challenge",
+		Code:     s.currentCode(secret),
+	})
+	s.NoError(err)
+	s.Equal("access", resp.Access.Token)
+	s.Equal("refresh", resp.Refresh.Token)
+}
+
+func (s *AuthSuite) TestVerifyMFA_Error() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+	service := NewAuthService(s.repo, s.storage, s.logger, WithTOTP("auth", box))
+
+	// unknown or expired challenge
+	s.storage.EXPECT().GetMFAChallenge("expired").Return(nil, redis.ErrRecordNotFound).Times(1)
+	resp, err := service.VerifyMFA(ctx, &api.VerifyMFARequest{MfaToken: "expired", Code: "123456"})
+	s.Nil(resp)
+	s.EqualError(err, "invalid token")
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	// challenge spent by concurrent request
+	s.storage.EXPECT().GetMFAChallenge("spent").Return(&storage.MFAChallenge{UserID: 123}, nil).Times(1)
+	s.storage.EXPECT().SpendMFAChallenge("spent").Return(false, nil).Times(1)
+	resp, err = service.VerifyMFA(ctx, &api.VerifyMFARequest{MfaToken: "spent", Code: "123456"})
+	s.Nil(resp)
+	s.EqualError(err, "invalid token")
+
+	// code was already used
+	userTOTP, secret := s.newUserTOTP(box, 123, true)
+	userTOTP.LastUsedStep = totp.Step(time.Now()) + 1
+	s.storage.EXPECT().GetMFAChallenge("challenge").Return(&storage.MFAChallenge{UserID: 123}, nil).Times(1)
+	s.storage.EXPECT().SpendMFAChallenge("challenge").Return(true, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(userTOTP, nil).Times(1)
+	resp, err = service.VerifyMFA(ctx, &api.VerifyMFARequest{MfaToken: "challenge", Code: s.currentCode(secret)})
+	s.Nil(resp)
+	s.EqualError(err, "incorrect code")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	// the same code used by concurrent request
+	userTOTP, secret = s.newUserTOTP(box, 123, true)
+	s.storage.EXPECT().GetMFAChallenge("challenge").Return(&storage.MFAChallenge{UserID: 123}, nil).Times(1)
+	s.storage.EXPECT().SpendMFAChallenge("challenge").Return(true, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(userTOTP, nil).Times(1)
+	s.expectTOTPStep(ctx, userTOTP, false)
+	resp, err = service.VerifyMFA(ctx, &api.VerifyMFARequest{MfaToken: "challenge", Code: s.currentCode(secret)})
+	s.Nil(resp)
+	s.EqualError(err, "incorrect code")
+}
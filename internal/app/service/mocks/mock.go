@@ -7,11 +7,15 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	pager "github.com/sanches1984/gopkg-pg-orm/pager"
 	model "github.com/sanches1984/msa-auth/internal/app/model"
+	notifier "github.com/sanches1984/msa-auth/internal/pkg/notifier"
 	storage "github.com/sanches1984/msa-auth/internal/pkg/storage"
+	throttle "github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	jwt "github.com/sanches1984/msa-auth/pkg/jwt"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -38,148 +42,869 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CreateAPIKey mocks base method.
+func (m *MockRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockRepositoryMockRecorder) CreateAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockRepository)(nil).CreateAPIKey), ctx, key)
+}
+
+// CreateEmailVerificationToken mocks base method.
+func (m *MockRepository) CreateEmailVerificationToken(ctx context.Context, token *model.EmailVerificationToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEmailVerificationToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateEmailVerificationToken indicates an expected call of CreateEmailVerificationToken.
+func (mr *MockRepositoryMockRecorder) CreateEmailVerificationToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEmailVerificationToken", reflect.TypeOf((*MockRepository)(nil).CreateEmailVerificationToken), ctx, token)
+}
+
+// CreateOAuthClient mocks base method.
+func (m *MockRepository) CreateOAuthClient(ctx context.Context, client *model.OAuthClient) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthClient", ctx, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthClient indicates an expected call of CreateOAuthClient.
+func (mr *MockRepositoryMockRecorder) CreateOAuthClient(ctx, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthClient", reflect.TypeOf((*MockRepository)(nil).CreateOAuthClient), ctx, client)
+}
+
+// CreateOutboxMessage mocks base method.
+func (m *MockRepository) CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOutboxMessage", ctx, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOutboxMessage indicates an expected call of CreateOutboxMessage.
+func (mr *MockRepositoryMockRecorder) CreateOutboxMessage(ctx, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOutboxMessage", reflect.TypeOf((*MockRepository)(nil).CreateOutboxMessage), ctx, message)
+}
+
+// CreatePasswordHistory mocks base method.
+func (m *MockRepository) CreatePasswordHistory(ctx context.Context, history *model.PasswordHistory) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordHistory", ctx, history)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePasswordHistory indicates an expected call of CreatePasswordHistory.
+func (mr *MockRepositoryMockRecorder) CreatePasswordHistory(ctx, history interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordHistory", reflect.TypeOf((*MockRepository)(nil).CreatePasswordHistory), ctx, history)
+}
+
+// CreatePasswordResetToken mocks base method.
+func (m *MockRepository) CreatePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordResetToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePasswordResetToken indicates an expected call of CreatePasswordResetToken.
+func (mr *MockRepositoryMockRecorder) CreatePasswordResetToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetToken", reflect.TypeOf((*MockRepository)(nil).CreatePasswordResetToken), ctx, token)
+}
+
+// CreatePermission mocks base method.
+func (m *MockRepository) CreatePermission(ctx context.Context, permission *model.Permission) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePermission", ctx, permission)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePermission indicates an expected call of CreatePermission.
+func (mr *MockRepositoryMockRecorder) CreatePermission(ctx, permission interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePermission", reflect.TypeOf((*MockRepository)(nil).CreatePermission), ctx, permission)
+}
+
+// CreateRecoveryCodes mocks base method.
+func (m *MockRepository) CreateRecoveryCodes(ctx context.Context, userID int64, codes model.RecoveryCodeList) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRecoveryCodes", ctx, userID, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRecoveryCodes indicates an expected call of CreateRecoveryCodes.
+func (mr *MockRepositoryMockRecorder) CreateRecoveryCodes(ctx, userID, codes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecoveryCodes", reflect.TypeOf((*MockRepository)(nil).CreateRecoveryCodes), ctx, userID, codes)
+}
+
 // CreateRefreshToken mocks base method.
 func (m *MockRepository) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, token)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockRepositoryMockRecorder) CreateRefreshToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockRepository)(nil).CreateRefreshToken), ctx, token)
+}
+
+// CreateRefreshTokenHistory mocks base method.
+func (m *MockRepository) CreateRefreshTokenHistory(ctx context.Context, history *model.RefreshTokenHistory) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshTokenHistory", ctx, history)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshTokenHistory indicates an expected call of CreateRefreshTokenHistory.
+func (mr *MockRepositoryMockRecorder) CreateRefreshTokenHistory(ctx, history interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshTokenHistory", reflect.TypeOf((*MockRepository)(nil).CreateRefreshTokenHistory), ctx, history)
+}
+
+// CreateRole mocks base method.
+func (m *MockRepository) CreateRole(ctx context.Context, role *model.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRole", ctx, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRole indicates an expected call of CreateRole.
+func (mr *MockRepositoryMockRecorder) CreateRole(ctx, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRole", reflect.TypeOf((*MockRepository)(nil).CreateRole), ctx, role)
+}
+
+// CreateServiceAccount mocks base method.
+func (m *MockRepository) CreateServiceAccount(ctx context.Context, account *model.ServiceAccount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateServiceAccount", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateServiceAccount indicates an expected call of CreateServiceAccount.
+func (mr *MockRepositoryMockRecorder) CreateServiceAccount(ctx, account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateServiceAccount", reflect.TypeOf((*MockRepository)(nil).CreateServiceAccount), ctx, account)
+}
+
+// CreateUser mocks base method.
+func (m *MockRepository) CreateUser(ctx context.Context, user *model.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockRepositoryMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), ctx, user)
+}
+
+// CreateUserRole mocks base method.
+func (m *MockRepository) CreateUserRole(ctx context.Context, userRole *model.UserRole) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserRole", ctx, userRole)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUserRole indicates an expected call of CreateUserRole.
+func (mr *MockRepositoryMockRecorder) CreateUserRole(ctx, userRole interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserRole", reflect.TypeOf((*MockRepository)(nil).CreateUserRole), ctx, userRole)
+}
+
+// CreateUserTOTP mocks base method.
+func (m *MockRepository) CreateUserTOTP(ctx context.Context, totp *model.UserTOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserTOTP", ctx, totp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUserTOTP indicates an expected call of CreateUserTOTP.
+func (mr *MockRepositoryMockRecorder) CreateUserTOTP(ctx, totp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserTOTP", reflect.TypeOf((*MockRepository)(nil).CreateUserTOTP), ctx, totp)
+}
+
+// CreateWebAuthnCredential mocks base method.
+func (m *MockRepository) CreateWebAuthnCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebAuthnCredential", ctx, credential)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebAuthnCredential indicates an expected call of CreateWebAuthnCredential.
+func (mr *MockRepositoryMockRecorder) CreateWebAuthnCredential(ctx, credential interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebAuthnCredential", reflect.TypeOf((*MockRepository)(nil).CreateWebAuthnCredential), ctx, credential)
+}
+
+// DeleteEmailVerificationTokens mocks base method.
+func (m *MockRepository) DeleteEmailVerificationTokens(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEmailVerificationTokens", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEmailVerificationTokens indicates an expected call of DeleteEmailVerificationTokens.
+func (mr *MockRepositoryMockRecorder) DeleteEmailVerificationTokens(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEmailVerificationTokens", reflect.TypeOf((*MockRepository)(nil).DeleteEmailVerificationTokens), ctx, userID)
+}
+
+// DeleteOAuthClient mocks base method.
+func (m *MockRepository) DeleteOAuthClient(ctx context.Context, client *model.OAuthClient) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthClient", ctx, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthClient indicates an expected call of DeleteOAuthClient.
+func (mr *MockRepositoryMockRecorder) DeleteOAuthClient(ctx, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthClient", reflect.TypeOf((*MockRepository)(nil).DeleteOAuthClient), ctx, client)
+}
+
+// DeletePasswordResetToken mocks base method.
+func (m *MockRepository) DeletePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePasswordResetToken", ctx, token)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePasswordResetToken indicates an expected call of DeletePasswordResetToken.
+func (mr *MockRepositoryMockRecorder) DeletePasswordResetToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePasswordResetToken", reflect.TypeOf((*MockRepository)(nil).DeletePasswordResetToken), ctx, token)
+}
+
+// DeletePasswordResetTokens mocks base method.
+func (m *MockRepository) DeletePasswordResetTokens(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePasswordResetTokens", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePasswordResetTokens indicates an expected call of DeletePasswordResetTokens.
+func (mr *MockRepositoryMockRecorder) DeletePasswordResetTokens(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePasswordResetTokens", reflect.TypeOf((*MockRepository)(nil).DeletePasswordResetTokens), ctx, userID)
+}
+
+// DeletePermission mocks base method.
+func (m *MockRepository) DeletePermission(ctx context.Context, permission *model.Permission) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePermission", ctx, permission)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePermission indicates an expected call of DeletePermission.
+func (mr *MockRepositoryMockRecorder) DeletePermission(ctx, permission interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePermission", reflect.TypeOf((*MockRepository)(nil).DeletePermission), ctx, permission)
+}
+
+// DeleteRecoveryCode mocks base method.
+func (m *MockRepository) DeleteRecoveryCode(ctx context.Context, code *model.RecoveryCode) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRecoveryCode", ctx, code)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRecoveryCode indicates an expected call of DeleteRecoveryCode.
+func (mr *MockRepositoryMockRecorder) DeleteRecoveryCode(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecoveryCode", reflect.TypeOf((*MockRepository)(nil).DeleteRecoveryCode), ctx, code)
+}
+
+// DeleteRefreshToken mocks base method.
+func (m *MockRepository) DeleteRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRefreshToken", ctx, filter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRefreshToken indicates an expected call of DeleteRefreshToken.
+func (mr *MockRepositoryMockRecorder) DeleteRefreshToken(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRefreshToken", reflect.TypeOf((*MockRepository)(nil).DeleteRefreshToken), ctx, filter)
+}
+
+// DeleteRole mocks base method.
+func (m *MockRepository) DeleteRole(ctx context.Context, role *model.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRole", ctx, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRole indicates an expected call of DeleteRole.
+func (mr *MockRepositoryMockRecorder) DeleteRole(ctx, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRole", reflect.TypeOf((*MockRepository)(nil).DeleteRole), ctx, role)
+}
+
+// DeleteServiceAccount mocks base method.
+func (m *MockRepository) DeleteServiceAccount(ctx context.Context, account *model.ServiceAccount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteServiceAccount", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteServiceAccount indicates an expected call of DeleteServiceAccount.
+func (mr *MockRepositoryMockRecorder) DeleteServiceAccount(ctx, account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteServiceAccount", reflect.TypeOf((*MockRepository)(nil).DeleteServiceAccount), ctx, account)
+}
+
+// DeleteUser mocks base method.
+func (m *MockRepository) DeleteUser(ctx context.Context, user *model.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockRepositoryMockRecorder) DeleteUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepository)(nil).DeleteUser), ctx, user)
+}
+
+// DeleteUserRole mocks base method.
+func (m *MockRepository) DeleteUserRole(ctx context.Context, userRole *model.UserRole) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserRole", ctx, userRole)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserRole indicates an expected call of DeleteUserRole.
+func (mr *MockRepositoryMockRecorder) DeleteUserRole(ctx, userRole interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserRole", reflect.TypeOf((*MockRepository)(nil).DeleteUserRole), ctx, userRole)
+}
+
+// DeleteUserTOTP mocks base method.
+func (m *MockRepository) DeleteUserTOTP(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserTOTP indicates an expected call of DeleteUserTOTP.
+func (mr *MockRepositoryMockRecorder) DeleteUserTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserTOTP", reflect.TypeOf((*MockRepository)(nil).DeleteUserTOTP), ctx, userID)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockRepository) GetAPIKey(ctx context.Context, filter model.APIKeyFilter) (*model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, filter)
+	ret0, _ := ret[0].(*model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockRepositoryMockRecorder) GetAPIKey(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockRepository)(nil).GetAPIKey), ctx, filter)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockRepository) GetAPIKeys(ctx context.Context, filter model.APIKeyFilter) (model.APIKeyList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx, filter)
+	ret0, _ := ret[0].(model.APIKeyList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockRepositoryMockRecorder) GetAPIKeys(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockRepository)(nil).GetAPIKeys), ctx, filter)
+}
+
+// GetEmailVerificationToken mocks base method.
+func (m *MockRepository) GetEmailVerificationToken(ctx context.Context, tokenHash string) (*model.EmailVerificationToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailVerificationToken", ctx, tokenHash)
+	ret0, _ := ret[0].(*model.EmailVerificationToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailVerificationToken indicates an expected call of GetEmailVerificationToken.
+func (mr *MockRepositoryMockRecorder) GetEmailVerificationToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailVerificationToken", reflect.TypeOf((*MockRepository)(nil).GetEmailVerificationToken), ctx, tokenHash)
+}
+
+// GetOAuthClient mocks base method.
+func (m *MockRepository) GetOAuthClient(ctx context.Context, filter model.OAuthClientFilter) (*model.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClient", ctx, filter)
+	ret0, _ := ret[0].(*model.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClient indicates an expected call of GetOAuthClient.
+func (mr *MockRepositoryMockRecorder) GetOAuthClient(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClient", reflect.TypeOf((*MockRepository)(nil).GetOAuthClient), ctx, filter)
+}
+
+// GetOAuthClients mocks base method.
+func (m *MockRepository) GetOAuthClients(ctx context.Context, filter model.OAuthClientFilter) (model.OAuthClientList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClients", ctx, filter)
+	ret0, _ := ret[0].(model.OAuthClientList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClients indicates an expected call of GetOAuthClients.
+func (mr *MockRepositoryMockRecorder) GetOAuthClients(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClients", reflect.TypeOf((*MockRepository)(nil).GetOAuthClients), ctx, filter)
+}
+
+// GetPasswordHistory mocks base method.
+func (m *MockRepository) GetPasswordHistory(ctx context.Context, filter model.PasswordHistoryFilter) (model.PasswordHistoryList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistory", ctx, filter)
+	ret0, _ := ret[0].(model.PasswordHistoryList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistory indicates an expected call of GetPasswordHistory.
+func (mr *MockRepositoryMockRecorder) GetPasswordHistory(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistory", reflect.TypeOf((*MockRepository)(nil).GetPasswordHistory), ctx, filter)
+}
+
+// GetPasswordResetToken mocks base method.
+func (m *MockRepository) GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordResetToken", ctx, tokenHash)
+	ret0, _ := ret[0].(*model.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordResetToken indicates an expected call of GetPasswordResetToken.
+func (mr *MockRepositoryMockRecorder) GetPasswordResetToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordResetToken", reflect.TypeOf((*MockRepository)(nil).GetPasswordResetToken), ctx, tokenHash)
+}
+
+// GetPermissions mocks base method.
+func (m *MockRepository) GetPermissions(ctx context.Context, filter model.PermissionFilter) (model.PermissionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPermissions", ctx, filter)
+	ret0, _ := ret[0].(model.PermissionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPermissions indicates an expected call of GetPermissions.
+func (mr *MockRepositoryMockRecorder) GetPermissions(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPermissions", reflect.TypeOf((*MockRepository)(nil).GetPermissions), ctx, filter)
+}
+
+// GetRecoveryCodes mocks base method.
+func (m *MockRepository) GetRecoveryCodes(ctx context.Context, userID int64) (model.RecoveryCodeList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecoveryCodes", ctx, userID)
+	ret0, _ := ret[0].(model.RecoveryCodeList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecoveryCodes indicates an expected call of GetRecoveryCodes.
+func (mr *MockRepositoryMockRecorder) GetRecoveryCodes(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecoveryCodes", reflect.TypeOf((*MockRepository)(nil).GetRecoveryCodes), ctx, userID)
+}
+
+// GetRefreshToken mocks base method.
+func (m *MockRepository) GetRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) (*model.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshToken", ctx, filter)
+	ret0, _ := ret[0].(*model.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshToken indicates an expected call of GetRefreshToken.
+func (mr *MockRepositoryMockRecorder) GetRefreshToken(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockRepository)(nil).GetRefreshToken), ctx, filter)
+}
+
+// GetRefreshTokenHistory mocks base method.
+func (m *MockRepository) GetRefreshTokenHistory(ctx context.Context, filter model.RefreshTokenHistoryFilter) (model.RefreshTokenHistoryList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshTokenHistory", ctx, filter)
+	ret0, _ := ret[0].(model.RefreshTokenHistoryList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshTokenHistory indicates an expected call of GetRefreshTokenHistory.
+func (mr *MockRepositoryMockRecorder) GetRefreshTokenHistory(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokenHistory", reflect.TypeOf((*MockRepository)(nil).GetRefreshTokenHistory), ctx, filter)
+}
+
+// GetRefreshTokens mocks base method.
+func (m *MockRepository) GetRefreshTokens(ctx context.Context, filter model.RefreshTokenFilter) (model.RefreshTokenList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshTokens", ctx, filter)
+	ret0, _ := ret[0].(model.RefreshTokenList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshTokens indicates an expected call of GetRefreshTokens.
+func (mr *MockRepositoryMockRecorder) GetRefreshTokens(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokens", reflect.TypeOf((*MockRepository)(nil).GetRefreshTokens), ctx, filter)
+}
+
+// GetRole mocks base method.
+func (m *MockRepository) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRole", ctx, name)
+	ret0, _ := ret[0].(*model.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRole indicates an expected call of GetRole.
+func (mr *MockRepositoryMockRecorder) GetRole(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRole", reflect.TypeOf((*MockRepository)(nil).GetRole), ctx, name)
+}
+
+// GetRoles mocks base method.
+func (m *MockRepository) GetRoles(ctx context.Context, filter model.RoleFilter) (model.RoleList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoles", ctx, filter)
+	ret0, _ := ret[0].(model.RoleList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoles indicates an expected call of GetRoles.
+func (mr *MockRepositoryMockRecorder) GetRoles(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoles", reflect.TypeOf((*MockRepository)(nil).GetRoles), ctx, filter)
+}
+
+// GetServiceAccount mocks base method.
+func (m *MockRepository) GetServiceAccount(ctx context.Context, filter model.ServiceAccountFilter) (*model.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServiceAccount", ctx, filter)
+	ret0, _ := ret[0].(*model.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServiceAccount indicates an expected call of GetServiceAccount.
+func (mr *MockRepositoryMockRecorder) GetServiceAccount(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServiceAccount", reflect.TypeOf((*MockRepository)(nil).GetServiceAccount), ctx, filter)
+}
+
+// GetServiceAccounts mocks base method.
+func (m *MockRepository) GetServiceAccounts(ctx context.Context, filter model.ServiceAccountFilter) (model.ServiceAccountList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServiceAccounts", ctx, filter)
+	ret0, _ := ret[0].(model.ServiceAccountList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServiceAccounts indicates an expected call of GetServiceAccounts.
+func (mr *MockRepositoryMockRecorder) GetServiceAccounts(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServiceAccounts", reflect.TypeOf((*MockRepository)(nil).GetServiceAccounts), ctx, filter)
+}
+
+// GetUser mocks base method.
+func (m *MockRepository) GetUser(ctx context.Context, filter model.UserFilter) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, filter)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockRepositoryMockRecorder) GetUser(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), ctx, filter)
+}
+
+// GetUserTOTP mocks base method.
+func (m *MockRepository) GetUserTOTP(ctx context.Context, userID int64) (*model.UserTOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTOTP", ctx, userID)
+	ret0, _ := ret[0].(*model.UserTOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTOTP indicates an expected call of GetUserTOTP.
+func (mr *MockRepositoryMockRecorder) GetUserTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTOTP", reflect.TypeOf((*MockRepository)(nil).GetUserTOTP), ctx, userID)
+}
+
+// GetUsers mocks base method.
+func (m *MockRepository) GetUsers(ctx context.Context, filter model.UserFilter, pgr pager.Pager) (model.UserList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", ctx, filter, pgr)
+	ret0, _ := ret[0].(model.UserList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockRepositoryMockRecorder) GetUsers(ctx, filter, pgr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockRepository)(nil).GetUsers), ctx, filter, pgr)
+}
+
+// GetWebAuthnCredential mocks base method.
+func (m *MockRepository) GetWebAuthnCredential(ctx context.Context, filter model.WebAuthnCredentialFilter) (*model.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebAuthnCredential", ctx, filter)
+	ret0, _ := ret[0].(*model.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebAuthnCredential indicates an expected call of GetWebAuthnCredential.
+func (mr *MockRepositoryMockRecorder) GetWebAuthnCredential(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebAuthnCredential", reflect.TypeOf((*MockRepository)(nil).GetWebAuthnCredential), ctx, filter)
+}
+
+// GetWebAuthnCredentials mocks base method.
+func (m *MockRepository) GetWebAuthnCredentials(ctx context.Context, filter model.WebAuthnCredentialFilter) (model.WebAuthnCredentialList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebAuthnCredentials", ctx, filter)
+	ret0, _ := ret[0].(model.WebAuthnCredentialList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebAuthnCredentials indicates an expected call of GetWebAuthnCredentials.
+func (mr *MockRepositoryMockRecorder) GetWebAuthnCredentials(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebAuthnCredentials", reflect.TypeOf((*MockRepository)(nil).GetWebAuthnCredentials), ctx, filter)
+}
+
+// LockRefreshToken mocks base method.
+func (m *MockRepository) LockRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) (*model.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockRefreshToken", ctx, filter)
+	ret0, _ := ret[0].(*model.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// CreateRefreshToken indicates an expected call of CreateRefreshToken.
-func (mr *MockRepositoryMockRecorder) CreateRefreshToken(ctx, token interface{}) *gomock.Call {
+// LockRefreshToken indicates an expected call of LockRefreshToken.
+func (mr *MockRepositoryMockRecorder) LockRefreshToken(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockRepository)(nil).CreateRefreshToken), ctx, token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockRefreshToken", reflect.TypeOf((*MockRepository)(nil).LockRefreshToken), ctx, filter)
 }
 
-// CreateUser mocks base method.
-func (m *MockRepository) CreateUser(ctx context.Context, user *model.User) error {
+// UpdateAPIKey mocks base method.
+func (m *MockRepository) UpdateAPIKey(ctx context.Context, key *model.APIKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	ret := m.ctrl.Call(m, "UpdateAPIKey", ctx, key)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockRepositoryMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+// UpdateAPIKey indicates an expected call of UpdateAPIKey.
+func (mr *MockRepositoryMockRecorder) UpdateAPIKey(ctx, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAPIKey", reflect.TypeOf((*MockRepository)(nil).UpdateAPIKey), ctx, key)
 }
 
-// DeleteRefreshToken mocks base method.
-func (m *MockRepository) DeleteRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) error {
+// UpdateOAuthClient mocks base method.
+func (m *MockRepository) UpdateOAuthClient(ctx context.Context, client *model.OAuthClient) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteRefreshToken", ctx, filter)
+	ret := m.ctrl.Call(m, "UpdateOAuthClient", ctx, client)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteRefreshToken indicates an expected call of DeleteRefreshToken.
-func (mr *MockRepositoryMockRecorder) DeleteRefreshToken(ctx, filter interface{}) *gomock.Call {
+// UpdateOAuthClient indicates an expected call of UpdateOAuthClient.
+func (mr *MockRepositoryMockRecorder) UpdateOAuthClient(ctx, client interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRefreshToken", reflect.TypeOf((*MockRepository)(nil).DeleteRefreshToken), ctx, filter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOAuthClient", reflect.TypeOf((*MockRepository)(nil).UpdateOAuthClient), ctx, client)
 }
 
-// DeleteUser mocks base method.
-func (m *MockRepository) DeleteUser(ctx context.Context, user *model.User) error {
+// UpdateRefreshToken mocks base method.
+func (m *MockRepository) UpdateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", ctx, user)
+	ret := m.ctrl.Call(m, "UpdateRefreshToken", ctx, token)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockRepositoryMockRecorder) DeleteUser(ctx, user interface{}) *gomock.Call {
+// UpdateRefreshToken indicates an expected call of UpdateRefreshToken.
+func (mr *MockRepositoryMockRecorder) UpdateRefreshToken(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepository)(nil).DeleteUser), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRefreshToken", reflect.TypeOf((*MockRepository)(nil).UpdateRefreshToken), ctx, token)
 }
 
-// GetRefreshToken mocks base method.
-func (m *MockRepository) GetRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) (*model.RefreshToken, error) {
+// UpdateRole mocks base method.
+func (m *MockRepository) UpdateRole(ctx context.Context, role *model.Role) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetRefreshToken", ctx, filter)
-	ret0, _ := ret[0].(*model.RefreshToken)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "UpdateRole", ctx, role)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetRefreshToken indicates an expected call of GetRefreshToken.
-func (mr *MockRepositoryMockRecorder) GetRefreshToken(ctx, filter interface{}) *gomock.Call {
+// UpdateRole indicates an expected call of UpdateRole.
+func (mr *MockRepositoryMockRecorder) UpdateRole(ctx, role interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockRepository)(nil).GetRefreshToken), ctx, filter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRole", reflect.TypeOf((*MockRepository)(nil).UpdateRole), ctx, role)
 }
 
-// GetRefreshTokens mocks base method.
-func (m *MockRepository) GetRefreshTokens(ctx context.Context, filter model.RefreshTokenFilter) (model.RefreshTokenList, error) {
+// UpdateUserEmail mocks base method.
+func (m *MockRepository) UpdateUserEmail(ctx context.Context, user *model.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetRefreshTokens", ctx, filter)
-	ret0, _ := ret[0].(model.RefreshTokenList)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "UpdateUserEmail", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetRefreshTokens indicates an expected call of GetRefreshTokens.
-func (mr *MockRepositoryMockRecorder) GetRefreshTokens(ctx, filter interface{}) *gomock.Call {
+// UpdateUserEmail indicates an expected call of UpdateUserEmail.
+func (mr *MockRepositoryMockRecorder) UpdateUserEmail(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokens", reflect.TypeOf((*MockRepository)(nil).GetRefreshTokens), ctx, filter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserEmail", reflect.TypeOf((*MockRepository)(nil).UpdateUserEmail), ctx, user)
 }
 
-// GetUser mocks base method.
-func (m *MockRepository) GetUser(ctx context.Context, filter model.UserFilter) (*model.User, error) {
+// UpdateUserPassword mocks base method.
+func (m *MockRepository) UpdateUserPassword(ctx context.Context, user *model.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", ctx, filter)
-	ret0, _ := ret[0].(*model.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockRepositoryMockRecorder) GetUser(ctx, filter interface{}) *gomock.Call {
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockRepositoryMockRecorder) UpdateUserPassword(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), ctx, filter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockRepository)(nil).UpdateUserPassword), ctx, user)
 }
 
-// GetUsers mocks base method.
-func (m *MockRepository) GetUsers(ctx context.Context, filter model.UserFilter, pgr pager.Pager) (model.UserList, error) {
+// UpdateUserTOTP mocks base method.
+func (m *MockRepository) UpdateUserTOTP(ctx context.Context, totp *model.UserTOTP) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", ctx, filter, pgr)
-	ret0, _ := ret[0].(model.UserList)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "UpdateUserTOTP", ctx, totp)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetUsers indicates an expected call of GetUsers.
-func (mr *MockRepositoryMockRecorder) GetUsers(ctx, filter, pgr interface{}) *gomock.Call {
+// UpdateUserTOTP indicates an expected call of UpdateUserTOTP.
+func (mr *MockRepositoryMockRecorder) UpdateUserTOTP(ctx, totp interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockRepository)(nil).GetUsers), ctx, filter, pgr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTOTP", reflect.TypeOf((*MockRepository)(nil).UpdateUserTOTP), ctx, totp)
 }
 
-// UpdateRefreshToken mocks base method.
-func (m *MockRepository) UpdateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+// UpdateWebAuthnCredential mocks base method.
+func (m *MockRepository) UpdateWebAuthnCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateRefreshToken", ctx, token)
+	ret := m.ctrl.Call(m, "UpdateWebAuthnCredential", ctx, credential)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateRefreshToken indicates an expected call of UpdateRefreshToken.
-func (mr *MockRepositoryMockRecorder) UpdateRefreshToken(ctx, token interface{}) *gomock.Call {
+// UpdateWebAuthnCredential indicates an expected call of UpdateWebAuthnCredential.
+func (mr *MockRepositoryMockRecorder) UpdateWebAuthnCredential(ctx, credential interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRefreshToken", reflect.TypeOf((*MockRepository)(nil).UpdateRefreshToken), ctx, token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebAuthnCredential", reflect.TypeOf((*MockRepository)(nil).UpdateWebAuthnCredential), ctx, credential)
 }
 
-// UpdateUserPassword mocks base method.
-func (m *MockRepository) UpdateUserPassword(ctx context.Context, user *model.User) error {
+// UseTOTPStep mocks base method.
+func (m *MockRepository) UseTOTPStep(ctx context.Context, totp *model.UserTOTP, step int64) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, user)
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, totp, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockRepositoryMockRecorder) UseTOTPStep(ctx, totp, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockRepository)(nil).UseTOTPStep), ctx, totp, step)
+}
+
+// WithTransaction mocks base method.
+func (m *MockRepository) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTransaction", ctx, fn)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateUserPassword indicates an expected call of UpdateUserPassword.
-func (mr *MockRepositoryMockRecorder) UpdateUserPassword(ctx, user interface{}) *gomock.Call {
+// WithTransaction indicates an expected call of WithTransaction.
+func (mr *MockRepositoryMockRecorder) WithTransaction(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockRepository)(nil).UpdateUserPassword), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTransaction", reflect.TypeOf((*MockRepository)(nil).WithTransaction), ctx, fn)
 }
 
 // MockStorage is a mock of Storage interface.
@@ -205,25 +930,85 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// CreateMFAChallenge mocks base method.
+func (m *MockStorage) CreateMFAChallenge(challenge storage.MFAChallenge) (storage.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMFAChallenge", challenge)
+	ret0, _ := ret[0].(storage.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMFAChallenge indicates an expected call of CreateMFAChallenge.
+func (mr *MockStorageMockRecorder) CreateMFAChallenge(challenge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMFAChallenge", reflect.TypeOf((*MockStorage)(nil).CreateMFAChallenge), challenge)
+}
+
+// CreateServiceToken mocks base method.
+func (m *MockStorage) CreateServiceToken(accountID int64, ttl time.Duration) (storage.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateServiceToken", accountID, ttl)
+	ret0, _ := ret[0].(storage.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateServiceToken indicates an expected call of CreateServiceToken.
+func (mr *MockStorageMockRecorder) CreateServiceToken(accountID, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateServiceToken", reflect.TypeOf((*MockStorage)(nil).CreateServiceToken), accountID, ttl)
+}
+
 // CreateSession mocks base method.
-func (m *MockStorage) CreateSession(userID int64, userData []byte) (*storage.Session, error) {
+func (m *MockStorage) CreateSession(userID int64, grants jwt.Grants, userData []byte) (*storage.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSession", userID, userData)
+	ret := m.ctrl.Call(m, "CreateSession", userID, grants, userData)
 	ret0, _ := ret[0].(*storage.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateSession indicates an expected call of CreateSession.
-func (mr *MockStorageMockRecorder) CreateSession(userID, userData interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) CreateSession(userID, grants, userData interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStorage)(nil).CreateSession), userID, grants, userData)
+}
+
+// CreateWebAuthnChallenge mocks base method.
+func (m *MockStorage) CreateWebAuthnChallenge(challenge storage.WebAuthnChallenge) (storage.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebAuthnChallenge", challenge)
+	ret0, _ := ret[0].(storage.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebAuthnChallenge indicates an expected call of CreateWebAuthnChallenge.
+func (mr *MockStorageMockRecorder) CreateWebAuthnChallenge(challenge interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStorage)(nil).CreateSession), userID, userData)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebAuthnChallenge", reflect.TypeOf((*MockStorage)(nil).CreateWebAuthnChallenge), challenge)
+}
+
+// DecodeGrants mocks base method.
+func (m *MockStorage) DecodeGrants(token string) (jwt.Grants, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecodeGrants", token)
+	ret0, _ := ret[0].(jwt.Grants)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecodeGrants indicates an expected call of DecodeGrants.
+func (mr *MockStorageMockRecorder) DecodeGrants(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeGrants", reflect.TypeOf((*MockStorage)(nil).DecodeGrants), token)
 }
 
 // DecodeToken mocks base method.
-func (m *MockStorage) DecodeToken(token string) (int64, uuid.UUID, error) {
+func (m *MockStorage) DecodeToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DecodeToken", token)
+	ret := m.ctrl.Call(m, "DecodeToken", token, typ)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(uuid.UUID)
 	ret2, _ := ret[2].(error)
@@ -231,9 +1016,9 @@ func (m *MockStorage) DecodeToken(token string) (int64, uuid.UUID, error) {
 }
 
 // DecodeToken indicates an expected call of DecodeToken.
-func (mr *MockStorageMockRecorder) DecodeToken(token interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) DecodeToken(token, typ interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeToken", reflect.TypeOf((*MockStorage)(nil).DecodeToken), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeToken", reflect.TypeOf((*MockStorage)(nil).DecodeToken), token, typ)
 }
 
 // DeleteSession mocks base method.
@@ -264,6 +1049,35 @@ func (mr *MockStorageMockRecorder) DeleteSessionByUUID(sessionID interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSessionByUUID", reflect.TypeOf((*MockStorage)(nil).DeleteSessionByUUID), sessionID)
 }
 
+// DeleteWebAuthnChallenge mocks base method.
+func (m *MockStorage) DeleteWebAuthnChallenge(token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebAuthnChallenge", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebAuthnChallenge indicates an expected call of DeleteWebAuthnChallenge.
+func (mr *MockStorageMockRecorder) DeleteWebAuthnChallenge(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebAuthnChallenge", reflect.TypeOf((*MockStorage)(nil).DeleteWebAuthnChallenge), token)
+}
+
+// GetMFAChallenge mocks base method.
+func (m *MockStorage) GetMFAChallenge(token string) (*storage.MFAChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMFAChallenge", token)
+	ret0, _ := ret[0].(*storage.MFAChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMFAChallenge indicates an expected call of GetMFAChallenge.
+func (mr *MockStorageMockRecorder) GetMFAChallenge(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMFAChallenge", reflect.TypeOf((*MockStorage)(nil).GetMFAChallenge), token)
+}
+
 // GetSessionData mocks base method.
 func (m *MockStorage) GetSessionData(token string) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -294,19 +1108,91 @@ func (mr *MockStorageMockRecorder) GetSessionDataByUUID(sessionID interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionDataByUUID", reflect.TypeOf((*MockStorage)(nil).GetSessionDataByUUID), sessionID)
 }
 
-// RefreshSession mocks base method.
-func (m *MockStorage) RefreshSession(userID int64, sessionID uuid.UUID, userData []byte) (*storage.Session, error) {
+// GetWebAuthnChallenge mocks base method.
+func (m *MockStorage) GetWebAuthnChallenge(token string) (*storage.WebAuthnChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebAuthnChallenge", token)
+	ret0, _ := ret[0].(*storage.WebAuthnChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebAuthnChallenge indicates an expected call of GetWebAuthnChallenge.
+func (mr *MockStorageMockRecorder) GetWebAuthnChallenge(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebAuthnChallenge", reflect.TypeOf((*MockStorage)(nil).GetWebAuthnChallenge), token)
+}
+
+// HashToken mocks base method.
+func (m *MockStorage) HashToken(token string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashToken", token)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// HashToken indicates an expected call of HashToken.
+func (mr *MockStorageMockRecorder) HashToken(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashToken", reflect.TypeOf((*MockStorage)(nil).HashToken), token)
+}
+
+// IssueSession mocks base method.
+func (m *MockStorage) IssueSession(userID int64, sessionID uuid.UUID, grants jwt.Grants) (*storage.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RefreshSession", userID, sessionID, userData)
+	ret := m.ctrl.Call(m, "IssueSession", userID, sessionID, grants)
 	ret0, _ := ret[0].(*storage.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// IssueSession indicates an expected call of IssueSession.
+func (mr *MockStorageMockRecorder) IssueSession(userID, sessionID, grants interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueSession", reflect.TypeOf((*MockStorage)(nil).IssueSession), userID, sessionID, grants)
+}
+
+// MatchToken mocks base method.
+func (m *MockStorage) MatchToken(hash, token string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MatchToken", hash, token)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// MatchToken indicates an expected call of MatchToken.
+func (mr *MockStorageMockRecorder) MatchToken(hash, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MatchToken", reflect.TypeOf((*MockStorage)(nil).MatchToken), hash, token)
+}
+
+// RefreshSession mocks base method.
+func (m *MockStorage) RefreshSession(session *storage.Session, userData []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSession", session, userData)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
 // RefreshSession indicates an expected call of RefreshSession.
-func (mr *MockStorageMockRecorder) RefreshSession(userID, sessionID, userData interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) RefreshSession(session, userData interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockStorage)(nil).RefreshSession), session, userData)
+}
+
+// SpendMFAChallenge mocks base method.
+func (m *MockStorage) SpendMFAChallenge(token string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SpendMFAChallenge", token)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SpendMFAChallenge indicates an expected call of SpendMFAChallenge.
+func (mr *MockStorageMockRecorder) SpendMFAChallenge(token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockStorage)(nil).RefreshSession), userID, sessionID, userData)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpendMFAChallenge", reflect.TypeOf((*MockStorage)(nil).SpendMFAChallenge), token)
 }
 
 // UpdateSessionData mocks base method.
@@ -322,3 +1208,138 @@ func (mr *MockStorageMockRecorder) UpdateSessionData(token, userData interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSessionData", reflect.TypeOf((*MockStorage)(nil).UpdateSessionData), token, userData)
 }
+
+// MockThrottler is a mock of Throttler interface.
+type MockThrottler struct {
+	ctrl     *gomock.Controller
+	recorder *MockThrottlerMockRecorder
+}
+
+// MockThrottlerMockRecorder is the mock recorder for MockThrottler.
+type MockThrottlerMockRecorder struct {
+	mock *MockThrottler
+}
+
+// NewMockThrottler creates a new mock instance.
+func NewMockThrottler(ctrl *gomock.Controller) *MockThrottler {
+	mock := &MockThrottler{ctrl: ctrl}
+	mock.recorder = &MockThrottlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockThrottler) EXPECT() *MockThrottlerMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockThrottler) Check(keys ...throttle.Key) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Check", varargs...)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockThrottlerMockRecorder) Check(keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockThrottler)(nil).Check), keys...)
+}
+
+// Fail mocks base method.
+func (m *MockThrottler) Fail(keys ...throttle.Key) ([]throttle.Lockout, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Fail", varargs...)
+	ret0, _ := ret[0].([]throttle.Lockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockThrottlerMockRecorder) Fail(keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockThrottler)(nil).Fail), keys...)
+}
+
+// Lockouts mocks base method.
+func (m *MockThrottler) Lockouts(keys ...throttle.Key) ([]throttle.Lockout, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Lockouts", varargs...)
+	ret0, _ := ret[0].([]throttle.Lockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lockouts indicates an expected call of Lockouts.
+func (mr *MockThrottlerMockRecorder) Lockouts(keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lockouts", reflect.TypeOf((*MockThrottler)(nil).Lockouts), keys...)
+}
+
+// Reset mocks base method.
+func (m *MockThrottler) Reset(keys ...throttle.Key) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Reset", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockThrottlerMockRecorder) Reset(keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockThrottler)(nil).Reset), keys...)
+}
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockNotifier) Send(msg notifier.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockNotifierMockRecorder) Send(msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockNotifier)(nil).Send), msg)
+}
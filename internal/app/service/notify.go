@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"time"
+)
+
+// notify queues security notification of the user in the outbox. Called with context of transaction,
+// the notification is queued only if the event is committed.
+func (o options) notify(ctx context.Context, repo Repository, userID int64, kind notifier.Kind, data map[string]string) error {
+	if !o.securityNotifications {
+		return nil
+	}
+	return repo.CreateOutboxMessage(ctx, &model.OutboxMessage{UserID: userID, Kind: kind, Data: data})
+}
+
+// queueToken queues token sent to the user in the outbox, e.g. password reset token. The token is encrypted,
+// so it can't be read from the database, and it's bound to the user and kind of message.
+// Email is set if the token is sent to address other than verified email of the user.
+func (o options) queueToken(ctx context.Context, repo Repository, userID int64, kind notifier.Kind, token string, expiresAt time.Time, email string) error {
+	if o.tokenBox == nil {
+		return errors.ErrNotifierNotConfigured
+	}
+	sealed, err := o.tokenBox.Seal([]byte(token), notifier.TokenAdditionalData(userID, kind))
+	if err != nil {
+		return err
+	}
+	data := map[string]string{
+		notifier.DataToken:     sealed,
+		notifier.DataExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	if email != "" {
+		data[notifier.DataEmail] = email
+	}
+	return repo.CreateOutboxMessage(ctx, &model.OutboxMessage{UserID: userID, Kind: kind, Data: data})
+}
+
+// notifyLockout tells the user about locked out login, it's not part of any transaction.
+func (s *AuthService) notifyLockout(ctx context.Context, userID int64) {
+	if err := s.notify(ctx, s.repo, userID, notifier.KindLoginLocked, s.clientDetails(ctx)); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't queue lockout notification")
+	}
+}
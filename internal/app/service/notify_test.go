@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"time"
+)
+
+func (s *AuthSuite) TestNotify_NewLogin() {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", "10.0.0.9", "user-agent", "client/1.0"))
+	// x-forwarded-for of untrusted peer is ignored
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(user, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, user.ID).Return(nil, nil).Times(1)
+	s.expectSession(ctx, user.ID)
+	s.repo.EXPECT().CreateOutboxMessage(ctx, &model.OutboxMessage{
+		UserID: user.ID,
+		Kind:   notifier.KindNewLogin,
+		Data:   map[string]string{"ip": "10.0.0.1", "user_agent": "client/1.0"},
+	}).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithSecurityNotifications(true)).Login(ctx, &api.LoginRequest{
+		Login:    "login",
+		Password: "password",
+		Data:     []byte("data"),
+	})
+	s.Require().NoError(err)
+	s.Equal("access", resp.Access.Token)
+}
+
+func (s *AuthSuite) TestNotify_LoginLocked() {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	keys := []throttle.Key{throttle.LoginKey("login"), throttle.IPKey("10.0.0.1")}
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	service := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithThrottler(s.throttler), WithSecurityNotifications(true))
+
+	// lockout of ip only isn't told to the user
+	s.throttler.EXPECT().Check(keys).Return(time.Duration(0), nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(s.newUser(hasher, "password"), nil).Times(1)
+	s.throttler.EXPECT().Fail(keys).Return([]throttle.Lockout{{Key: keys[1], Failures: 20, Locked: true}}, nil).Times(1)
+
+	resp, err := service.Login(ctx, &api.LoginRequest{Login: "login", Password: "wrong"})
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	s.throttler.EXPECT().Check(keys).Return(time.Duration(0), nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(s.newUser(hasher, "password"), nil).Times(1)
+	s.throttler.EXPECT().Fail(keys).Return([]throttle.Lockout{{Key: keys[0], Failures: 5, Locked: true}}, nil).Times(1)
+	s.repo.EXPECT().CreateOutboxMessage(ctx, &model.OutboxMessage{
+		UserID: 123,
+		Kind:   notifier.KindLoginLocked,
+		Data:   map[string]string{"ip": "10.0.0.1"},
+	}).Return(nil).Times(1)
+
+	resp, err = service.Login(ctx, &api.LoginRequest{Login: "login", Password: "wrong"})
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
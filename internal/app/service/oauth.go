@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/oauth"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const (
+	oauthClientIDSize     = 24
+	oauthClientSecretSize = 32
+)
+
+// oauthClientKey keeps client of token endpoint in context of grpc methods it calls.
+type oauthClientKey struct{}
+
+var oauthGrantTypes = map[string]bool{
+	oauth.GrantTypePassword:          true,
+	oauth.GrantTypeRefreshToken:      true,
+	oauth.GrantTypeClientCredentials: true,
+}
+
+// OAuthToken serves oauth token endpoint: password grant is Login, refresh_token grant is
+// NewAccessTokenByRefreshToken and client_credentials grant issues token of service account of the client.
+// Errors are oauth.Error except internal ones.
+func (s *AuthService) OAuthToken(ctx context.Context, r oauth.TokenRequest) (*oauth.Token, error) {
+	if !oauthGrantTypes[r.GrantType] {
+		return nil, oauth.ErrUnsupportedGrantType
+	}
+	client, err := s.oauthClient(ctx, r.ClientID, r.ClientSecret)
+	if err != nil {
+		return nil, err
+	} else if !client.AllowsGrant(r.GrantType) {
+		log.WithContext(ctx, s.logger).Info().Str("client_id", client.ClientID).Str("grant_type", r.GrantType).Msg("grant isn't allowed to oauth client")
+		return nil, oauth.ErrUnauthorizedClient
+	}
+	// refresh tokens are issued to the client and accepted from it only
+	ctx = context.WithValue(ctx, oauthClientKey{}, client.ClientID)
+
+	switch r.GrantType {
+	case oauth.GrantTypePassword:
+		if r.Username == "" || r.Password == "" {
+			return nil, oauth.ErrInvalidRequest.WithDescription("username and password are required")
+		}
+		resp, err := s.Login(ctx, &api.LoginRequest{Login: r.Username, Password: r.Password})
+		if err != nil {
+			return nil, oauthError(err)
+		} else if resp.GetMfa() != nil {
+			// password grant has no step for the second factor
+			return nil, oauth.ErrInvalidGrant.WithDescription("mfa required")
+		}
+		return oauthToken(resp.GetAccess(), resp.GetRefresh()), nil
+
+	case oauth.GrantTypeRefreshToken:
+		if r.RefreshToken == "" {
+			return nil, oauth.ErrInvalidRequest.WithDescription("refresh_token is required")
+		}
+		resp, err := s.NewAccessTokenByRefreshToken(ctx, &api.NewAccessTokenByRefreshTokenRequest{RefreshToken: r.RefreshToken})
+		if err != nil {
+			return nil, oauthError(err)
+		}
+		return oauthToken(resp.GetAccess(), resp.GetRefresh()), nil
+
+	default:
+		if client.ServiceAccountID == 0 {
+			return nil, oauth.ErrUnauthorizedClient.WithDescription("client has no service account")
+		}
+		token, err := s.storage.CreateServiceToken(client.ServiceAccountID, s.serviceTokenTTL)
+		if err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", client.ServiceAccountID).Msg("can't create service token")
+			return nil, err
+		}
+		log.WithContext(ctx, s.logger).Info().Str("client_id", client.ClientID).Int64("service_account_id", client.ServiceAccountID).Msg("service token issued")
+		return oauthToken(&api.Token{Token: token.Value, ExpiresIn: token.ExpiresIn}, nil), nil
+	}
+}
+
+// oauthClientID returns client of token endpoint, it's empty for grpc calls.
+func oauthClientID(ctx context.Context) string {
+	clientID, _ := ctx.Value(oauthClientKey{}).(string)
+	return clientID
+}
+
+// oauthClient authenticates client by its secret.
+func (s *AuthService) oauthClient(ctx context.Context, clientID, secret string) (*model.OAuthClient, error) {
+	client, err := s.repo.GetOAuthClient(ctx, model.OAuthClientFilter{ClientID: clientID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("client_id", clientID).Msg("can't get oauth client")
+		return nil, err
+	} else if client == nil || !s.storage.MatchToken(client.SecretHash, secret) {
+		log.WithContext(ctx, s.logger).Info().Str("client_id", clientID).Msg("invalid oauth client")
+		return nil, oauth.ErrInvalidClient
+	}
+	return client, nil
+}
+
+func (s *ManageService) CreateOAuthClient(ctx context.Context, r *api.CreateOAuthClientRequest) (*api.OAuthClientResponse, error) {
+	if r.GetName() == "" || len(r.GetGrantTypes()) == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	client := &model.OAuthClient{Name: r.GetName(), GrantTypes: r.GetGrantTypes()}
+	for _, grantType := range client.GrantTypes {
+		if !oauthGrantTypes[grantType] {
+			return nil, convert(&errors.ValidationError{Violations: []errors.FieldViolation{
+				{Field: "grant_types", Description: "unsupported grant type " + grantType},
+			}})
+		}
+	}
+	if client.AllowsGrant(oauth.GrantTypeClientCredentials) || r.GetServiceAccountId() != 0 {
+		account, err := s.findServiceAccount(ctx, r.GetServiceAccountId())
+		if err != nil {
+			return nil, convert(err)
+		}
+		client.ServiceAccountID = account.ID
+	}
+
+	var err error
+	if client.ClientID, err = random.Code(oauthClientIDSize); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't generate oauth client id")
+		return nil, convert(err)
+	}
+	secret, err := s.newOAuthClientSecret(client)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't generate oauth client secret")
+		return nil, convert(err)
+	}
+	if err := s.repo.CreateOAuthClient(ctx, client); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("name", client.Name).Msg("can't create oauth client")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("client_id", client.ClientID).Msg("created oauth client")
+	return &api.OAuthClientResponse{Client: oauthClientResponse(client), ClientSecret: secret}, nil
+}
+
+func (s *ManageService) GetOAuthClients(ctx context.Context, r *api.GetOAuthClientsRequest) (*api.GetOAuthClientsResponse, error) {
+	clients, err := s.repo.GetOAuthClients(ctx, model.OAuthClientFilter{})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get oauth client list")
+		return nil, convert(err)
+	}
+
+	list := make([]*api.OAuthClient, 0, len(clients))
+	for _, client := range clients {
+		list = append(list, oauthClientResponse(client))
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int("count", len(list)).Msg("get oauth client list")
+	return &api.GetOAuthClientsResponse{Clients: list}, nil
+}
+
+// RotateOAuthClientSecret replaces secret of the client, the previous one stops working at once.
+func (s *ManageService) RotateOAuthClientSecret(ctx context.Context, r *api.RotateOAuthClientSecretRequest) (*api.OAuthClientResponse, error) {
+	client, err := s.findOAuthClient(ctx, r.GetClientId())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	secret, err := s.newOAuthClientSecret(client)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't generate oauth client secret")
+		return nil, convert(err)
+	}
+	if err := s.repo.UpdateOAuthClient(ctx, client); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("client_id", client.ClientID).Msg("can't update oauth client")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("client_id", client.ClientID).Msg("rotated oauth client secret")
+	return &api.OAuthClientResponse{Client: oauthClientResponse(client), ClientSecret: secret}, nil
+}
+
+func (s *ManageService) DeleteOAuthClient(ctx context.Context, r *api.DeleteOAuthClientRequest) (*api.DeleteOAuthClientResponse, error) {
+	client, err := s.findOAuthClient(ctx, r.GetClientId())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	if err := s.repo.DeleteOAuthClient(ctx, client); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("client_id", client.ClientID).Msg("can't delete oauth client")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("client_id", client.ClientID).Msg("deleted oauth client")
+	return &api.DeleteOAuthClientResponse{Deleted: true}, nil
+}
+
+func (s *ManageService) findOAuthClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	if clientID == "" {
+		return nil, errors.ErrBadRequest
+	}
+	client, err := s.repo.GetOAuthClient(ctx, model.OAuthClientFilter{ClientID: clientID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("client_id", clientID).Msg("can't get oauth client")
+		return nil, err
+	} else if client == nil {
+		log.WithContext(ctx, s.logger).Info().Str("client_id", clientID).Msg("oauth client not found")
+		return nil, errors.ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+// newOAuthClientSecret sets hash of new secret to the client and returns the secret to give out.
+func (s *ManageService) newOAuthClientSecret(client *model.OAuthClient) (string, error) {
+	secret, err := random.String(oauthClientSecretSize)
+	if err != nil {
+		return "", err
+	}
+	client.SecretHash = s.storage.HashToken(secret)
+	return secret, nil
+}
+
+// oauthError turns errors of grpc methods into oauth ones, rejected credentials and tokens are invalid_grant.
+func oauthError(err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied, codes.NotFound, codes.ResourceExhausted:
+		return oauth.ErrInvalidGrant.WithDescription(status.Convert(err).Message())
+	default:
+		return err
+	}
+}
+
+// oauthToken converts tokens of grpc response, oauth expires_in is lifetime instead of expiration time.
+func oauthToken(access, refresh *api.Token) *oauth.Token {
+	token := &oauth.Token{
+		AccessToken: access.GetToken(),
+		TokenType:   oauth.TokenTypeBearer,
+		ExpiresIn:   int64(access.GetExpiresIn()) - time.Now().Unix(),
+	}
+	if token.ExpiresIn < 0 {
+		token.ExpiresIn = 0
+	}
+	if refresh != nil {
+		token.RefreshToken = refresh.GetToken()
+	}
+	return token
+}
+
+func oauthClientResponse(client *model.OAuthClient) *api.OAuthClient {
+	return &api.OAuthClient{
+		ClientId:         client.ClientID,
+		Name:             client.Name,
+		GrantTypes:       client.GrantTypes,
+		ServiceAccountId: client.ServiceAccountID,
+		Created:          client.Created.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/oauth"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"time"
+)
+
+func (s *AuthSuite) expectOAuthClient(ctx context.Context, client *model.OAuthClient) {
+	s.repo.EXPECT().GetOAuthClient(ctx, model.OAuthClientFilter{ClientID: client.ClientID}).Return(client, nil).Times(1)
+	s.storage.EXPECT().MatchToken(client.SecretHash, "secret").Return(true).Times(1)
+}
+
+func (s *AuthSuite) TestOAuthToken_Password() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+	expiresIn := int32(time.Now().Add(time.Minute).Unix())
+
+	s.expectOAuthClient(ctx, &model.OAuthClient{ClientID: "client", SecretHash: "hash", GrantTypes: []string{oauth.GrantTypePassword}})
+	clientCtx := context.WithValue(ctx, oauthClientKey{}, "client")
+	s.repo.EXPECT().GetUser(clientCtx, model.UserFilter{Login: "login"}).Return(user, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(clientCtx, user.ID).Return(nil, nil).Times(1)
+	s.repo.EXPECT().GetRoles(clientCtx, model.RoleFilter{UserID: user.ID}).Return(nil, nil).Times(1)
+	s.storage.EXPECT().CreateSession(user.ID, jwt.Grants{}, nil).Return(&storage.Session{
+		ID:      uuid.NewV4(),
+		UserID:  user.ID,
+		Access:  storage.Token{Value: "access", ExpiresIn: expiresIn},
+		Refresh: storage.Token{Value: "refresh", ExpiresIn: expiresIn},
+	}, nil).Times(1)
+	s.storage.EXPECT().HashToken("refresh").Return("refresh_hash").Times(1)
+	s.expectTransaction(clientCtx)
+	// refresh token is bound to the client
+	s.repo.EXPECT().CreateRefreshToken(clientCtx, gomock.Any()).DoAndReturn(func(ctx context.Context, token *model.RefreshToken) error {
+		s.Equal("client", token.ClientID)
+		return nil
+	}).Times(1)
+
+	token, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).OAuthToken(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypePassword,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Username:     "login",
+		Password:     "password",
+	})
+	s.Require().NoError(err)
+	s.Equal("access", token.AccessToken)
+	s.Equal("refresh", token.RefreshToken)
+	s.Equal(oauth.TokenTypeBearer, token.TokenType)
+	s.InDelta(60, token.ExpiresIn, 5)
+}
+
+func (s *AuthSuite) TestOAuthToken_RefreshTokenOfAnotherClient() {
+	ctx := context.Background()
+	sessionID := uuid.NewV4()
+	service := NewAuthService(s.repo, s.storage, s.logger)
+	client := &model.OAuthClient{ClientID: "client", SecretHash: "hash", GrantTypes: []string{oauth.GrantTypeRefreshToken}}
+	clientCtx := context.WithValue(ctx, oauthClientKey{}, "client")
+
+	for _, issuedTo := range []string{"other", ""} {
+		s.expectOAuthClient(ctx, client)
+		s.storage.EXPECT().DecodeToken("refresh", jwt.TokenTypeRefresh).Return(int64(123), sessionID, nil).Times(1)
+		s.storage.EXPECT().GetSessionDataByUUID(sessionID).Return([]byte("data"), nil).Times(1)
+		s.expectTransaction(clientCtx)
+		s.repo.EXPECT().LockRefreshToken(clientCtx, model.RefreshTokenFilter{UserID: 123, SessionID: sessionID}).Return(&model.RefreshToken{
+			UserID:    123,
+			SessionID: sessionID,
+			TokenHash: "refresh_hash",
+			ExpiresIn: int32(time.Now().Add(time.Minute).Unix()),
+			ClientID:  issuedTo,
+		}, nil).Times(1)
+		s.storage.EXPECT().MatchToken("refresh_hash", "refresh").Return(true).Times(1)
+
+		_, err := service.OAuthToken(ctx, oauth.TokenRequest{GrantType: oauth.GrantTypeRefreshToken, ClientID: "client", ClientSecret: "secret", RefreshToken: "refresh"})
+		s.ErrorIsf(err, oauth.ErrInvalidGrant, "issued to %q", issuedTo)
+	}
+
+	// token of oauth client isn't refreshed by grpc call
+	s.storage.EXPECT().DecodeToken("refresh", jwt.TokenTypeRefresh).Return(int64(123), sessionID, nil).Times(1)
+	s.storage.EXPECT().GetSessionDataByUUID(sessionID).Return([]byte("data"), nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().LockRefreshToken(ctx, model.RefreshTokenFilter{UserID: 123, SessionID: sessionID}).Return(&model.RefreshToken{
+		UserID:    123,
+		SessionID: sessionID,
+		TokenHash: "refresh_hash",
+		ExpiresIn: int32(time.Now().Add(time.Minute).Unix()),
+		ClientID:  "client",
+	}, nil).Times(1)
+	s.storage.EXPECT().MatchToken("refresh_hash", "refresh").Return(true).Times(1)
+	resp, err := service.NewAccessTokenByRefreshToken(ctx, &api.NewAccessTokenByRefreshTokenRequest{RefreshToken: "refresh"})
+	s.Nil(resp)
+	s.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *AuthSuite) TestOAuthToken_SpoofedForwardedFor() {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", "192.0.2.10"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 1234}})
+
+	// remote address isn't trusted proxy, so the header doesn't change throttled ip
+	s.expectOAuthClient(ctx, &model.OAuthClient{ClientID: "client", SecretHash: "hash", GrantTypes: []string{oauth.GrantTypePassword}})
+	s.throttler.EXPECT().Check([]throttle.Key{throttle.LoginKey("login"), throttle.IPKey("203.0.113.5")}).Return(time.Minute, nil).Times(1)
+
+	_, err := NewAuthService(s.repo, s.storage, s.logger, WithThrottler(s.throttler)).OAuthToken(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypePassword,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Username:     "login",
+		Password:     "password",
+	})
+	s.ErrorIs(err, oauth.ErrInvalidGrant)
+}
+
+func (s *AuthSuite) TestOAuthToken_ClientCredentials() {
+	ctx := context.Background()
+	expiresIn := int32(time.Now().Add(time.Minute).Unix())
+
+	s.expectOAuthClient(ctx, &model.OAuthClient{ClientID: "client", SecretHash: "hash", GrantTypes: []string{oauth.GrantTypeClientCredentials}, ServiceAccountID: 7})
+	s.storage.EXPECT().CreateServiceToken(int64(7), time.Minute).Return(storage.Token{Value: "service", ExpiresIn: expiresIn}, nil).Times(1)
+
+	token, err := NewAuthService(s.repo, s.storage, s.logger, WithServiceTokenTTL(time.Minute)).OAuthToken(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeClientCredentials,
+		ClientID:     "client",
+		ClientSecret: "secret",
+	})
+	s.Require().NoError(err)
+	s.Equal("service", token.AccessToken)
+	s.Empty(token.RefreshToken)
+}
+
+func (s *AuthSuite) TestOAuthToken_Error() {
+	ctx := context.Background()
+	service := NewAuthService(s.repo, s.storage, s.logger)
+
+	_, err := service.OAuthToken(ctx, oauth.TokenRequest{GrantType: "implicit", ClientID: "client", ClientSecret: "secret"})
+	s.ErrorIs(err, oauth.ErrUnsupportedGrantType)
+
+	s.repo.EXPECT().GetOAuthClient(ctx, model.OAuthClientFilter{ClientID: "unknown"}).Return(nil, nil).Times(1)
+	_, err = service.OAuthToken(ctx, oauth.TokenRequest{GrantType: oauth.GrantTypePassword, ClientID: "unknown", ClientSecret: "secret"})
+	s.ErrorIs(err, oauth.ErrInvalidClient)
+
+	client := &model.OAuthClient{ClientID: "client", SecretHash: "hash", GrantTypes: []string{oauth.GrantTypeRefreshToken}}
+	s.expectOAuthClient(ctx, client)
+	_, err = service.OAuthToken(ctx, oauth.TokenRequest{GrantType: oauth.GrantTypePassword, ClientID: "client", ClientSecret: "secret"})
+	s.ErrorIs(err, oauth.ErrUnauthorizedClient)
+
+	s.expectOAuthClient(ctx, client)
+	s.storage.EXPECT().DecodeToken("access", jwt.TokenTypeRefresh).Return(int64(0), uuid.Nil, jwt.ErrInvalidType).Times(1)
+	_, err = service.OAuthToken(ctx, oauth.TokenRequest{GrantType: oauth.GrantTypeRefreshToken, ClientID: "client", ClientSecret: "secret", RefreshToken: "access"})
+	s.ErrorIs(err, oauth.ErrInvalidGrant)
+	s.EqualError(err, "invalid_grant: refresh token required")
+}
+
+func (s *ManageSuite) TestCreateOAuthClient_Success() {
+	ctx := context.Background()
+	var created *model.OAuthClient
+
+	s.repo.EXPECT().GetServiceAccount(ctx, model.ServiceAccountFilter{ID: 7}).Return(&model.ServiceAccount{ID: 7}, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).Return("hash").Times(1)
+	s.repo.EXPECT().CreateOAuthClient(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, client *model.OAuthClient) error {
+		created = client
+		return nil
+	}).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateOAuthClient(ctx, &api.CreateOAuthClientRequest{
+		Name:             "partner",
+		GrantTypes:       []string{oauth.GrantTypeClientCredentials},
+		ServiceAccountId: 7,
+	})
+	s.Require().NoError(err)
+	s.Equal("hash", created.SecretHash)
+	s.Equal(int64(7), created.ServiceAccountID)
+	s.Len(resp.Client.ClientId, oauthClientIDSize)
+	s.Equal(created.ClientID, resp.Client.ClientId)
+	s.NotEmpty(resp.ClientSecret)
+}
+
+func (s *ManageSuite) TestCreateOAuthClient_Error() {
+	ctx := context.Background()
+	service := NewManageService(s.repo, s.storage, s.logger)
+
+	resp, err := service.CreateOAuthClient(ctx, &api.CreateOAuthClientRequest{Name: "partner", GrantTypes: []string{"implicit"}})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	// client_credentials grant needs service account
+	resp, err = service.CreateOAuthClient(ctx, &api.CreateOAuthClientRequest{Name: "partner", GrantTypes: []string{oauth.GrantTypeClientCredentials}})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *ManageSuite) TestRotateOAuthClientSecret_Success() {
+	ctx := context.Background()
+	client := &model.OAuthClient{ID: 1, ClientID: "client", SecretHash: "old"}
+
+	s.repo.EXPECT().GetOAuthClient(ctx, model.OAuthClientFilter{ClientID: "client"}).Return(client, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).Return("new").Times(1)
+	s.repo.EXPECT().UpdateOAuthClient(ctx, client).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).RotateOAuthClientSecret(ctx, &api.RotateOAuthClientSecretRequest{ClientId: "client"})
+	s.Require().NoError(err)
+	s.Equal("new", client.SecretHash)
+	s.NotEmpty(resp.ClientSecret)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+	"github.com/sanches1984/msa-auth/pkg/webauthn"
+	"net"
+	"time"
+)
+
+type options struct {
+	hasher          *password.Hasher
+	policy          password.Policy
+	passwordHistory int
+	revokeSessions  bool
+	throttler       Throttler
+	genericErrors   bool
+	totpIssuer      string
+	secretBox       *secretbox.Box
+	recoveryCodes   int
+	webAuthn        *webauthn.WebAuthn
+	notifier        Notifier
+	tokenBox        *secretbox.Box
+	resetTTL        time.Duration
+	verifyTTL       time.Duration
+	serviceTokenTTL time.Duration
+	trustedProxies  []*net.IPNet
+	// securityNotifications queues notifications of account events in the outbox
+	securityNotifications bool
+}
+
+type Option func(o *options)
+
+// WithPasswordHasher sets algorithm of new password hashes, bcrypt with default cost is used by default.
+func WithPasswordHasher(hasher *password.Hasher) Option {
+	return func(o *options) {
+		o.hasher = hasher
+	}
+}
+
+// WithPasswordPolicy sets policy of new passwords, password.DefaultPolicy is used by default.
+func WithPasswordPolicy(policy password.Policy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithPasswordHistory forbids reuse of the current and size-1 previous passwords, 0 turns the check off.
+func WithPasswordHistory(size int) Option {
+	return func(o *options) {
+		o.passwordHistory = size
+	}
+}
+
+// WithRevokeOtherSessions sets if password change terminates all sessions of the user but the current one, on by default.
+func WithRevokeOtherSessions(revoke bool) Option {
+	return func(o *options) {
+		o.revokeSessions = revoke
+	}
+}
+
+// WithThrottler enables brute-force protection of login, it's off by default.
+func WithThrottler(throttler Throttler) Option {
+	return func(o *options) {
+		o.throttler = throttler
+	}
+}
+
+// WithGenericLoginErrors makes login return the same error for unknown user and wrong password,
+// so logins can't be enumerated. Logs keep the real reason.
+func WithGenericLoginErrors(generic bool) Option {
+	return func(o *options) {
+		o.genericErrors = generic
+	}
+}
+
+// WithTOTP enables totp second factor, issuer is shown by authenticator apps, box encrypts secrets.
+func WithTOTP(issuer string, box *secretbox.Box) Option {
+	return func(o *options) {
+		o.totpIssuer = issuer
+		o.secretBox = box
+	}
+}
+
+// WithRecoveryCodes sets number of generated recovery codes, 10 by default.
+func WithRecoveryCodes(count int) Option {
+	return func(o *options) {
+		o.recoveryCodes = count
+	}
+}
+
+// WithWebAuthn enables passkey registration and login, it's off by default.
+func WithWebAuthn(w *webauthn.WebAuthn) Option {
+	return func(o *options) {
+		o.webAuthn = w
+	}
+}
+
+// WithNotifier enables password reset, reset tokens are delivered by notifier. It's off by default.
+// Box encrypts tokens while they are queued in the outbox.
+func WithNotifier(n Notifier, box *secretbox.Box) Option {
+	return func(o *options) {
+		o.notifier = n
+		o.tokenBox = box
+	}
+}
+
+// WithPasswordResetTTL sets lifetime of password reset tokens, 1 hour by default.
+func WithPasswordResetTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.resetTTL = ttl
+	}
+}
+
+// WithEmailVerificationTTL sets lifetime of email verification tokens, 24 hours by default.
+func WithEmailVerificationTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.verifyTTL = ttl
+	}
+}
+
+// WithServiceTokenTTL sets lifetime of access tokens issued to service accounts by api keys, 15 minutes by default.
+func WithServiceTokenTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.serviceTokenTTL = ttl
+	}
+}
+
+// WithTrustedProxies sets networks of proxies whose x-forwarded-for is honored, by default
+// the header is ignored and client address is address of grpc peer.
+func WithTrustedProxies(proxies []*net.IPNet) Option {
+	return func(o *options) {
+		o.trustedProxies = proxies
+	}
+}
+
+// WithSecurityNotifications queues notifications of password change, new login, lockout and mfa changes
+// in the outbox, it's off by default. Dispatcher of the outbox must be run to deliver them.
+func WithSecurityNotifications(enabled bool) Option {
+	return func(o *options) {
+		o.securityNotifications = enabled
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		policy:          password.DefaultPolicy(),
+		revokeSessions:  true,
+		recoveryCodes:   defaultRecoveryCodes,
+		resetTTL:        defaultPasswordResetTTL,
+		verifyTTL:       defaultEmailVerificationTTL,
+		serviceTokenTTL: defaultServiceTokenTTL,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.hasher == nil {
+		o.hasher, _ = password.New()
+	}
+	return o
+}
+
+// checkPassword returns errors.ValidationError with every rule of policy broken by password.
+func (o options) checkPassword(field, login, pwd string) error {
+	violations := o.policy.Validate(login, pwd)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	err := &errors.ValidationError{}
+	for _, v := range violations {
+		err.Violations = append(err.Violations, errors.FieldViolation{
+			Field:       field,
+			Description: string(v.Rule) + ": " + v.Description,
+		})
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
+)
+
+const (
+	headerForwardedFor = "x-forwarded-for"
+	headerUserAgent    = "user-agent"
+)
+
+// clientIP returns address of grpc peer. Only if the peer is trusted proxy, x-forwarded-for is walked
+// from the end and the first address which isn't trusted proxy is the client.
+func (o options) clientIP(ctx context.Context) string {
+	ip := peerIP(ctx)
+	if ip == "" || !o.isTrustedProxy(ip) {
+		return ip
+	}
+
+	var hops []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get(headerForwardedFor) {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		// malformed hop can't be trusted, the last valid one is the client then
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		ip = hops[i]
+		if !o.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (o options) isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	for _, proxy := range o.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func peerIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func userAgent(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(headerUserAgent); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// clientDetails describes client of the request for security notifications.
+func (o options) clientDetails(ctx context.Context) map[string]string {
+	details := map[string]string{}
+	if ip := o.clientIP(ctx); ip != "" {
+		details["ip"] = ip
+	}
+	if ua := userAgent(ctx); ua != "" {
+		details["user_agent"] = ua
+	}
+	return details
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("192.168.0.0/16")
+	require.NoError(t, err)
+	o := newOptions([]Option{WithTrustedProxies([]*net.IPNet{proxies})})
+
+	cases := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		expected  string
+	}{
+		{name: "no proxy", peer: "10.0.0.1", expected: "10.0.0.1"},
+		{name: "untrusted peer", peer: "10.0.0.1", forwarded: []string{"10.0.0.9"}, expected: "10.0.0.1"},
+		{name: "trusted proxy", peer: "192.168.0.1", forwarded: []string{"10.0.0.9"}, expected: "10.0.0.9"},
+		{name: "proxy chain", peer: "192.168.0.1", forwarded: []string{"10.0.0.8, 10.0.0.9", "192.168.0.2"}, expected: "10.0.0.9"},
+		{name: "malformed hop", peer: "192.168.0.1", forwarded: []string{"10.0.0.9, unknown"}, expected: "192.168.0.1"},
+		{name: "no header", peer: "192.168.0.1", expected: "192.168.0.1"},
+	}
+	for _, c := range cases {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(c.peer), Port: 5000}})
+		md := metadata.MD{}
+		for _, value := range c.forwarded {
+			md.Append(headerForwardedFor, value)
+		}
+		ctx = metadata.NewIncomingContext(ctx, md)
+		require.Equal(t, c.expected, o.clientIP(ctx), c.name)
+	}
+}
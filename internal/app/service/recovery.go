@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"strings"
+)
+
+const defaultRecoveryCodes = 10
+
+// recoveryCodeSize is number of symbols of recovery code, it's shown in two groups, e.g. "abcde-fghjk".
+const recoveryCodeSize = 10
+
+// GenerateRecoveryCodes replaces recovery codes of the user, the current password is required.
+func (s *AuthService) GenerateRecoveryCodes(ctx context.Context, r *api.GenerateRecoveryCodesRequest) (*api.RecoveryCodesResponse, error) {
+	if r.GetToken() == "" || r.GetPassword() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: userID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("user not found")
+		return nil, convert(errors.ErrUserNotFound)
+	}
+	if err := s.verifyPassword(ctx, user, r.GetPassword()); err != nil {
+		return nil, convert(err)
+	}
+
+	codes, err := s.regenerateRecoveryCodes(ctx, s.repo, s.storage, userID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't generate recovery codes")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("recovery codes generated")
+	return &api.RecoveryCodesResponse{Codes: codes}, nil
+}
+
+// LoginWithRecoveryCode logs in by login and recovery code instead of password, the code is burnt.
+func (s *AuthService) LoginWithRecoveryCode(ctx context.Context, r *api.LoginWithRecoveryCodeRequest) (*api.TokenResponse, error) {
+	if r.GetLogin() == "" || r.GetCode() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	throttleKeys := s.loginThrottleKeys(ctx, r.GetLogin())
+	if err := s.checkThrottle(ctx, throttleKeys); err != nil {
+		return nil, convert(err)
+	}
+
+	// codes are checked by cheap hmac, so both paths take as long as password check of Login
+	// and response time doesn't tell if user exists
+	s.hasher.VerifyDummy(r.GetCode())
+	user, err := userByLogin(ctx, s.repo, r.GetLogin())
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't get user by login")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Str("login", r.GetLogin()).Msg("user not found")
+		s.failThrottle(ctx, throttleKeys)
+		return nil, convert(s.loginError(errors.ErrUserNotFound))
+	}
+
+	codes, err := s.repo.GetRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't get recovery codes")
+		return nil, convert(err)
+	}
+
+	code := normalizeRecoveryCode(r.GetCode())
+	var matched *model.RecoveryCode
+	for _, c := range codes {
+		if s.storage.MatchToken(c.CodeHash, code) {
+			matched = c
+			break
+		}
+	}
+	if matched == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("incorrect recovery code")
+		if s.failThrottle(ctx, throttleKeys) {
+			s.notifyLockout(ctx, user.ID)
+		}
+		return nil, convert(s.loginError(errors.ErrIncorrectCode))
+	}
+
+	if deleted, err := s.repo.DeleteRecoveryCode(ctx, matched); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't delete recovery code")
+		return nil, convert(err)
+	} else if !deleted {
+		// the same code is used by concurrent request
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("recovery code already used")
+		return nil, convert(s.loginError(errors.ErrIncorrectCode))
+	}
+	s.resetThrottle(ctx, throttleKeys)
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Int("remaining", len(codes)-1).Msg("recovery code used")
+
+	resp, err := s.completeLogin(ctx, user.ID, r.GetData())
+	if err != nil {
+		return nil, convert(err)
+	}
+	return resp, nil
+}
+
+func (s *ManageService) RegenerateRecoveryCodes(ctx context.Context, r *api.RegenerateRecoveryCodesRequest) (*api.RecoveryCodesResponse, error) {
+	if r.GetUserId() == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: r.GetUserId()})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", r.GetUserId()).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", r.GetUserId()).Msg("user not found")
+		return nil, convert(errors.ErrUserNotFound)
+	}
+
+	codes, err := s.regenerateRecoveryCodes(ctx, s.repo, s.storage, user.ID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't generate recovery codes")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("recovery codes regenerated")
+	return &api.RecoveryCodesResponse{Codes: codes}, nil
+}
+
+func (s *ManageService) CountRecoveryCodes(ctx context.Context, r *api.CountRecoveryCodesRequest) (*api.CountRecoveryCodesResponse, error) {
+	if r.GetUserId() == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	}
+
+	codes, err := s.repo.GetRecoveryCodes(ctx, r.GetUserId())
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", r.GetUserId()).Msg("can't get recovery codes")
+		return nil, convert(err)
+	}
+
+	return &api.CountRecoveryCodesResponse{Remaining: int32(len(codes))}, nil
+}
+
+// regenerateRecoveryCodes replaces codes of the user by new ones, codes are returned once and kept hashed.
+func (o options) regenerateRecoveryCodes(ctx context.Context, repo Repository, storage Storage, userID int64) ([]string, error) {
+	count := o.recoveryCodes
+	codes := make([]string, 0, count)
+	list := make(model.RecoveryCodeList, 0, count)
+	for i := 0; i < count; i++ {
+		code, err := random.Code(recoveryCodeSize)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code[:recoveryCodeSize/2]+"-"+code[recoveryCodeSize/2:])
+		list = append(list, &model.RecoveryCode{UserID: userID, CodeHash: storage.HashToken(code)})
+	}
+
+	err := repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := repo.CreateRecoveryCodes(ctx, userID, list); err != nil {
+			return err
+		}
+		return o.notify(ctx, repo, userID, notifier.KindRecoveryCodesGenerated, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops separators and case, so codes can be typed as shown or not.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"regexp"
+)
+
+func (s *AuthSuite) TestGenerateRecoveryCodes_Success() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+
+	s.expectActiveSession(user.ID)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).DoAndReturn(func(code string) string {
+		return "hash:" + code
+	}).Times(3)
+	s.expectTransaction(ctx)
+	var stored model.RecoveryCodeList
+	s.repo.EXPECT().CreateRecoveryCodes(ctx, user.ID, gomock.Any()).DoAndReturn(func(ctx context.Context, userID int64, codes model.RecoveryCodeList) error {
+		stored = codes
+		return nil
+	}).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithRecoveryCodes(3)).GenerateRecoveryCodes(ctx, &api.GenerateRecoveryCodesRequest{
+		Token:    "access",
+		Password: "password",
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Codes, 3)
+	s.Require().Len(stored, 3)
+	for i, code := range resp.Codes {
+		s.Regexp(regexp.MustCompile(`^[a-z2-9]{5}-[a-z2-9]{5}$`), code)
+		s.Equal("hash:"+normalizeRecoveryCode(code), stored[i].CodeHash)
+	}
+}
+
+func (s *AuthSuite) TestGenerateRecoveryCodes_Error() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+
+	s.expectActiveSession(user.ID)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher)).GenerateRecoveryCodes(ctx, &api.GenerateRecoveryCodesRequest{
+		Token:    "access",
+		Password: "wrong",
+	})
+	s.Nil(resp)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *AuthSuite) TestLoginWithRecoveryCode_Success() {
+	ctx := context.Background()
+	code := &model.RecoveryCode{ID: 2, UserID: 123, CodeHash: "hash2"}
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().GetRecoveryCodes(ctx, int64(123)).Return(model.RecoveryCodeList{
+		{ID: 1, UserID: 123, CodeHash: "hash1"},
+		code,
+	}, nil).Times(1)
+	s.storage.EXPECT().MatchToken("hash1", "abcdefghjk").Return(false).Times(1)
+	s.storage.EXPECT().MatchToken("hash2", "abcdefghjk").Return(true).Times(1)
+	s.repo.EXPECT().DeleteRecoveryCode(ctx, code).Return(true, nil).Times(1)
+	s.repo.EXPECT().GetUserTOTP(ctx, int64(123)).Return(nil, nil).Times(1)
+	s.expectSession(ctx, 123)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).LoginWithRecoveryCode(ctx, &api.LoginWithRecoveryCodeRequest{
+		Login: "login",
+		Code:  "ABCDE-FGHJK",
+		Data:  []byte("data"),
+	})
+	s.NoError(err)
+	s.Equal("access", resp.Access.Token)
+}
+
+func (s *AuthSuite) TestLoginWithRecoveryCode_AlreadyUsed() {
+	ctx := context.Background()
+	code := &model.RecoveryCode{ID: 1, UserID: 123, CodeHash: "hash1"}
+
+	// concurrent request burnt the code first
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().GetRecoveryCodes(ctx, int64(123)).Return(model.RecoveryCodeList{code}, nil).Times(1)
+	s.storage.EXPECT().MatchToken("hash1", "abcdefghjk").Return(true).Times(1)
+	s.repo.EXPECT().DeleteRecoveryCode(ctx, code).Return(false, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).LoginWithRecoveryCode(ctx, &api.LoginWithRecoveryCodeRequest{
+		Login: "login",
+		Code:  "abcde-fghjk",
+	})
+	s.Nil(resp)
+	s.EqualError(err, "incorrect code")
+}
+
+func (s *AuthSuite) TestLoginWithRecoveryCode_Error() {
+	ctx := context.Background()
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(2)
+	s.repo.EXPECT().GetRecoveryCodes(ctx, int64(123)).Return(model.RecoveryCodeList{
+		{ID: 1, UserID: 123, CodeHash: "hash1"},
+	}, nil).Times(2)
+	s.storage.EXPECT().MatchToken("hash1", "abcdefghjk").Return(false).Times(2)
+
+	req := &api.LoginWithRecoveryCodeRequest{Login: "login", Code: "abcde-fghjk"}
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).LoginWithRecoveryCode(ctx, req)
+	s.Nil(resp)
+	s.EqualError(err, "incorrect code")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	resp, err = NewAuthService(s.repo, s.storage, s.logger, WithGenericLoginErrors(true)).LoginWithRecoveryCode(ctx, req)
+	s.Nil(resp)
+	s.EqualError(err, "invalid login or password")
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	// unknown login by email gets the same error
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "user@example.com"}).Return(nil, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Email: "user@example.com", EmailStatus: model.EmailStatusVerified}).Return(nil, nil).Times(1)
+	resp, err = NewAuthService(s.repo, s.storage, s.logger, WithGenericLoginErrors(true)).LoginWithRecoveryCode(ctx, &api.LoginWithRecoveryCodeRequest{
+		Login: "user@example.com",
+		Code:  "abcde-fghjk",
+	})
+	s.Nil(resp)
+	s.EqualError(err, "invalid login or password")
+}
+
+func (s *ManageSuite) TestRegenerateRecoveryCodes_Success() {
+	ctx := context.Background()
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123}, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).Return("hash").Times(defaultRecoveryCodes)
+	s.repo.EXPECT().WithTransaction(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	}).Times(1)
+	s.repo.EXPECT().CreateRecoveryCodes(ctx, int64(123), gomock.Len(defaultRecoveryCodes)).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).RegenerateRecoveryCodes(ctx, &api.RegenerateRecoveryCodesRequest{UserId: 123})
+	s.NoError(err)
+	s.Len(resp.Codes, defaultRecoveryCodes)
+}
+
+func (s *ManageSuite) TestCountRecoveryCodes() {
+	ctx := context.Background()
+
+	s.repo.EXPECT().GetRecoveryCodes(ctx, int64(123)).Return(model.RecoveryCodeList{{ID: 1}, {ID: 2}}, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).CountRecoveryCodes(ctx, &api.CountRecoveryCodesRequest{UserId: 123})
+	s.NoError(err)
+	s.Equal(int32(2), resp.Remaining)
+}
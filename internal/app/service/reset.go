@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+const defaultPasswordResetTTL = time.Hour
+
+// sentTokenSize is number of random bytes of tokens sent to users, e.g. password reset token.
+const sentTokenSize = 32
+
+// RequestPasswordReset queues reset token to the user in the outbox. The response is the same for unknown login,
+// so logins can't be enumerated. Requests are throttled per login and ip, since each of them sends a message.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, r *api.RequestPasswordResetRequest) (*api.RequestPasswordResetResponse, error) {
+	if r.GetLogin() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.notifier == nil {
+		return nil, convert(errors.ErrNotifierNotConfigured)
+	}
+
+	keys := s.resetThrottleKeys(ctx, r.GetLogin())
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return nil, convert(err)
+	}
+	s.countResetThrottle(ctx, keys)
+
+	// token is generated for unknown login too, so both take the same time
+	token, err := random.String(sentTokenSize)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't generate reset token")
+		return nil, convert(err)
+	}
+
+	user, err := userByLogin(ctx, s.repo, r.GetLogin())
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't get user by login")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Str("login", r.GetLogin()).Msg("user not found")
+		return &api.RequestPasswordResetResponse{Requested: true}, nil
+	}
+
+	expiresAt := time.Now().Add(s.resetTTL)
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		err := s.repo.CreatePasswordResetToken(ctx, &model.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: s.storage.HashToken(token),
+			ExpiresIn: int32(expiresAt.Unix()),
+		})
+		if err != nil {
+			return err
+		}
+		return s.queueToken(ctx, s.repo, user.ID, notifier.KindPasswordReset, token, expiresAt, "")
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't create reset token")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("password reset requested")
+	return &api.RequestPasswordResetResponse{Requested: true}, nil
+}
+
+// ResetPassword sets new password by reset token, the token is burnt and all sessions of the user are revoked.
+func (s *AuthService) ResetPassword(ctx context.Context, r *api.ResetPasswordRequest) (*api.ChangePasswordResponse, error) {
+	if r.GetToken() == "" || r.GetNewPassword() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.notifier == nil {
+		return nil, convert(errors.ErrNotifierNotConfigured)
+	}
+
+	token, err := s.repo.GetPasswordResetToken(ctx, s.storage.HashToken(r.GetToken()))
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get reset token")
+		return nil, convert(err)
+	} else if token == nil {
+		log.WithContext(ctx, s.logger).Info().Msg("reset token not found")
+		return nil, convert(errors.ErrTokenInvalid)
+	} else if token.IsExpired() {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", token.UserID).Msg("reset token has expired")
+		return nil, convert(errors.ErrTokenExpired)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: token.UserID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", token.UserID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", token.UserID).Msg("user not found")
+		return nil, convert(errors.ErrTokenInvalid)
+	}
+
+	if err := s.checkPassword("new_password", user.Login, r.GetNewPassword()); err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Int64("user_id", user.ID).Msg("password policy violated")
+		return nil, convert(err)
+	}
+	if err := s.checkPasswordHistory(ctx, user, r.GetNewPassword()); err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Int64("user_id", user.ID).Msg("password reuse")
+		return nil, convert(err)
+	}
+
+	history := &model.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}
+	if err := user.SetHashByPassword(s.hasher, r.GetNewPassword()); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't set password hash")
+		return nil, convert(err)
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		// the token is burnt first, so concurrent requests with the same token don't both set a password
+		if deleted, err := s.repo.DeletePasswordResetToken(ctx, token); err != nil {
+			return err
+		} else if !deleted {
+			return errors.ErrTokenInvalid
+		}
+		if err := s.repo.CreatePasswordHistory(ctx, history); err != nil {
+			return err
+		}
+		if err := s.repo.UpdateUserPassword(ctx, user); err != nil {
+			return err
+		}
+		if err := s.repo.DeletePasswordResetTokens(ctx, user.ID); err != nil {
+			return err
+		}
+		return s.notify(ctx, s.repo, user.ID, notifier.KindPasswordChanged, s.clientDetails(ctx))
+	})
+	if err == errors.ErrTokenInvalid {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("reset token already used")
+		return nil, convert(err)
+	} else if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't reset user password")
+		return nil, convert(err)
+	}
+
+	revoked, err := s.revokeOtherSessions(ctx, user.ID, uuid.Nil)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't revoke sessions")
+		return nil, convert(err)
+	}
+	s.resetThrottle(ctx, []throttle.Key{throttle.LoginKey(user.Login)})
+	s.resetThrottle(ctx, []throttle.Key{throttle.ResetKey(user.Login)})
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Int("revoked", len(revoked)).Msg("password reset")
+	return &api.ChangePasswordResponse{Changed: true, RevokedSessionId: revoked.Sessions()}, nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/app/service/mocks"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/password"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"time"
+)
+
+func (s *AuthSuite) TestRequestPasswordReset_Success() {
+	ctx := context.Background()
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+
+	s.throttler.EXPECT().Check(throttle.ResetKey("login")).Return(time.Duration(0), nil).Times(1)
+	s.throttler.EXPECT().Fail(throttle.ResetKey("login")).Return(nil, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).DoAndReturn(func(token string) string {
+		return "hash:" + token
+	}).Times(1)
+	s.expectTransaction(ctx)
+	var stored *model.PasswordResetToken
+	s.repo.EXPECT().CreatePasswordResetToken(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, t *model.PasswordResetToken) error {
+		stored = t
+		return nil
+	}).Times(1)
+	s.repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, m *model.OutboxMessage) error {
+		s.Equal(notifier.KindPasswordReset, m.Kind)
+		s.Equal(int64(123), m.UserID)
+		// the token is queued encrypted and bound to the user
+		s.NotEqual("hash:"+m.Data[notifier.DataToken], stored.TokenHash)
+		token, err := box.Open(m.Data[notifier.DataToken], notifier.TokenAdditionalData(123, notifier.KindPasswordReset))
+		s.Require().NoError(err)
+		s.Equal("hash:"+string(token), stored.TokenHash)
+		expiresAt, err := time.Parse(time.RFC3339, m.Data[notifier.DataExpiresAt])
+		s.Require().NoError(err)
+		s.Equal(int32(expiresAt.Unix()), stored.ExpiresIn)
+		s.WithinDuration(time.Now().Add(time.Minute), expiresAt, time.Second)
+		return nil
+	}).Times(1)
+
+	// the token is queued in transaction, not sent by notifier
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithNotifier(mocks.NewMockNotifier(s.ctrl), box), WithPasswordResetTTL(time.Minute), WithThrottler(s.throttler)).
+		RequestPasswordReset(ctx, &api.RequestPasswordResetRequest{Login: "login"})
+	s.NoError(err)
+	s.True(resp.Requested)
+	s.Equal(int64(123), stored.UserID)
+}
+
+func (s *AuthSuite) TestRequestPasswordReset_Throttled() {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	keys := []throttle.Key{throttle.ResetKey("login"), throttle.ResetIPKey("10.0.0.1")}
+
+	s.throttler.EXPECT().Check(keys).Return(time.Minute, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithNotifier(mocks.NewMockNotifier(s.ctrl), nil), WithThrottler(s.throttler)).
+		RequestPasswordReset(ctx, &api.RequestPasswordResetRequest{Login: "login"})
+	s.Nil(resp)
+	s.Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *AuthSuite) TestRequestPasswordReset_UnknownLogin() {
+	ctx := context.Background()
+	n := mocks.NewMockNotifier(s.ctrl)
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "unknown"}).Return(nil, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithNotifier(n, nil)).
+		RequestPasswordReset(ctx, &api.RequestPasswordResetRequest{Login: "unknown"})
+	s.NoError(err)
+	s.True(resp.Requested)
+
+	// not configured
+	resp, err = NewAuthService(s.repo, s.storage, s.logger).RequestPasswordReset(ctx, &api.RequestPasswordResetRequest{Login: "login"})
+	s.Nil(resp)
+	s.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func (s *AuthSuite) TestResetPassword_Success() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+	oldHash := user.PasswordHash
+	sessionID := uuid.NewV4()
+
+	s.storage.EXPECT().HashToken("token").Return("token_hash").Times(1)
+	token := &model.PasswordResetToken{
+		ID:        1,
+		UserID:    user.ID,
+		TokenHash: "token_hash",
+		ExpiresIn: int32(time.Now().Add(time.Hour).Unix()),
+	}
+	s.repo.EXPECT().GetPasswordResetToken(ctx, "token_hash").Return(token, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().DeletePasswordResetToken(ctx, token).Return(true, nil).Times(1)
+	s.repo.EXPECT().CreatePasswordHistory(ctx, &model.PasswordHistory{UserID: user.ID, PasswordHash: oldHash}).Return(nil).Times(1)
+	s.repo.EXPECT().UpdateUserPassword(ctx, user).Return(nil).Times(1)
+	s.repo.EXPECT().DeletePasswordResetTokens(ctx, user.ID).Return(nil).Times(1)
+	s.repo.EXPECT().GetRefreshTokens(ctx, model.RefreshTokenFilter{UserID: user.ID}).
+		Return(model.RefreshTokenList{{UserID: user.ID, SessionID: sessionID}}, nil).Times(1)
+	s.storage.EXPECT().DeleteSessionByUUID(sessionID).Return(nil).Times(1)
+	s.repo.EXPECT().DeleteRefreshToken(ctx, model.RefreshTokenFilter{UserID: user.ID, SessionID: sessionID}).Return(nil).Times(1)
+	s.throttler.EXPECT().Reset(throttle.LoginKey("login")).Return(nil).Times(1)
+	s.throttler.EXPECT().Reset(throttle.ResetKey("login")).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithNotifier(mocks.NewMockNotifier(s.ctrl), nil), WithThrottler(s.throttler)).
+		ResetPassword(ctx, &api.ResetPasswordRequest{Token: "token", NewPassword: "new-Passw0rd"})
+	s.Require().NoError(err)
+	s.True(resp.Changed)
+	s.Equal([]string{sessionID.String()}, resp.RevokedSessionId)
+	s.True(user.IsPasswordCorrect(hasher, "new-Passw0rd"))
+}
+
+func (s *AuthSuite) TestResetPassword_AlreadyUsed() {
+	ctx := context.Background()
+	hasher, err := password.New(password.WithBcrypt(bcrypt.MinCost))
+	s.Require().NoError(err)
+	user := s.newUser(hasher, "password")
+	token := &model.PasswordResetToken{ID: 1, UserID: user.ID, ExpiresIn: int32(time.Now().Add(time.Hour).Unix())}
+
+	// concurrent request burnt the token first
+	s.storage.EXPECT().HashToken("token").Return("token_hash").Times(1)
+	s.repo.EXPECT().GetPasswordResetToken(ctx, "token_hash").Return(token, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: user.ID}).Return(user, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().DeletePasswordResetToken(ctx, token).Return(false, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithPasswordHasher(hasher), WithNotifier(mocks.NewMockNotifier(s.ctrl), nil)).
+		ResetPassword(ctx, &api.ResetPasswordRequest{Token: "token", NewPassword: "new-Passw0rd"})
+	s.Nil(resp)
+	s.EqualError(err, "invalid token")
+	s.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *AuthSuite) TestResetPassword_Error() {
+	ctx := context.Background()
+	service := NewAuthService(s.repo, s.storage, s.logger, WithNotifier(mocks.NewMockNotifier(s.ctrl), nil))
+
+	// unknown or already used token
+	s.storage.EXPECT().HashToken("used").Return("used_hash").Times(1)
+	s.repo.EXPECT().GetPasswordResetToken(ctx, "used_hash").Return(nil, nil).Times(1)
+	resp, err := service.ResetPassword(ctx, &api.ResetPasswordRequest{Token: "used", NewPassword: "new-Passw0rd"})
+	s.Nil(resp)
+	s.EqualError(err, "invalid token")
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	s.storage.EXPECT().HashToken("expired").Return("expired_hash").Times(1)
+	s.repo.EXPECT().GetPasswordResetToken(ctx, "expired_hash").Return(&model.PasswordResetToken{
+		UserID:    123,
+		ExpiresIn: int32(time.Now().Add(-time.Minute).Unix()),
+	}, nil).Times(1)
+	resp, err = service.ResetPassword(ctx, &api.ResetPasswordRequest{Token: "expired", NewPassword: "new-Passw0rd"})
+	s.Nil(resp)
+	s.EqualError(err, "token has expired")
+
+	resp, err = service.ResetPassword(ctx, &api.ResetPasswordRequest{Token: "token"})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	api "github.com/sanches1984/msa-auth/proto/api"
+)
+
+func (s *ManageService) CreatePermission(ctx context.Context, r *api.CreatePermissionRequest) (*api.Permission, error) {
+	if r.GetName() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+
+	permission := &model.Permission{Name: r.GetName(), Description: r.GetDescription()}
+	if err := s.repo.CreatePermission(ctx, permission); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("permission", r.GetName()).Msg("can't create permission")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("permission", permission.Name).Msg("created permission")
+	return &api.Permission{Name: permission.Name, Description: permission.Description}, nil
+}
+
+func (s *ManageService) GetPermissions(ctx context.Context, r *api.GetPermissionsRequest) (*api.GetPermissionsResponse, error) {
+	permissions, err := s.repo.GetPermissions(ctx, model.PermissionFilter{})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get permission list")
+		return nil, convert(err)
+	}
+
+	list := make([]*api.Permission, 0, len(permissions))
+	for _, p := range permissions {
+		list = append(list, &api.Permission{Name: p.Name, Description: p.Description})
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int("count", len(list)).Msg("get permission list")
+	return &api.GetPermissionsResponse{Permissions: list}, nil
+}
+
+// DeletePermission removes the permission from all roles, sessions lose it on the next refresh.
+func (s *ManageService) DeletePermission(ctx context.Context, r *api.DeletePermissionRequest) (*api.DeletePermissionResponse, error) {
+	if r.GetName() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	permissions, err := s.repo.GetPermissions(ctx, model.PermissionFilter{Names: []string{r.GetName()}})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("permission", r.GetName()).Msg("can't get permission")
+		return nil, convert(err)
+	} else if len(permissions) == 0 {
+		log.WithContext(ctx, s.logger).Info().Str("permission", r.GetName()).Msg("permission not found")
+		return nil, convert(errors.ErrPermissionNotFound)
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.DeletePermission(ctx, permissions[0])
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("permission", r.GetName()).Msg("can't delete permission")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("permission", r.GetName()).Msg("deleted permission")
+	return &api.DeletePermissionResponse{Deleted: true}, nil
+}
+
+func (s *ManageService) CreateRole(ctx context.Context, r *api.CreateRoleRequest) (*api.Role, error) {
+	if r.GetName() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	permissions, err := s.findPermissions(ctx, r.GetPermissions())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	role := &model.Role{Name: r.GetName(), Description: r.GetDescription(), Permissions: permissions}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.CreateRole(ctx, role)
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("role", r.GetName()).Msg("can't create role")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("role", role.Name).Msg("created role")
+	return roleResponse(role), nil
+}
+
+// UpdateRole sets description and replaces permissions of the role, sessions get them on the next refresh.
+func (s *ManageService) UpdateRole(ctx context.Context, r *api.UpdateRoleRequest) (*api.Role, error) {
+	role, err := s.findRole(ctx, r.GetName())
+	if err != nil {
+		return nil, convert(err)
+	}
+	permissions, err := s.findPermissions(ctx, r.GetPermissions())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	role.Description = r.GetDescription()
+	role.Permissions = permissions
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.UpdateRole(ctx, role)
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("role", r.GetName()).Msg("can't update role")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("role", role.Name).Msg("updated role")
+	return roleResponse(role), nil
+}
+
+func (s *ManageService) GetRoles(ctx context.Context, r *api.GetRolesRequest) (*api.GetRolesResponse, error) {
+	roles, err := s.repo.GetRoles(ctx, model.RoleFilter{})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get role list")
+		return nil, convert(err)
+	}
+
+	list := make([]*api.Role, 0, len(roles))
+	for _, role := range roles {
+		list = append(list, roleResponse(role))
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int("count", len(list)).Msg("get role list")
+	return &api.GetRolesResponse{Roles: list}, nil
+}
+
+// DeleteRole unassigns the role from all users, sessions lose it on the next refresh.
+func (s *ManageService) DeleteRole(ctx context.Context, r *api.DeleteRoleRequest) (*api.DeleteRoleResponse, error) {
+	role, err := s.findRole(ctx, r.GetName())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.DeleteRole(ctx, role)
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("role", r.GetName()).Msg("can't delete role")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Str("role", r.GetName()).Msg("deleted role")
+	return &api.DeleteRoleResponse{Deleted: true}, nil
+}
+
+func (s *ManageService) AssignRole(ctx context.Context, r *api.AssignRoleRequest) (*api.UserRolesResponse, error) {
+	userRole, err := s.findUserRole(ctx, r.GetUserId(), r.GetRole())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	roles, err := s.repo.GetRoles(ctx, model.RoleFilter{UserID: userRole.UserID, Names: []string{r.GetRole()}})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userRole.UserID).Msg("can't get user roles")
+		return nil, convert(err)
+	}
+	// assigning the role again isn't an error
+	if len(roles) == 0 {
+		if err := s.repo.CreateUserRole(ctx, userRole); err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userRole.UserID).Str("role", r.GetRole()).Msg("can't assign role")
+			return nil, convert(err)
+		}
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userRole.UserID).Str("role", r.GetRole()).Msg("role assigned")
+	return s.userRoles(ctx, userRole.UserID)
+}
+
+func (s *ManageService) RevokeRole(ctx context.Context, r *api.RevokeRoleRequest) (*api.UserRolesResponse, error) {
+	userRole, err := s.findUserRole(ctx, r.GetUserId(), r.GetRole())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	if err := s.repo.DeleteUserRole(ctx, userRole); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userRole.UserID).Str("role", r.GetRole()).Msg("can't revoke role")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userRole.UserID).Str("role", r.GetRole()).Msg("role revoked")
+	return s.userRoles(ctx, userRole.UserID)
+}
+
+func (s *ManageService) GetUserRoles(ctx context.Context, r *api.GetUserRolesRequest) (*api.UserRolesResponse, error) {
+	if r.GetUserId() == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	return s.userRoles(ctx, r.GetUserId())
+}
+
+func (s *ManageService) userRoles(ctx context.Context, userID int64) (*api.UserRolesResponse, error) {
+	grants, err := userGrants(ctx, s.repo, userID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user roles")
+		return nil, convert(err)
+	}
+	return &api.UserRolesResponse{UserId: userID, Roles: grants.Roles, Permissions: grants.Permissions}, nil
+}
+
+func (s *ManageService) findRole(ctx context.Context, name string) (*model.Role, error) {
+	if name == "" {
+		return nil, errors.ErrBadRequest
+	}
+	role, err := s.repo.GetRole(ctx, name)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("role", name).Msg("can't get role")
+		return nil, err
+	} else if role == nil {
+		log.WithContext(ctx, s.logger).Info().Str("role", name).Msg("role not found")
+		return nil, errors.ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// findUserRole checks that both the user and the role exist.
+func (s *ManageService) findUserRole(ctx context.Context, userID int64, name string) (*model.UserRole, error) {
+	if userID == 0 {
+		return nil, errors.ErrBadRequest
+	}
+	role, err := s.findRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: userID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user by id")
+		return nil, err
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("user not found")
+		return nil, errors.ErrUserNotFound
+	}
+	return &model.UserRole{UserID: user.ID, RoleID: role.ID}, nil
+}
+
+// findPermissions returns permissions by names, all of them must exist.
+func (s *ManageService) findPermissions(ctx context.Context, names []string) (model.PermissionList, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	permissions, err := s.repo.GetPermissions(ctx, model.PermissionFilter{Names: names})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Strs("permissions", names).Msg("can't get permissions")
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		found[p.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			log.WithContext(ctx, s.logger).Info().Str("permission", name).Msg("permission not found")
+			return nil, errors.ErrPermissionNotFound
+		}
+	}
+	return permissions, nil
+}
+
+// userGrants returns current roles of the user and their permissions to put into access token.
+func userGrants(ctx context.Context, repo Repository, userID int64) (jwt.Grants, error) {
+	roles, err := repo.GetRoles(ctx, model.RoleFilter{UserID: userID})
+	if err != nil {
+		return jwt.Grants{}, err
+	}
+	names, permissions := roles.Grants()
+	return jwt.Grants{Roles: names, Permissions: permissions}, nil
+}
+
+func roleResponse(role *model.Role) *api.Role {
+	return &api.Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions.Names(),
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *ManageSuite) expectTransaction(ctx context.Context) {
+	s.repo.EXPECT().WithTransaction(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	}).Times(1)
+}
+
+func (s *ManageSuite) TestCreateRole_Success() {
+	ctx := context.Background()
+	permissions := model.PermissionList{{ID: 1, Name: "users:read"}, {ID: 2, Name: "users:write"}}
+
+	s.repo.EXPECT().GetPermissions(ctx, model.PermissionFilter{Names: []string{"users:read", "users:write"}}).Return(permissions, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().CreateRole(ctx, &model.Role{Name: "admin", Description: "Administrator", Permissions: permissions}).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateRole(ctx, &api.CreateRoleRequest{
+		Name:        "admin",
+		Description: "Administrator",
+		Permissions: []string{"users:read", "users:write"},
+	})
+	s.Require().NoError(err)
+	s.Equal(&api.Role{Name: "admin", Description: "Administrator", Permissions: []string{"users:read", "users:write"}}, resp)
+}
+
+func (s *ManageSuite) TestCreateRole_Error() {
+	ctx := context.Background()
+	service := NewManageService(s.repo, s.storage, s.logger)
+
+	resp, err := service.CreateRole(ctx, &api.CreateRoleRequest{})
+	s.Nil(resp)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	s.repo.EXPECT().GetPermissions(ctx, model.PermissionFilter{Names: []string{"users:read", "unknown"}}).
+		Return(model.PermissionList{{ID: 1, Name: "users:read"}}, nil).Times(1)
+	resp, err = service.CreateRole(ctx, &api.CreateRoleRequest{Name: "admin", Permissions: []string{"users:read", "unknown"}})
+	s.Nil(resp)
+	s.EqualError(err, "permission not found")
+	s.Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *ManageSuite) TestUpdateRole_Success() {
+	ctx := context.Background()
+	role := &model.Role{ID: 1, Name: "admin", Permissions: model.PermissionList{{ID: 1, Name: "users:read"}}}
+	permissions := model.PermissionList{{ID: 2, Name: "users:write"}}
+
+	s.repo.EXPECT().GetRole(ctx, "admin").Return(role, nil).Times(1)
+	s.repo.EXPECT().GetPermissions(ctx, model.PermissionFilter{Names: []string{"users:write"}}).Return(permissions, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().UpdateRole(ctx, role).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).UpdateRole(ctx, &api.UpdateRoleRequest{
+		Name:        "admin",
+		Description: "Administrator",
+		Permissions: []string{"users:write"},
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"users:write"}, resp.Permissions)
+	s.Equal("Administrator", role.Description)
+}
+
+func (s *ManageSuite) TestDeleteRole_Error() {
+	ctx := context.Background()
+	s.repo.EXPECT().GetRole(ctx, "unknown").Return(nil, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).DeleteRole(ctx, &api.DeleteRoleRequest{Name: "unknown"})
+	s.Nil(resp)
+	s.EqualError(err, "role not found")
+	s.Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *ManageSuite) TestAssignRole_Success() {
+	ctx := context.Background()
+	role := &model.Role{ID: 1, Name: "admin", Permissions: model.PermissionList{{ID: 1, Name: "users:read"}}}
+
+	s.repo.EXPECT().GetRole(ctx, "admin").Return(role, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123}, nil).Times(1)
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{UserID: 123, Names: []string{"admin"}}).Return(nil, nil).Times(1)
+	s.repo.EXPECT().CreateUserRole(ctx, &model.UserRole{UserID: 123, RoleID: 1}).Return(nil).Times(1)
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{UserID: 123}).Return(model.RoleList{role}, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).AssignRole(ctx, &api.AssignRoleRequest{UserId: 123, Role: "admin"})
+	s.Require().NoError(err)
+	s.Equal(&api.UserRolesResponse{UserId: 123, Roles: []string{"admin"}, Permissions: []string{"users:read"}}, resp)
+}
+
+func (s *ManageSuite) TestRevokeRole_Success() {
+	ctx := context.Background()
+
+	s.repo.EXPECT().GetRole(ctx, "admin").Return(&model.Role{ID: 1, Name: "admin"}, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123}, nil).Times(1)
+	s.repo.EXPECT().DeleteUserRole(ctx, &model.UserRole{UserID: 123, RoleID: 1}).Return(nil).Times(1)
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{UserID: 123}).Return(nil, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).RevokeRole(ctx, &api.RevokeRoleRequest{UserId: 123, Role: "admin"})
+	s.Require().NoError(err)
+	s.Equal(&api.UserRolesResponse{UserId: 123}, resp)
+}
+
+func (s *ManageSuite) TestAssignRole_Error() {
+	ctx := context.Background()
+
+	s.repo.EXPECT().GetRole(ctx, "admin").Return(&model.Role{ID: 1, Name: "admin"}, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(nil, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).AssignRole(ctx, &api.AssignRoleRequest{UserId: 123, Role: "admin"})
+	s.Nil(resp)
+	s.Equal(codes.NotFound, status.Code(err))
+}
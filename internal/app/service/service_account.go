@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
+	"strings"
+	"time"
+)
+
+const defaultServiceTokenTTL = 15 * time.Minute
+
+// api key is "<prefix>.<secret>", prefix is kept as is to find the key, the whole key is kept hashed
+const (
+	apiKeyPrefixSize = 12
+	apiKeySecretSize = 32
+	apiKeySeparator  = "."
+)
+
+// NewAccessTokenByAPIKey issues short-lived access token of service account of the key. The token has no
+// session and no refresh token, the key is exchanged again when the token expires.
+func (s *AuthService) NewAccessTokenByAPIKey(ctx context.Context, r *api.NewAccessTokenByAPIKeyRequest) (*api.Token, error) {
+	if r.GetApiKey() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+	prefix, ok := apiKeyPrefix(r.GetApiKey())
+	if !ok {
+		log.WithContext(ctx, s.logger).Info().Msg("malformed api key")
+		return nil, convert(errors.ErrAPIKeyInvalid)
+	}
+
+	key, err := s.repo.GetAPIKey(ctx, model.APIKeyFilter{Prefix: prefix})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("prefix", prefix).Msg("can't get api key")
+		return nil, convert(err)
+	} else if key == nil || key.IsRevoked() || !s.storage.MatchToken(key.KeyHash, r.GetApiKey()) {
+		log.WithContext(ctx, s.logger).Info().Str("prefix", prefix).Msg("invalid api key")
+		return nil, convert(errors.ErrAPIKeyInvalid)
+	}
+
+	token, err := s.storage.CreateServiceToken(key.ServiceAccountID, s.serviceTokenTTL)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", key.ServiceAccountID).Msg("can't create service token")
+		return nil, convert(err)
+	}
+
+	now := time.Now()
+	key.LastUsed = &now
+	if err := s.repo.UpdateAPIKey(ctx, key); err != nil {
+		// the token is issued anyway, last use is informational
+		log.WithContext(ctx, s.logger).Warn().Err(err).Int64("api_key_id", key.ID).Msg("can't update api key")
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", key.ServiceAccountID).Str("prefix", prefix).Msg("service token issued")
+	return &api.Token{Token: token.Value, ExpiresIn: token.ExpiresIn}, nil
+}
+
+// validateServiceToken describes service principal of the token, the token is rejected once its account is deleted.
+func (s *AuthService) validateServiceToken(ctx context.Context, accountID int64, tokenID uuid.UUID) (*api.ValidateTokenResponse, error) {
+	account, err := s.repo.GetServiceAccount(ctx, model.ServiceAccountFilter{ID: accountID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", accountID).Msg("can't get service account")
+		return nil, convert(err)
+	} else if account == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("service_account_id", accountID).Msg("service account not found")
+		return nil, convert(errors.ErrTokenInvalid)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", accountID).Msg("validate service token")
+	return &api.ValidateTokenResponse{
+		SessionId:        tokenID.String(),
+		Principal:        api.ValidateTokenResponse_SERVICE,
+		ServiceAccountId: account.ID,
+		ServiceAccount:   account.Name,
+	}, nil
+}
+
+func (s *ManageService) CreateServiceAccount(ctx context.Context, r *api.CreateServiceAccountRequest) (*api.ServiceAccount, error) {
+	if r.GetName() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	}
+
+	account := &model.ServiceAccount{Name: r.GetName(), Description: r.GetDescription()}
+	if err := s.repo.CreateServiceAccount(ctx, account); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("service_account", r.GetName()).Msg("can't create service account")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", account.ID).Msg("created service account")
+	return serviceAccountResponse(account), nil
+}
+
+func (s *ManageService) GetServiceAccounts(ctx context.Context, r *api.GetServiceAccountsRequest) (*api.GetServiceAccountsResponse, error) {
+	accounts, err := s.repo.GetServiceAccounts(ctx, model.ServiceAccountFilter{})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get service account list")
+		return nil, convert(err)
+	}
+
+	list := make([]*api.ServiceAccount, 0, len(accounts))
+	for _, account := range accounts {
+		list = append(list, serviceAccountResponse(account))
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int("count", len(list)).Msg("get service account list")
+	return &api.GetServiceAccountsResponse{ServiceAccounts: list}, nil
+}
+
+// DeleteServiceAccount deletes the account with its keys, issued tokens are rejected by ValidateToken at once.
+func (s *ManageService) DeleteServiceAccount(ctx context.Context, r *api.DeleteServiceAccountRequest) (*api.DeleteServiceAccountResponse, error) {
+	account, err := s.findServiceAccount(ctx, r.GetId())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.DeleteServiceAccount(ctx, account)
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", account.ID).Msg("can't delete service account")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", account.ID).Msg("deleted service account")
+	return &api.DeleteServiceAccountResponse{Deleted: true}, nil
+}
+
+// CreateAPIKey returns new key of the service account, the key itself is shown only here.
+func (s *ManageService) CreateAPIKey(ctx context.Context, r *api.CreateAPIKeyRequest) (*api.APIKeyResponse, error) {
+	account, err := s.findServiceAccount(ctx, r.GetServiceAccountId())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	key, value, err := s.newAPIKey(account.ID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", account.ID).Msg("can't generate api key")
+		return nil, convert(err)
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", account.ID).Msg("can't create api key")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", account.ID).Str("prefix", key.Prefix).Msg("created api key")
+	return &api.APIKeyResponse{Key: apiKeyResponse(key), ApiKey: value}, nil
+}
+
+func (s *ManageService) GetAPIKeys(ctx context.Context, r *api.GetAPIKeysRequest) (*api.GetAPIKeysResponse, error) {
+	account, err := s.findServiceAccount(ctx, r.GetServiceAccountId())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	keys, err := s.repo.GetAPIKeys(ctx, model.APIKeyFilter{ServiceAccountID: account.ID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", account.ID).Msg("can't get api key list")
+		return nil, convert(err)
+	}
+
+	list := make([]*api.APIKey, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, apiKeyResponse(key))
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", account.ID).Int("count", len(list)).Msg("get api key list")
+	return &api.GetAPIKeysResponse{Keys: list}, nil
+}
+
+// RotateAPIKey revokes the key and returns new key of the same service account.
+func (s *ManageService) RotateAPIKey(ctx context.Context, r *api.RotateAPIKeyRequest) (*api.APIKeyResponse, error) {
+	key, err := s.findAPIKey(ctx, r.GetId())
+	if err != nil {
+		return nil, convert(err)
+	} else if key.IsRevoked() {
+		log.WithContext(ctx, s.logger).Info().Int64("api_key_id", key.ID).Msg("api key is revoked")
+		return nil, convert(errors.ErrAPIKeyNotFound)
+	}
+
+	newKey, value, err := s.newAPIKey(key.ServiceAccountID)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", key.ServiceAccountID).Msg("can't generate api key")
+		return nil, convert(err)
+	}
+
+	now := time.Now()
+	key.Revoked = &now
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateAPIKey(ctx, key); err != nil {
+			return err
+		}
+		return s.repo.CreateAPIKey(ctx, newKey)
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("api_key_id", key.ID).Msg("can't rotate api key")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", key.ServiceAccountID).Str("prefix", newKey.Prefix).Msg("rotated api key")
+	return &api.APIKeyResponse{Key: apiKeyResponse(newKey), ApiKey: value}, nil
+}
+
+// RevokeAPIKey stops exchange of the key, tokens issued by it are valid until they expire.
+func (s *ManageService) RevokeAPIKey(ctx context.Context, r *api.RevokeAPIKeyRequest) (*api.APIKey, error) {
+	key, err := s.findAPIKey(ctx, r.GetId())
+	if err != nil {
+		return nil, convert(err)
+	}
+	// revoking the key again isn't an error
+	if key.IsRevoked() {
+		return apiKeyResponse(key), nil
+	}
+
+	now := time.Now()
+	key.Revoked = &now
+	if err := s.repo.UpdateAPIKey(ctx, key); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("api_key_id", key.ID).Msg("can't revoke api key")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("service_account_id", key.ServiceAccountID).Str("prefix", key.Prefix).Msg("revoked api key")
+	return apiKeyResponse(key), nil
+}
+
+func (s *ManageService) findServiceAccount(ctx context.Context, id int64) (*model.ServiceAccount, error) {
+	if id == 0 {
+		return nil, errors.ErrBadRequest
+	}
+	account, err := s.repo.GetServiceAccount(ctx, model.ServiceAccountFilter{ID: id})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("service_account_id", id).Msg("can't get service account")
+		return nil, err
+	} else if account == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("service_account_id", id).Msg("service account not found")
+		return nil, errors.ErrServiceAccountNotFound
+	}
+	return account, nil
+}
+
+func (s *ManageService) findAPIKey(ctx context.Context, id int64) (*model.APIKey, error) {
+	if id == 0 {
+		return nil, errors.ErrBadRequest
+	}
+	key, err := s.repo.GetAPIKey(ctx, model.APIKeyFilter{ID: id})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("api_key_id", id).Msg("can't get api key")
+		return nil, err
+	} else if key == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("api_key_id", id).Msg("api key not found")
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// newAPIKey generates key of the service account, returns its record and the key to give out.
+func (s *ManageService) newAPIKey(accountID int64) (*model.APIKey, string, error) {
+	prefix, err := random.Code(apiKeyPrefixSize)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := random.String(apiKeySecretSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	value := prefix + apiKeySeparator + secret
+	return &model.APIKey{
+		ServiceAccountID: accountID,
+		Prefix:           prefix,
+		KeyHash:          s.storage.HashToken(value),
+	}, value, nil
+}
+
+func apiKeyPrefix(key string) (string, bool) {
+	parts := strings.SplitN(key, apiKeySeparator, 2)
+	if len(parts) != 2 || len(parts[0]) != apiKeyPrefixSize || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func serviceAccountResponse(account *model.ServiceAccount) *api.ServiceAccount {
+	return &api.ServiceAccount{
+		Id:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		Created:     account.Created.Format(time.RFC3339),
+	}
+}
+
+func apiKeyResponse(key *model.APIKey) *api.APIKey {
+	resp := &api.APIKey{
+		Id:               key.ID,
+		ServiceAccountId: key.ServiceAccountID,
+		Prefix:           key.Prefix,
+		Created:          key.Created.Format(time.RFC3339),
+	}
+	if key.LastUsed != nil {
+		resp.LastUsed = key.LastUsed.Format(time.RFC3339)
+	}
+	if key.Revoked != nil {
+		resp.Revoked = key.Revoked.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+func (s *AuthSuite) TestNewAccessTokenByAPIKey_Success() {
+	ctx := context.Background()
+	apiKey := "abcdefghjkmn.secret"
+	key := &model.APIKey{ID: 1, ServiceAccountID: 7, Prefix: "abcdefghjkmn", KeyHash: "hash"}
+
+	s.repo.EXPECT().GetAPIKey(ctx, model.APIKeyFilter{Prefix: "abcdefghjkmn"}).Return(key, nil).Times(1)
+	s.storage.EXPECT().MatchToken("hash", apiKey).Return(true).Times(1)
+	s.storage.EXPECT().CreateServiceToken(int64(7), time.Minute).Return(storage.Token{Value: "service", ExpiresIn: 111}, nil).Times(1)
+	s.repo.EXPECT().UpdateAPIKey(ctx, key).Return(nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger, WithServiceTokenTTL(time.Minute)).
+		NewAccessTokenByAPIKey(ctx, &api.NewAccessTokenByAPIKeyRequest{ApiKey: apiKey})
+	s.Require().NoError(err)
+	s.Equal(&api.Token{Token: "service", ExpiresIn: 111}, resp)
+	s.NotNil(key.LastUsed)
+}
+
+func (s *AuthSuite) TestNewAccessTokenByAPIKey_Error() {
+	ctx := context.Background()
+	service := NewAuthService(s.repo, s.storage, s.logger)
+	revoked := time.Now()
+
+	resp, err := service.NewAccessTokenByAPIKey(ctx, &api.NewAccessTokenByAPIKeyRequest{ApiKey: "malformed"})
+	s.Nil(resp)
+	s.EqualError(err, "invalid api key")
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	s.repo.EXPECT().GetAPIKey(ctx, model.APIKeyFilter{Prefix: "abcdefghjkmn"}).
+		Return(&model.APIKey{ID: 1, ServiceAccountID: 7, KeyHash: "hash", Revoked: &revoked}, nil).Times(1)
+	resp, err = service.NewAccessTokenByAPIKey(ctx, &api.NewAccessTokenByAPIKeyRequest{ApiKey: "abcdefghjkmn.secret"})
+	s.Nil(resp)
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	s.repo.EXPECT().GetAPIKey(ctx, model.APIKeyFilter{Prefix: "abcdefghjkmn"}).
+		Return(&model.APIKey{ID: 1, ServiceAccountID: 7, KeyHash: "hash"}, nil).Times(1)
+	s.storage.EXPECT().MatchToken("hash", "abcdefghjkmn.wrong").Return(false).Times(1)
+	resp, err = service.NewAccessTokenByAPIKey(ctx, &api.NewAccessTokenByAPIKeyRequest{ApiKey: "abcdefghjkmn.wrong"})
+	s.Nil(resp)
+	s.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *AuthSuite) TestValidateToken_Service() {
+	ctx := context.Background()
+	tokenID := uuid.NewV4()
+	s.storage.EXPECT().DecodeToken("service", jwt.TokenTypeAccess).Return(int64(0), uuid.Nil, jwt.ErrInvalidType).Times(1)
+	s.storage.EXPECT().DecodeToken("service", jwt.TokenTypeService).Return(int64(7), tokenID, nil).Times(1)
+	s.repo.EXPECT().GetServiceAccount(ctx, model.ServiceAccountFilter{ID: 7}).Return(&model.ServiceAccount{ID: 7, Name: "billing"}, nil).Times(1)
+
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).ValidateToken(ctx, &api.ValidateTokenRequest{Token: "service"})
+	s.Require().NoError(err)
+	s.Equal(&api.ValidateTokenResponse{
+		SessionId:        tokenID.String(),
+		Principal:        api.ValidateTokenResponse_SERVICE,
+		ServiceAccountId: 7,
+		ServiceAccount:   "billing",
+	}, resp)
+
+	s.storage.EXPECT().DecodeToken("service", jwt.TokenTypeAccess).Return(int64(0), uuid.Nil, jwt.ErrInvalidType).Times(1)
+	s.storage.EXPECT().DecodeToken("service", jwt.TokenTypeService).Return(int64(7), tokenID, nil).Times(1)
+	s.repo.EXPECT().GetServiceAccount(ctx, model.ServiceAccountFilter{ID: 7}).Return(nil, nil).Times(1)
+
+	resp, err = NewAuthService(s.repo, s.storage, s.logger).ValidateToken(ctx, &api.ValidateTokenRequest{Token: "service"})
+	s.Nil(resp)
+	s.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *ManageSuite) TestCreateAPIKey_Success() {
+	ctx := context.Background()
+	var created *model.APIKey
+
+	s.repo.EXPECT().GetServiceAccount(ctx, model.ServiceAccountFilter{ID: 7}).Return(&model.ServiceAccount{ID: 7}, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).Return("hash").Times(1)
+	s.repo.EXPECT().CreateAPIKey(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, key *model.APIKey) error {
+		created = key
+		return nil
+	}).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateAPIKey(ctx, &api.CreateAPIKeyRequest{ServiceAccountId: 7})
+	s.Require().NoError(err)
+	s.Equal(int64(7), created.ServiceAccountID)
+	s.Equal("hash", created.KeyHash)
+	s.True(strings.HasPrefix(resp.ApiKey, created.Prefix+"."))
+	prefix, ok := apiKeyPrefix(resp.ApiKey)
+	s.True(ok)
+	s.Equal(created.Prefix, prefix)
+	s.Equal(created.Prefix, resp.Key.Prefix)
+}
+
+func (s *ManageSuite) TestCreateAPIKey_Error() {
+	ctx := context.Background()
+	s.repo.EXPECT().GetServiceAccount(ctx, model.ServiceAccountFilter{ID: 7}).Return(nil, nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).CreateAPIKey(ctx, &api.CreateAPIKeyRequest{ServiceAccountId: 7})
+	s.Nil(resp)
+	s.EqualError(err, "service account not found")
+	s.Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *ManageSuite) TestRotateAPIKey_Success() {
+	ctx := context.Background()
+	key := &model.APIKey{ID: 1, ServiceAccountID: 7, Prefix: "abcdefghjkmn"}
+
+	s.repo.EXPECT().GetAPIKey(ctx, model.APIKeyFilter{ID: 1}).Return(key, nil).Times(1)
+	s.storage.EXPECT().HashToken(gomock.Any()).Return("hash").Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().UpdateAPIKey(ctx, key).Return(nil).Times(1)
+	s.repo.EXPECT().CreateAPIKey(ctx, gomock.Any()).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).RotateAPIKey(ctx, &api.RotateAPIKeyRequest{Id: 1})
+	s.Require().NoError(err)
+	s.True(key.IsRevoked())
+	s.Equal(int64(7), resp.Key.ServiceAccountId)
+	s.NotEqual(key.Prefix, resp.Key.Prefix)
+	s.NotEmpty(resp.ApiKey)
+}
+
+func (s *ManageSuite) TestRevokeAPIKey_Success() {
+	ctx := context.Background()
+	key := &model.APIKey{ID: 1, ServiceAccountID: 7, Prefix: "abcdefghjkmn"}
+
+	s.repo.EXPECT().GetAPIKey(ctx, model.APIKeyFilter{ID: 1}).Return(key, nil).Times(2)
+	s.repo.EXPECT().UpdateAPIKey(ctx, key).Return(nil).Times(1)
+
+	service := NewManageService(s.repo, s.storage, s.logger)
+	resp, err := service.RevokeAPIKey(ctx, &api.RevokeAPIKeyRequest{Id: 1})
+	s.Require().NoError(err)
+	s.NotEmpty(resp.Revoked)
+
+	// already revoked key isn't updated
+	resp, err = service.RevokeAPIKey(ctx, &api.RevokeAPIKeyRequest{Id: 1})
+	s.Require().NoError(err)
+	s.NotEmpty(resp.Revoked)
+}
+
+func (s *ManageSuite) TestDeleteServiceAccount_Success() {
+	ctx := context.Background()
+	account := &model.ServiceAccount{ID: 7, Name: "billing"}
+
+	s.repo.EXPECT().GetServiceAccount(ctx, model.ServiceAccountFilter{ID: 7}).Return(account, nil).Times(1)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().DeleteServiceAccount(ctx, account).Return(nil).Times(1)
+
+	resp, err := NewManageService(s.repo, s.storage, s.logger).DeleteServiceAccount(ctx, &api.DeleteServiceAccountRequest{Id: 7})
+	s.Require().NoError(err)
+	s.True(resp.Deleted)
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"golang.org/x/sync/errgroup"
+)
+
+// deleteSessions removes sessions of the tokens from storage, already expired sessions are skipped.
+func deleteSessions(storage Storage, tokens model.RefreshTokenList) error {
+	eg := errgroup.Group{}
+	for _, token := range tokens {
+		sessionID := token.SessionID
+		eg.Go(func() error {
+			if err := storage.DeleteSessionByUUID(sessionID); err != nil && err != redis.ErrRecordNotFound {
+				return err
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/metrics"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+)
+
+// loginThrottleKeys returns keys of login attempt, login key goes first.
+func (o options) loginThrottleKeys(ctx context.Context, login string) []throttle.Key {
+	keys := []throttle.Key{throttle.LoginKey(login)}
+	if ip := o.clientIP(ctx); ip != "" {
+		keys = append(keys, throttle.IPKey(ip))
+	}
+	return keys
+}
+
+// resetThrottleKeys returns keys of password reset request, they are counted apart from failed logins,
+// so requests of somebody else don't lock the user out.
+func (o options) resetThrottleKeys(ctx context.Context, login string) []throttle.Key {
+	keys := []throttle.Key{throttle.ResetKey(login)}
+	if ip := o.clientIP(ctx); ip != "" {
+		keys = append(keys, throttle.ResetIPKey(ip))
+	}
+	return keys
+}
+
+// checkThrottle rejects attempts of delayed or locked out keys. Throttler failures don't block login.
+func (s *AuthService) checkThrottle(ctx context.Context, keys []throttle.Key) error {
+	if s.throttler == nil {
+		return nil
+	}
+
+	retryAfter, err := s.throttler.Check(keys...)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't check login throttling")
+		return nil
+	} else if retryAfter > 0 {
+		log.WithContext(ctx, s.logger).Info().Str("kind", string(keys[0].Kind)).Str("value", keys[0].Value).Dur("retry_after", retryAfter).Msg("login throttled")
+		return &errors.RetryAfterError{Err: errors.ErrTooManyAttempts, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// failThrottle counts failure of all keys and tells if the first key is locked out.
+func (s *AuthService) failThrottle(ctx context.Context, keys []throttle.Key) bool {
+	return s.countThrottle(ctx, keys, metrics.EventLoginLockout, "security event: login locked out")
+}
+
+// countResetThrottle counts password reset request, reaching the threshold is its own security event.
+func (s *AuthService) countResetThrottle(ctx context.Context, keys []throttle.Key) {
+	s.countThrottle(ctx, keys, metrics.EventResetLockout, "security event: password reset locked out")
+}
+
+func (s *AuthService) countThrottle(ctx context.Context, keys []throttle.Key, event, msg string) bool {
+	if s.throttler == nil {
+		return false
+	}
+
+	lockouts, err := s.throttler.Fail(keys...)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("event", event).Msg("can't count throttled attempt")
+		return false
+	}
+	var locked bool
+	for _, l := range lockouts {
+		if l.Locked {
+			locked = locked || l.Key == keys[0]
+			log.WithContext(ctx, s.logger).Warn().
+				Str("event", event).
+				Str("kind", string(l.Kind)).
+				Str("value", l.Value).
+				Int64("failures", l.Failures).
+				Dur("retry_after", l.RetryAfter).
+				Msg(msg)
+			metrics.SecurityEvent(event)
+		}
+	}
+	return locked
+}
+
+// resetThrottle forgets failures of the first key after success, failures of ip are kept.
+func (s *AuthService) resetThrottle(ctx context.Context, keys []throttle.Key) {
+	if s.throttler == nil {
+		return
+	}
+	if err := s.throttler.Reset(keys[0]); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't reset login throttling")
+	}
+}
+
+// verifyPassword checks password of signed in user. Failures are counted with failed logins of the user,
+// so stolen access token doesn't give unlimited guesses.
+func (s *AuthService) verifyPassword(ctx context.Context, user *model.User, pwd string) error {
+	keys := s.loginThrottleKeys(ctx, user.Login)
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return err
+	}
+
+	if !user.IsPasswordCorrect(s.hasher, pwd) {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", user.ID).Msg("incorrect password")
+		if s.failThrottle(ctx, keys) {
+			s.notifyLockout(ctx, user.ID)
+		}
+		return errors.ErrIncorrectPassword
+	}
+	s.resetThrottle(ctx, keys)
+	return nil
+}
+
+// lockoutKeys returns keys requested by ManageService, empty values are skipped.
+func lockoutKeys(login, ip string) []throttle.Key {
+	var keys []throttle.Key
+	if login != "" {
+		keys = append(keys, throttle.LoginKey(login))
+	}
+	if ip != "" {
+		keys = append(keys, throttle.IPKey(ip))
+	}
+	return keys
+}
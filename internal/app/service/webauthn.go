@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/metrics"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/pkg/errors"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/sanches1984/msa-auth/pkg/webauthn"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"strconv"
+	"time"
+)
+
+// BeginWebAuthnRegistration returns options of navigator.credentials.create for the user of access token.
+func (s *AuthService) BeginWebAuthnRegistration(ctx context.Context, r *api.BeginWebAuthnRegistrationRequest) (*api.WebAuthnOptionsResponse, error) {
+	if r.GetToken() == "" {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.webAuthn == nil {
+		return nil, convert(errors.ErrWebAuthnNotConfigured)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: userID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Msg("user not found")
+		return nil, convert(errors.ErrUserNotFound)
+	}
+
+	credentials, err := s.repo.GetWebAuthnCredentials(ctx, model.WebAuthnCredentialFilter{UserID: userID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get webauthn credentials")
+		return nil, convert(err)
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create webauthn challenge")
+		return nil, convert(err)
+	}
+	webAuthnUser := webauthn.User{ID: userHandle(userID), Name: user.Login, DisplayName: user.Login}
+	options, err := s.webAuthn.CreationOptions(webAuthnUser, challenge, credentialIDs(credentials))
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create webauthn options")
+		return nil, convert(err)
+	}
+
+	return s.webAuthnOptions(ctx, storage.WebAuthnChallenge{UserID: userID, Challenge: challenge}, options)
+}
+
+// FinishWebAuthnRegistration verifies new credential created by options of BeginWebAuthnRegistration and keeps it.
+func (s *AuthService) FinishWebAuthnRegistration(ctx context.Context, r *api.FinishWebAuthnRegistrationRequest) (*api.FinishWebAuthnRegistrationResponse, error) {
+	if r.GetToken() == "" || r.GetChallengeToken() == "" || len(r.GetCredential()) == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.webAuthn == nil {
+		return nil, convert(errors.ErrWebAuthnNotConfigured)
+	}
+	userID, err := s.sessionUser(ctx, r.GetToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	challenge, err := s.spendWebAuthnChallenge(ctx, r.GetChallengeToken())
+	if err != nil {
+		return nil, convert(err)
+	} else if challenge.UserID != userID {
+		log.WithContext(ctx, s.logger).Warn().Int64("user_id", userID).Msg("webauthn challenge of another user")
+		return nil, convert(errors.ErrTokenInvalid)
+	}
+
+	credential, err := s.webAuthn.VerifyRegistration(challenge.Challenge, r.GetCredential())
+	if err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Int64("user_id", userID).Msg("webauthn registration failed")
+		return nil, convert(errors.ErrCredentialInvalid)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateWebAuthnCredential(ctx, &model.WebAuthnCredential{
+			UserID:       userID,
+			CredentialID: credentialID,
+			PublicKey:    credential.PublicKey,
+			SignCount:    int64(credential.SignCount),
+			Name:         r.GetName(),
+		}); err != nil {
+			return err
+		}
+		return s.notify(ctx, s.repo, userID, notifier.KindMFAEnabled, map[string]string{"method": mfaMethodWebAuthn})
+	})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", userID).Msg("can't create webauthn credential")
+		return nil, convert(err)
+	}
+
+	log.WithContext(ctx, s.logger).Info().Int64("user_id", userID).Str("credential_id", credentialID).Msg("webauthn credential registered")
+	return &api.FinishWebAuthnRegistrationResponse{CredentialId: credentialID}, nil
+}
+
+// BeginWebAuthnLogin returns options of navigator.credentials.get. Without login any discoverable credential is accepted.
+// Unknown login gets options without credentials, so logins can't be enumerated.
+func (s *AuthService) BeginWebAuthnLogin(ctx context.Context, r *api.BeginWebAuthnLoginRequest) (*api.WebAuthnOptionsResponse, error) {
+	if s.webAuthn == nil {
+		return nil, convert(errors.ErrWebAuthnNotConfigured)
+	}
+
+	state := storage.WebAuthnChallenge{}
+	var allow [][]byte
+	if r.GetLogin() != "" {
+		user, err := s.repo.GetUser(ctx, model.UserFilter{Login: r.GetLogin()})
+		if err != nil {
+			log.WithContext(ctx, s.logger).Error().Err(err).Str("login", r.GetLogin()).Msg("can't get user by login")
+			return nil, convert(err)
+		} else if user != nil {
+			credentials, err := s.repo.GetWebAuthnCredentials(ctx, model.WebAuthnCredentialFilter{UserID: user.ID})
+			if err != nil {
+				log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't get webauthn credentials")
+				return nil, convert(err)
+			}
+			state.UserID = user.ID
+			allow = credentialIDs(credentials)
+		}
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't create webauthn challenge")
+		return nil, convert(err)
+	}
+	state.Challenge = challenge
+	options, err := s.webAuthn.RequestOptions(challenge, allow)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't create webauthn options")
+		return nil, convert(err)
+	}
+
+	return s.webAuthnOptions(ctx, state, options)
+}
+
+// FinishWebAuthnLogin verifies assertion made by options of BeginWebAuthnLogin and creates session like Login.
+func (s *AuthService) FinishWebAuthnLogin(ctx context.Context, r *api.FinishWebAuthnLoginRequest) (*api.TokenResponse, error) {
+	if r.GetChallengeToken() == "" || len(r.GetCredential()) == 0 {
+		return nil, convert(errors.ErrBadRequest)
+	} else if s.webAuthn == nil {
+		return nil, convert(errors.ErrWebAuthnNotConfigured)
+	}
+
+	challenge, err := s.spendWebAuthnChallenge(ctx, r.GetChallengeToken())
+	if err != nil {
+		return nil, convert(err)
+	}
+	assertion, err := webauthn.ParseAssertion(r.GetCredential())
+	if err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Msg("invalid webauthn assertion")
+		return nil, convert(errors.ErrCredentialInvalid)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(assertion.CredentialID)
+	credential, err := s.repo.GetWebAuthnCredential(ctx, model.WebAuthnCredentialFilter{CredentialID: credentialID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Str("credential_id", credentialID).Msg("can't get webauthn credential")
+		return nil, convert(err)
+	} else if credential == nil {
+		log.WithContext(ctx, s.logger).Info().Str("credential_id", credentialID).Msg("webauthn credential not found")
+		return nil, convert(errors.ErrCredentialInvalid)
+	}
+	if (challenge.UserID != 0 && challenge.UserID != credential.UserID) ||
+		(len(assertion.UserHandle) != 0 && !bytes.Equal(assertion.UserHandle, userHandle(credential.UserID))) {
+		log.WithContext(ctx, s.logger).Warn().Int64("user_id", credential.UserID).Msg("webauthn credential of another user")
+		return nil, convert(errors.ErrCredentialInvalid)
+	}
+
+	signCount, err := s.webAuthn.VerifyAssertion(challenge.Challenge, assertion, webauthn.Credential{
+		ID:        assertion.CredentialID,
+		PublicKey: credential.PublicKey,
+		SignCount: uint32(credential.SignCount),
+	})
+	if err == webauthn.ErrSignCount {
+		log.WithContext(ctx, s.logger).Warn().
+			Str("event", metrics.EventWebAuthnClone).
+			Int64("user_id", credential.UserID).
+			Str("credential_id", credentialID).
+			Msg("security event: webauthn signature counter didn't increase")
+		metrics.SecurityEvent(metrics.EventWebAuthnClone)
+		return nil, convert(errors.ErrCredentialInvalid)
+	} else if err != nil {
+		log.WithContext(ctx, s.logger).Info().Err(err).Int64("user_id", credential.UserID).Msg("webauthn assertion failed")
+		return nil, convert(errors.ErrCredentialInvalid)
+	}
+
+	user, err := s.repo.GetUser(ctx, model.UserFilter{ID: credential.UserID})
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", credential.UserID).Msg("can't get user by id")
+		return nil, convert(err)
+	} else if user == nil {
+		log.WithContext(ctx, s.logger).Info().Int64("user_id", credential.UserID).Msg("user not found")
+		return nil, convert(errors.ErrCredentialInvalid)
+	}
+
+	now := time.Now()
+	credential.SignCount = int64(signCount)
+	credential.LastUsed = &now
+	if err := s.repo.UpdateWebAuthnCredential(ctx, credential); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", user.ID).Msg("can't update webauthn credential")
+		return nil, convert(err)
+	}
+
+	resp, err := s.createSession(ctx, user.ID, r.GetData())
+	if err != nil {
+		return nil, convert(err)
+	}
+	return resp, nil
+}
+
+func (s *AuthService) webAuthnOptions(ctx context.Context, challenge storage.WebAuthnChallenge, options []byte) (*api.WebAuthnOptionsResponse, error) {
+	token, err := s.storage.CreateWebAuthnChallenge(challenge)
+	if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", challenge.UserID).Msg("can't save webauthn challenge")
+		return nil, convert(err)
+	}
+	return &api.WebAuthnOptionsResponse{ChallengeToken: token.Value, Options: options}, nil
+}
+
+// spendWebAuthnChallenge returns state of ceremony and deletes it, so every challenge is answered once.
+func (s *AuthService) spendWebAuthnChallenge(ctx context.Context, token string) (*storage.WebAuthnChallenge, error) {
+	challenge, err := s.storage.GetWebAuthnChallenge(token)
+	if err == redis.ErrRecordNotFound {
+		return nil, errors.ErrTokenInvalid
+	} else if err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Msg("can't get webauthn challenge")
+		return nil, err
+	}
+
+	if err := s.storage.DeleteWebAuthnChallenge(token); err != nil {
+		log.WithContext(ctx, s.logger).Error().Err(err).Int64("user_id", challenge.UserID).Msg("can't delete webauthn challenge")
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// userHandle is webauthn user id, it's opaque for authenticators.
+func userHandle(userID int64) []byte {
+	return []byte(strconv.FormatInt(userID, 10))
+}
+
+func credentialIDs(credentials model.WebAuthnCredentialList) [][]byte {
+	ids := make([][]byte, 0, len(credentials))
+	for _, c := range credentials {
+		if id, err := base64.RawURLEncoding.DecodeString(c.CredentialID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/storage"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/sanches1984/msa-auth/pkg/webauthn"
+	"github.com/sanches1984/msa-auth/pkg/webauthn/virtual"
+	api "github.com/sanches1984/msa-auth/proto/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *AuthSuite) newWebAuthn() (*webauthn.WebAuthn, *virtual.Authenticator) {
+	w, err := webauthn.New(webauthn.Config{RPID: "example.com", Origins: []string{"https://example.com"}, RequireUserVerification: true})
+	s.Require().NoError(err)
+	authenticator, err := virtual.New("https://example.com")
+	s.Require().NoError(err)
+	return w, authenticator
+}
+
+// expectWebAuthnChallenge keeps challenge created by service in memory instead of redis.
+func (s *AuthSuite) expectWebAuthnChallenge(token string) {
+	var saved storage.WebAuthnChallenge
+	s.storage.EXPECT().CreateWebAuthnChallenge(gomock.Any()).DoAndReturn(func(challenge storage.WebAuthnChallenge) (storage.Token, error) {
+		saved = challenge
+		return storage.Token{Value: token}, nil
+	}).Times(1)
+	s.storage.EXPECT().GetWebAuthnChallenge(token).DoAndReturn(func(string) (*storage.WebAuthnChallenge, error) {
+		return &saved, nil
+	}).Times(1)
+	s.storage.EXPECT().DeleteWebAuthnChallenge(token).Return(nil).Times(1)
+}
+
+func (s *AuthSuite) registerWebAuthn(ctx context.Context, service *AuthService, authenticator *virtual.Authenticator) *model.WebAuthnCredential {
+	s.expectActiveSession(123)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().GetWebAuthnCredentials(ctx, model.WebAuthnCredentialFilter{UserID: 123}).Return(nil, nil).Times(1)
+	s.expectWebAuthnChallenge("registration")
+
+	begin, err := service.BeginWebAuthnRegistration(ctx, &api.BeginWebAuthnRegistrationRequest{Token: "access"})
+	s.Require().NoError(err)
+	s.Equal("registration", begin.ChallengeToken)
+	credential, err := authenticator.Register(begin.Options)
+	s.Require().NoError(err)
+
+	var created *model.WebAuthnCredential
+	s.expectActiveSession(123)
+	s.expectTransaction(ctx)
+	s.repo.EXPECT().CreateWebAuthnCredential(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, c *model.WebAuthnCredential) error {
+		created = c
+		return nil
+	}).Times(1)
+
+	finish, err := service.FinishWebAuthnRegistration(ctx, &api.FinishWebAuthnRegistrationRequest{
+		Token:          "access",
+		ChallengeToken: begin.ChallengeToken,
+		Credential:     credential,
+		Name:           "laptop",
+	})
+	s.Require().NoError(err)
+	s.Equal(base64.RawURLEncoding.EncodeToString(authenticator.CredentialID()), finish.CredentialId)
+	s.Equal(int64(123), created.UserID)
+	s.Equal("laptop", created.Name)
+	return created
+}
+
+func (s *AuthSuite) TestWebAuthn_Success() {
+	ctx := context.Background()
+	w, authenticator := s.newWebAuthn()
+	service := NewAuthService(s.repo, s.storage, s.logger, WithWebAuthn(w))
+	credential := s.registerWebAuthn(ctx, service, authenticator)
+
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{Login: "login"}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().GetWebAuthnCredentials(ctx, model.WebAuthnCredentialFilter{UserID: 123}).
+		Return(model.WebAuthnCredentialList{credential}, nil).Times(1)
+	s.expectWebAuthnChallenge("login")
+
+	begin, err := service.BeginWebAuthnLogin(ctx, &api.BeginWebAuthnLoginRequest{Login: "login"})
+	s.Require().NoError(err)
+	s.Contains(string(begin.Options), credential.CredentialID)
+	assertion, err := authenticator.Login(begin.Options)
+	s.Require().NoError(err)
+
+	s.repo.EXPECT().GetWebAuthnCredential(ctx, model.WebAuthnCredentialFilter{CredentialID: credential.CredentialID}).Return(credential, nil).Times(1)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.repo.EXPECT().UpdateWebAuthnCredential(ctx, credential).DoAndReturn(func(ctx context.Context, c *model.WebAuthnCredential) error {
+		s.Equal(int64(1), c.SignCount)
+		s.NotNil(c.LastUsed)
+		return nil
+	}).Times(1)
+	s.expectSession(ctx, 123)
+
+	resp, err := service.FinishWebAuthnLogin(ctx, &api.FinishWebAuthnLoginRequest{
+		ChallengeToken: begin.ChallengeToken,
+		Credential:     assertion,
+		Data:           []byte("data"),
+	})
+	s.NoError(err)
+	s.Equal("access", resp.Access.Token)
+	s.Equal("refresh", resp.Refresh.Token)
+}
+
+func (s *AuthSuite) TestWebAuthn_Error() {
+	ctx := context.Background()
+	w, authenticator := s.newWebAuthn()
+	service := NewAuthService(s.repo, s.storage, s.logger, WithWebAuthn(w))
+
+	// not configured
+	resp, err := NewAuthService(s.repo, s.storage, s.logger).BeginWebAuthnLogin(ctx, &api.BeginWebAuthnLoginRequest{})
+	s.Nil(resp)
+	s.Equal(codes.FailedPrecondition, status.Code(err))
+
+	credential := s.registerWebAuthn(ctx, service, authenticator)
+
+	// discoverable credential, its counter went back
+	credential.SignCount = 10
+	s.expectWebAuthnChallenge("login")
+	begin, err := service.BeginWebAuthnLogin(ctx, &api.BeginWebAuthnLoginRequest{})
+	s.Require().NoError(err)
+	assertion, err := authenticator.Login(begin.Options)
+	s.Require().NoError(err)
+	s.repo.EXPECT().GetWebAuthnCredential(ctx, model.WebAuthnCredentialFilter{CredentialID: credential.CredentialID}).Return(credential, nil).Times(1)
+
+	_, err = service.FinishWebAuthnLogin(ctx, &api.FinishWebAuthnLoginRequest{ChallengeToken: begin.ChallengeToken, Credential: assertion})
+	s.EqualError(err, "invalid credential")
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	// challenge was spent or expired
+	s.storage.EXPECT().GetWebAuthnChallenge("login").Return(nil, redis.ErrRecordNotFound).Times(1)
+	_, err = service.FinishWebAuthnLogin(ctx, &api.FinishWebAuthnLoginRequest{ChallengeToken: "login", Credential: assertion})
+	s.EqualError(err, "invalid token")
+}
@@ -0,0 +1,168 @@
+package authz
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"github.com/rs/zerolog"
+	log "github.com/sanches1984/gopkg-logger"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/certs"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const (
+	headerAuthorization = "authorization"
+	headerAPIKey        = "x-api-key"
+	bearerPrefix        = "bearer "
+)
+
+var errPermissionDenied = status.Error(codes.PermissionDenied, "admin credential required")
+
+type Config struct {
+	// Services are full names of grpc services which require admin credential, e.g. auth.ManageService
+	Services []string
+	// AdminRole is role of users whose access tokens are accepted
+	AdminRole string
+	// APIKeys are static keys accepted in x-api-key header
+	APIKeys []string
+	// ClientNames are common names or dns names of verified client certificates accepted as admin
+	ClientNames []string
+}
+
+// Authorizer lets calls of protected services through only with admin credential:
+// bearer access token of user with admin role, static api key or verified client certificate.
+type Authorizer struct {
+	storage     Storage
+	repo        Repository
+	logger      zerolog.Logger
+	services    map[string]bool
+	adminRole   string
+	apiKeys     [][sha256.Size]byte
+	clientNames map[string]bool
+}
+
+func New(storage Storage, repo Repository, logger zerolog.Logger, config Config) *Authorizer {
+	a := &Authorizer{
+		storage:     storage,
+		repo:        repo,
+		logger:      logger,
+		services:    make(map[string]bool, len(config.Services)),
+		adminRole:   config.AdminRole,
+		clientNames: make(map[string]bool, len(config.ClientNames)),
+	}
+	for _, service := range config.Services {
+		a.services[service] = true
+	}
+	for _, key := range config.APIKeys {
+		if key != "" {
+			a.apiKeys = append(a.apiKeys, sha256.Sum256([]byte(key)))
+		}
+	}
+	for _, name := range config.ClientNames {
+		if name != "" {
+			a.clientNames[name] = true
+		}
+	}
+	return a
+}
+
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if a.isProtected(info.FullMethod) && !a.isAdmin(ctx) {
+			log.WithContext(ctx, a.logger).Warn().Str("method", info.FullMethod).Msg("admin credential required")
+			return nil, errPermissionDenied
+		}
+		return handler(ctx, req)
+	}
+}
+
+// isProtected tells if method of "/package.Service/Method" form belongs to protected service.
+func (a *Authorizer) isProtected(method string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(method, "/"), "/", 2)
+	return a.services[parts[0]]
+}
+
+func (a *Authorizer) isAdmin(ctx context.Context) bool {
+	if a.hasClientCertificate(ctx) {
+		return true
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, key := range md.Get(headerAPIKey) {
+		if a.hasAPIKey(key) {
+			return true
+		}
+	}
+	for _, value := range md.Get(headerAuthorization) {
+		if len(value) > len(bearerPrefix) && strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			if a.hasAdminRole(ctx, value[len(bearerPrefix):]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAPIKey compares hashes of keys in constant time, so the time doesn't tell the key.
+func (a *Authorizer) hasAPIKey(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	var found int
+	for i := range a.apiKeys {
+		found |= subtle.ConstantTimeCompare(sum[:], a.apiKeys[i][:])
+	}
+	return found == 1
+}
+
+// hasAdminRole checks that token is access token of active session of user with admin role. Roles are loaded
+// from database, not from the token, so revoked role takes effect at once.
+func (a *Authorizer) hasAdminRole(ctx context.Context, token string) bool {
+	if a.adminRole == "" {
+		return false
+	}
+	userID, _, err := a.storage.DecodeToken(token, jwt.TokenTypeAccess)
+	if err != nil {
+		log.WithContext(ctx, a.logger).Info().Err(err).Msg("can't decode admin token")
+		return false
+	}
+	if _, err := a.storage.GetSessionData(token); err != nil {
+		log.WithContext(ctx, a.logger).Info().Err(err).Int64("user_id", userID).Msg("admin session not found")
+		return false
+	}
+	roles, err := a.repo.GetRoles(ctx, model.RoleFilter{Names: []string{a.adminRole}, UserID: userID})
+	if err != nil {
+		log.WithContext(ctx, a.logger).Error().Err(err).Int64("user_id", userID).Msg("can't get admin roles")
+		return false
+	}
+	return len(roles) != 0
+}
+
+// hasClientCertificate checks name of client certificate verified by tls handshake.
+func (a *Authorizer) hasClientCertificate(ctx context.Context) bool {
+	if len(a.clientNames) == 0 {
+		return false
+	}
+	cert := certs.ClientCertificate(ctx)
+	return cert != nil && a.hasClientName(cert)
+}
+
+func (a *Authorizer) hasClientName(cert *x509.Certificate) bool {
+	if a.clientNames[cert.Subject.CommonName] {
+		return true
+	}
+	for _, name := range cert.DNSNames {
+		if a.clientNames[name] {
+			return true
+		}
+	}
+	return false
+}
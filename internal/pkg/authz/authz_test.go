@@ -0,0 +1,117 @@
+package authz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/authz/mocks"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+type AuthorizerSuite struct {
+	suite.Suite
+
+	ctrl    *gomock.Controller
+	storage *mocks.MockStorage
+	repo    *mocks.MockRepository
+}
+
+func (s *AuthorizerSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.storage = mocks.NewMockStorage(s.ctrl)
+	s.repo = mocks.NewMockRepository(s.ctrl)
+}
+
+func (s *AuthorizerSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func TestAuthorizer(t *testing.T) {
+	suite.Run(t, new(AuthorizerSuite))
+}
+
+func (s *AuthorizerSuite) call(ctx context.Context, method string) error {
+	interceptor := New(s.storage, s.repo, zerolog.Nop(), Config{
+		Services:    []string{"auth.ManageService"},
+		AdminRole:   "admin",
+		APIKeys:     []string{"secret"},
+		ClientNames: []string{"admin-client"},
+	}).UnaryServerInterceptor()
+
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	return err
+}
+
+func (s *AuthorizerSuite) TestPublicService() {
+	s.NoError(s.call(context.Background(), "/auth.AuthService/Login"))
+}
+
+func (s *AuthorizerSuite) TestNoCredential() {
+	err := s.call(context.Background(), "/auth.ManageService/CreateUser")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "wrong"))
+	err = s.call(ctx, "/auth.ManageService/CreateUser")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *AuthorizerSuite) TestAPIKey() {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "secret"))
+	s.NoError(s.call(ctx, "/auth.ManageService/CreateUser"))
+}
+
+func (s *AuthorizerSuite) TestAdminToken() {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer admin"))
+	s.storage.EXPECT().DecodeToken("admin", jwt.TokenTypeAccess).Return(int64(1), uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("admin").Return([]byte("data"), nil).Times(1)
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{Names: []string{"admin"}, UserID: 1}).Return(model.RoleList{{Name: "admin"}}, nil).Times(1)
+	s.NoError(s.call(ctx, "/auth.ManageService/DeleteUser"))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer user"))
+	s.storage.EXPECT().DecodeToken("user", jwt.TokenTypeAccess).Return(int64(2), uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("user").Return([]byte("data"), nil).Times(1)
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{Names: []string{"admin"}, UserID: 2}).Return(nil, nil).Times(1)
+	err := s.call(ctx, "/auth.ManageService/DeleteUser")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	// admin role is revoked after the token is issued
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer revoked"))
+	s.storage.EXPECT().DecodeToken("revoked", jwt.TokenTypeAccess).Return(int64(3), uuid.NewV4(), nil).Times(1)
+	s.storage.EXPECT().GetSessionData("revoked").Return([]byte("data"), nil).Times(1)
+	s.repo.EXPECT().GetRoles(ctx, model.RoleFilter{Names: []string{"admin"}, UserID: 3}).Return(model.RoleList{}, nil).Times(1)
+	err = s.call(ctx, "/auth.ManageService/DeleteUser")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer refresh"))
+	s.storage.EXPECT().DecodeToken("refresh", jwt.TokenTypeAccess).Return(int64(0), uuid.Nil, jwt.ErrInvalidType).Times(1)
+	err = s.call(ctx, "/auth.ManageService/DeleteUser")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *AuthorizerSuite) TestClientCertificate() {
+	tlsPeer := func(name string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		}})
+	}
+
+	s.NoError(s.call(tlsPeer("admin-client"), "/auth.ManageService/GetUsers"))
+	err := s.call(tlsPeer("other-client"), "/auth.ManageService/GetUsers")
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,17 @@
+package authz
+
+import (
+	"context"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	uuid "github.com/satori/go.uuid"
+)
+
+type Storage interface {
+	DecodeToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error)
+	GetSessionData(token string) ([]byte, error)
+}
+
+type Repository interface {
+	GetRoles(ctx context.Context, filter model.RoleFilter) (model.RoleList, error)
+}
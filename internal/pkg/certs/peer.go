@@ -0,0 +1,29 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientCertificate returns client certificate verified by tls handshake of grpc peer, nil if there is none.
+func ClientCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+// ClientSubject returns distinguished name of verified client certificate, empty without one.
+func ClientSubject(ctx context.Context) string {
+	if cert := ClientCertificate(ctx); cert != nil {
+		return cert.Subject.String()
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoClientCA = errors.New("no certificates in client ca file")
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile turns on mutual tls, client certificates must be signed by one of its certificates
+	ClientCAFile string
+	// Interval is pause between checks of files modification
+	Interval time.Duration
+}
+
+// Reloader keeps server certificate and client ca loaded from files and reloads them when files change,
+// so certificates are renewed without restart. Failed reload keeps previous certificates.
+type Reloader struct {
+	config Config
+	logger zerolog.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running int32
+}
+
+func New(config Config, logger zerolog.Logger) (*Reloader, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Reloader{
+		config: config,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if err := r.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns server config using current certificates on every handshake.
+// GetCertificate is set too, so http.Server starts by ListenAndServeTLS without files.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			cfg := &tls.Config{
+				Certificates: []tls.Certificate{*r.cert},
+				MinVersion:   tls.VersionTLS12,
+			}
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// Reload loads files again if any of them is modified since the last load.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	changed := false
+	for file, modTime := range modTimes {
+		changed = changed || !modTime.Equal(r.modTimes[file])
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	if err := r.load(); err != nil {
+		return err
+	}
+	r.logger.Info().Str("cert", r.config.CertFile).Msg("certificates reloaded")
+	return nil
+}
+
+// Run checks files until Close, it's run once.
+func (r *Reloader) Run() {
+	if !atomic.CompareAndSwapInt32(&r.running, 0, 1) {
+		return
+	}
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				r.logger.Error().Err(err).Str("cert", r.config.CertFile).Msg("can't reload certificates")
+			}
+		}
+	}
+}
+
+func (r *Reloader) Close() {
+	r.cancel()
+	if !atomic.CompareAndSwapInt32(&r.running, 0, 2) {
+		<-r.done
+	}
+}
+
+func (r *Reloader) load() error {
+	// times are taken first, so changes made while loading are loaded next time
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return ErrNoClientCA
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
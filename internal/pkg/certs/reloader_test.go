@@ -0,0 +1,161 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ReloaderSuite struct {
+	suite.Suite
+
+	dir    string
+	config Config
+}
+
+func (s *ReloaderSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "certs")
+	s.Require().NoError(err)
+	s.dir = dir
+	s.config = Config{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		Interval:     time.Minute,
+	}
+}
+
+func (s *ReloaderSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func TestReloader(t *testing.T) {
+	suite.Run(t, new(ReloaderSuite))
+}
+
+// writeCert writes self-signed certificate with common name to files, modification time is moved by shift.
+func (s *ReloaderSuite) writeCert(name string, shift time.Duration) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	s.Require().NoError(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	s.Require().NoError(err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	files := map[string][]byte{
+		s.config.CertFile:     certPEM,
+		s.config.KeyFile:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		s.config.ClientCAFile: certPEM,
+	}
+	modTime := time.Now().Add(shift)
+	for file, data := range files {
+		if file == "" {
+			continue
+		}
+		s.Require().NoError(ioutil.WriteFile(file, data, 0600))
+		s.Require().NoError(os.Chtimes(file, modTime, modTime))
+	}
+}
+
+func (s *ReloaderSuite) serverConfig(r *Reloader) *tls.Config {
+	cfg, err := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	s.Require().NoError(err)
+	return cfg
+}
+
+func (s *ReloaderSuite) commonName(cfg *tls.Config) string {
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	s.Require().NoError(err)
+	return cert.Subject.CommonName
+}
+
+func (s *ReloaderSuite) TestReload() {
+	s.writeCert("first", -time.Hour)
+	r, err := New(s.config, zerolog.Nop())
+	s.Require().NoError(err)
+
+	cfg := s.serverConfig(r)
+	s.Equal("first", s.commonName(cfg))
+	s.Equal(tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+
+	// files aren't changed
+	s.Require().NoError(r.Reload())
+	s.Equal("first", s.commonName(s.serverConfig(r)))
+
+	s.writeCert("second", 0)
+	s.Require().NoError(r.Reload())
+	s.Equal("second", s.commonName(s.serverConfig(r)))
+	cert, err := r.TLSConfig().GetCertificate(&tls.ClientHelloInfo{})
+	s.Require().NoError(err)
+	s.Equal("second", s.commonName(&tls.Config{Certificates: []tls.Certificate{*cert}}))
+}
+
+func (s *ReloaderSuite) TestReload_KeepsPrevious() {
+	s.writeCert("first", -time.Hour)
+	r, err := New(s.config, zerolog.Nop())
+	s.Require().NoError(err)
+
+	s.Require().NoError(ioutil.WriteFile(s.config.ClientCAFile, []byte("broken"), 0600))
+	s.Equal(ErrNoClientCA, r.Reload())
+	s.Equal("first", s.commonName(s.serverConfig(r)))
+}
+
+func (s *ReloaderSuite) TestNew_Error() {
+	_, err := New(s.config, zerolog.Nop())
+	s.Error(err)
+
+	s.config.ClientCAFile = ""
+	s.writeCert("plain", 0)
+	r, err := New(s.config, zerolog.Nop())
+	s.Require().NoError(err)
+	s.Equal(tls.NoClientCert, s.serverConfig(r).ClientAuth)
+}
+
+func (s *ReloaderSuite) TestRunClose() {
+	s.writeCert("first", -time.Hour)
+	s.config.Interval = 10 * time.Millisecond
+	r, err := New(s.config, zerolog.Nop())
+	s.Require().NoError(err)
+
+	go r.Run()
+	s.writeCert("second", 0)
+	s.Eventually(func() bool {
+		return s.commonName(s.serverConfig(r)) == "second"
+	}, time.Second, 10*time.Millisecond)
+	r.Close()
+}
+
+func (s *ReloaderSuite) TestClientSubject() {
+	s.Empty(ClientSubject(context.Background()))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client", Organization: []string{"org"}}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+	s.Equal(cert, ClientCertificate(ctx))
+	s.Equal("CN=client,O=org", ClientSubject(ctx))
+}
@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"encoding/json"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"net/http"
+	"strings"
+)
+
+const (
+	openIDConfigurationPath = "/.well-known/openid-configuration"
+	jwksPath                = "/.well-known/jwks.json"
+	cacheControl            = "public, max-age=300"
+)
+
+type KeySet interface {
+	JWKS() jwt.JWKS
+}
+
+type Service struct {
+	httpServer *http.Server
+	issuer     string
+	keys       KeySet
+}
+
+type configuration struct {
+	Issuer                           string   `json:"issuer"`
+	JwksURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+func NewService(addr, issuer string, keys KeySet) *Service {
+	s := &Service{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		keys:   keys,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(openIDConfigurationPath, s.openIDConfiguration)
+	mux.HandleFunc(jwksPath, s.jwks)
+	s.httpServer = &http.Server{Handler: mux, Addr: addr}
+	return s
+}
+
+func (s *Service) Listen() error {
+	return s.httpServer.ListenAndServe()
+}
+
+func (s *Service) Close() error {
+	return s.httpServer.Close()
+}
+
+func (s *Service) openIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	algs := make([]string, 0)
+	for _, key := range s.keys.JWKS().Keys {
+		algs = appendUnique(algs, key.Alg)
+	}
+
+	writeJSON(w, r, configuration{
+		Issuer:                           s.issuer,
+		JwksURI:                          s.issuer + jwksPath,
+		ResponseTypesSupported:           []string{"token"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: algs,
+	})
+}
+
+func (s *Service) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, s.keys.JWKS())
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,49 @@
+package discovery
+
+import (
+	"encoding/json"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type staticKeySet jwt.JWKS
+
+func (s staticKeySet) JWKS() jwt.JWKS {
+	return jwt.JWKS(s)
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	keys := staticKeySet{Keys: []jwt.JWK{{Kid: "1", Alg: "RS256"}, {Kid: "2", Alg: "RS256"}, {Kid: "3", Alg: "EdDSA"}}}
+	s := NewService("", "https://auth.example.com/", keys)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openIDConfigurationPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var conf configuration
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conf))
+	require.Equal(t, "https://auth.example.com", conf.Issuer)
+	require.Equal(t, "https://auth.example.com/.well-known/jwks.json", conf.JwksURI)
+	require.Equal(t, []string{"RS256", "EdDSA"}, conf.IDTokenSigningAlgValuesSupported)
+}
+
+func TestJWKS(t *testing.T) {
+	keys := staticKeySet{Keys: []jwt.JWK{{Kty: "OKP", Kid: "1", Alg: "EdDSA", Crv: "Ed25519", X: "abc"}}}
+	s := NewService("", "https://auth.example.com", keys)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, jwksPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var set jwt.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	require.Equal(t, jwt.JWKS(keys), set)
+
+	rec = httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, jwksPath, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -8,6 +8,14 @@ const namespace = "auth_service"
 
 const (
 	fieldMethodName = "grpc_method"
+	fieldEvent      = "event"
+)
+
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventLoginLockout      = "login_lockout"
+	EventResetLockout      = "password_reset_lockout"
+	EventWebAuthnClone     = "webauthn_clone"
 )
 
 var requestTimeHist = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -15,3 +23,13 @@ var requestTimeHist = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 	Name:      "request_duration_seconds",
 	Help:      "Request duration per grpc method.",
 }, []string{fieldMethodName})
+
+var securityEventCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: namespace,
+	Name:      "security_events_total",
+	Help:      "Security events count per event type.",
+}, []string{fieldEvent})
+
+func SecurityEvent(event string) {
+	securityEventCounter.WithLabelValues(event).Inc()
+}
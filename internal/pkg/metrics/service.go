@@ -20,6 +20,7 @@ func NewService(addr string) *Service {
 	grpcMetrics := grpc_prometheus.NewServerMetrics()
 	reg.MustRegister(grpcMetrics)
 	reg.MustRegister(requestTimeHist)
+	reg.MustRegister(securityEventCounter)
 
 	return &Service{
 		httpServer:  &http.Server{Handler: promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), Addr: addr},
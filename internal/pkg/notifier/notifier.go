@@ -0,0 +1,132 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindPasswordReset          Kind = "password_reset"
+	KindEmailVerification      Kind = "email_verification"
+	KindPasswordChanged        Kind = "password_changed"
+	KindNewLogin               Kind = "new_login"
+	KindLoginLocked            Kind = "login_locked"
+	KindMFAEnabled             Kind = "mfa_enabled"
+	KindMFADisabled            Kind = "mfa_disabled"
+	KindRecoveryCodesGenerated Kind = "recovery_codes_generated"
+)
+
+type Type string
+
+const (
+	TypeLog    Type = "log"
+	TypeFile   Type = "file"
+	TypeStdout Type = "stdout"
+	TypeSMTP   Type = "smtp"
+)
+
+var ErrUnknownType = errors.New("unknown notifier type")
+
+// ErrNoRecipient means the message can't be delivered at all, so it's useless to retry.
+var ErrNoRecipient = errors.New("no recipient address")
+
+// DataToken and DataExpiresAt keep Token and ExpiresAt of message queued in the outbox,
+// the token is kept encrypted with TokenAdditionalData. DataEmail keeps Email of message sent
+// to address that isn't verified yet, e.g. email verification.
+const (
+	DataToken     = "token"
+	DataExpiresAt = "expires_at"
+	DataEmail     = "email"
+)
+
+// TokenAdditionalData binds encrypted token of the outbox to the user and kind of message.
+func TokenAdditionalData(userID int64, kind Kind) []byte {
+	return []byte(string(kind) + ":" + strconv.FormatInt(userID, 10))
+}
+
+// Message is notification of the user, Token is secret to deliver, e.g. password reset token.
+// Email is address to deliver to, it's empty if the user has no verified email.
+// Data has details of security event, e.g. ip of new login.
+type Message struct {
+	Kind      Kind              `json:"kind"`
+	UserID    int64             `json:"user_id"`
+	Login     string            `json:"login"`
+	Email     string            `json:"email,omitempty"`
+	Token     string            `json:"token,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+// Log writes messages to log, it's for local use only since tokens get into logs.
+type Log struct {
+	logger zerolog.Logger
+}
+
+func NewLog(logger zerolog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Send(msg Message) error {
+	event := l.logger.Info().
+		Str("kind", string(msg.Kind)).
+		Int64("user_id", msg.UserID).
+		Str("login", msg.Login).
+		Str("email", msg.Email)
+	if msg.Token != "" {
+		event = event.Str("token", msg.Token).Time("expires_at", msg.ExpiresAt)
+	}
+	for k, v := range msg.Data {
+		event = event.Str(k, v)
+	}
+	event.Msg("notification")
+	return nil
+}
+
+// File appends messages to file or stdout as JSON lines, it's for local use and tests of clients.
+type File struct {
+	path string
+	w    io.Writer
+	mu   sync.Mutex
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func NewStdout() *File {
+	return &File{w: os.Stdout}
+}
+
+func (f *File) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.w != nil {
+		_, err := f.w.Write(data)
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	f := NewFile(path)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	require.NoError(t, f.Send(Message{Kind: KindPasswordReset, UserID: 1, Login: "first", Token: "token1", ExpiresAt: expiresAt}))
+	require.NoError(t, f.Send(Message{Kind: KindPasswordReset, UserID: 2, Login: "second", Token: "token2", ExpiresAt: expiresAt}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var msg Message
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &msg))
+	require.Equal(t, KindPasswordReset, msg.Kind)
+	require.Equal(t, int64(2), msg.UserID)
+	require.Equal(t, "token2", msg.Token)
+	require.True(t, expiresAt.Equal(msg.ExpiresAt))
+}
+
+func TestSMTP(t *testing.T) {
+	s := NewSMTP(SMTPConfig{Addr: "localhost:25", From: "auth@example.com"})
+	var sent []byte
+	s.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		require.Equal(t, "localhost:25", addr)
+		require.Equal(t, "auth@example.com", from)
+		require.Equal(t, []string{"user@example.com"}, to)
+		sent = msg
+		return nil
+	}
+
+	require.NoError(t, s.Send(Message{
+		Kind:   KindNewLogin,
+		Login:  "login",
+		Email:  "user@example.com",
+		Data:   map[string]string{"ip": "127.0.0.1"},
+		UserID: 1,
+	}))
+	require.Contains(t, string(sent), "Subject: New login\r\n")
+	require.Contains(t, string(sent), "There is new login to login.\r\nip: 127.0.0.1\r\n")
+
+	require.ErrorIs(t, s.Send(Message{Kind: KindNewLogin, Login: "login"}), ErrNoRecipient)
+}
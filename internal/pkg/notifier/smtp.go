@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type SMTPConfig struct {
+	// Addr is host:port of mail server
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP sends messages as plain text emails to verified email of the user.
+type SMTP struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+type email struct {
+	subject string
+	body    *template.Template
+}
+
+var emails = map[Kind]email{
+	KindPasswordReset: {
+		subject: "Password reset",
+		body:    template.Must(template.New("").Parse("Use this token to set new password of {{.Login}}: {{.Token}}\nIt expires at {{.ExpiresAt}}.\n")),
+	},
+	KindEmailVerification: {
+		subject: "Email verification",
+		body:    template.Must(template.New("").Parse("Use this token to verify email of {{.Login}}: {{.Token}}\nIt expires at {{.ExpiresAt}}.\n")),
+	},
+	KindPasswordChanged: {
+		subject: "Password changed",
+		body:    template.Must(template.New("").Parse("Password of {{.Login}} was changed.\n")),
+	},
+	KindNewLogin: {
+		subject: "New login",
+		body:    template.Must(template.New("").Parse("There is new login to {{.Login}}.\n")),
+	},
+	KindLoginLocked: {
+		subject: "Login locked",
+		body:    template.Must(template.New("").Parse("Login to {{.Login}} is temporarily locked after too many failed attempts.\n")),
+	},
+	KindMFAEnabled: {
+		subject: "Two-factor authentication enabled",
+		body:    template.Must(template.New("").Parse("Two-factor authentication of {{.Login}} was enabled.\n")),
+	},
+	KindMFADisabled: {
+		subject: "Two-factor authentication disabled",
+		body:    template.Must(template.New("").Parse("Two-factor authentication of {{.Login}} was disabled.\n")),
+	},
+	KindRecoveryCodesGenerated: {
+		subject: "Recovery codes generated",
+		body:    template.Must(template.New("").Parse("New recovery codes of {{.Login}} were generated, previous ones don't work anymore.\n")),
+	},
+}
+
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	s := &SMTP{cfg: cfg, send: smtp.SendMail}
+	if cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(msg Message) error {
+	if msg.Email == "" {
+		return ErrNoRecipient
+	}
+	e, ok := emails[msg.Kind]
+	if !ok {
+		return fmt.Errorf("unknown message kind: %s", msg.Kind)
+	}
+
+	body := &bytes.Buffer{}
+	if err := e.body.Execute(body, msg); err != nil {
+		return err
+	}
+	// details are appended in stable order
+	keys := make([]string, 0, len(msg.Data))
+	for k := range msg.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(body, "%s: %s\n", k, msg.Data[k])
+	}
+
+	data := &bytes.Buffer{}
+	fmt.Fprintf(data, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(data, "To: %s\r\n", msg.Email)
+	fmt.Fprintf(data, "Subject: %s\r\n", e.subject)
+	fmt.Fprintf(data, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	data.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	data.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+
+	return s.send(s.cfg.Addr, s.auth, s.cfg.From, []string{msg.Email}, data.Bytes())
+}
@@ -0,0 +1,66 @@
+package oauth
+
+import "net/http"
+
+const (
+	GrantTypePassword          = "password"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+
+	TokenTypeBearer = "Bearer"
+)
+
+var (
+	ErrInvalidRequest       = &Error{Code: "invalid_request", status: http.StatusBadRequest}
+	ErrInvalidClient        = &Error{Code: "invalid_client", status: http.StatusUnauthorized}
+	ErrInvalidGrant         = &Error{Code: "invalid_grant", status: http.StatusBadRequest}
+	ErrUnauthorizedClient   = &Error{Code: "unauthorized_client", status: http.StatusBadRequest}
+	ErrUnsupportedGrantType = &Error{Code: "unsupported_grant_type", status: http.StatusBadRequest}
+	ErrServerError          = &Error{Code: "server_error", status: http.StatusInternalServerError}
+)
+
+// TokenRequest is request of token endpoint, client credentials are taken from basic auth or the form.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+	RefreshToken string
+	Scope        string
+}
+
+// Token is successful response of token endpoint, ExpiresIn is lifetime of access token in seconds.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// Error is error response of token endpoint (RFC 6749, section 5.2).
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	status      int
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// WithDescription returns copy of the error with human readable description.
+func (e *Error) WithDescription(description string) *Error {
+	err := *e
+	err.Description = description
+	return &err
+}
+
+// Is makes errors of the same code equal for errors.Is regardless of description.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
@@ -0,0 +1,167 @@
+package oauth
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+const tokenPath = "/oauth/token"
+
+type Issuer interface {
+	OAuthToken(ctx context.Context, r TokenRequest) (*Token, error)
+}
+
+type Service struct {
+	httpServer *http.Server
+	issuer     Issuer
+	logger     zerolog.Logger
+	tlsConfig  *tls.Config
+	newContext func(ctx context.Context) context.Context
+}
+
+type Option func(s *Service)
+
+// WithContext sets function preparing context of every request, e.g. with database connection.
+func WithContext(fn func(ctx context.Context) context.Context) Option {
+	return func(s *Service) {
+		s.newContext = fn
+	}
+}
+
+// WithTLS serves the endpoint over https with the config, e.g. of certs.Reloader.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Service) {
+		s.tlsConfig = config
+	}
+}
+
+func NewService(addr string, issuer Issuer, logger zerolog.Logger, opts ...Option) *Service {
+	s := &Service{
+		issuer: issuer,
+		logger: logger,
+		newContext: func(ctx context.Context) context.Context {
+			return ctx
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPath, s.token)
+	s.httpServer = &http.Server{Handler: mux, Addr: addr, TLSConfig: s.tlsConfig}
+	return s
+}
+
+// Listen serves https if tls config is set, certificates are taken from the config.
+func (s *Service) Listen() error {
+	if s.httpServer.TLSConfig != nil {
+		return s.httpServer.ListenAndServeTLS("", "")
+	}
+	return s.httpServer.ListenAndServe()
+}
+
+func (s *Service) Close() error {
+	return s.httpServer.Close()
+}
+
+func (s *Service) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, ErrInvalidRequest.WithDescription("malformed form"), false)
+		return
+	}
+
+	req := TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Username:     r.PostForm.Get("username"),
+		Password:     r.PostForm.Get("password"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+	}
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// only one authentication method is allowed
+		if req.ClientSecret != "" {
+			writeError(w, ErrInvalidRequest.WithDescription("multiple client authentication methods"), basic)
+			return
+		}
+		var err error
+		if req.ClientID, err = url.QueryUnescape(id); err != nil {
+			writeError(w, ErrInvalidClient, basic)
+			return
+		}
+		if req.ClientSecret, err = url.QueryUnescape(secret); err != nil {
+			writeError(w, ErrInvalidClient, basic)
+			return
+		}
+	}
+	if req.GrantType == "" {
+		writeError(w, ErrInvalidRequest.WithDescription("grant_type is required"), basic)
+		return
+	} else if req.ClientID == "" || req.ClientSecret == "" {
+		writeError(w, ErrInvalidClient, basic)
+		return
+	}
+
+	token, err := s.issuer.OAuthToken(s.newContext(requestContext(r)), req)
+	if err != nil {
+		var oauthErr *Error
+		if !errors.As(err, &oauthErr) {
+			s.logger.Error().Err(err).Str("grant_type", req.GrantType).Msg("can't issue oauth token")
+			oauthErr = ErrServerError
+		}
+		writeError(w, oauthErr, basic)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, token)
+}
+
+// requestContext passes address and user agent of http client the same way as of grpc one,
+// so throttling and notifications see the client. X-Forwarded-For is passed as is, the service
+// honors it only when remote address is a trusted proxy, like for grpc calls.
+func requestContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	if value := r.Header.Get("X-Forwarded-For"); value != "" {
+		md.Set("x-forwarded-for", value)
+	}
+	if value := r.UserAgent(); value != "" {
+		md.Set("user-agent", value)
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	return ctx
+}
+
+func writeError(w http.ResponseWriter, err *Error, basic bool) {
+	if err.status == http.StatusUnauthorized && basic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	writeJSON(w, err.status, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
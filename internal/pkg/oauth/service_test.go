@@ -0,0 +1,154 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type issuerFunc func(ctx context.Context, r TokenRequest) (*Token, error)
+
+func (f issuerFunc) OAuthToken(ctx context.Context, r TokenRequest) (*Token, error) {
+	return f(ctx, r)
+}
+
+func post(s *Service, form url.Values, configure func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, tokenPath, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if configure != nil {
+		configure(r)
+	}
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) Error {
+	var resp Error
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp
+}
+
+func TestToken(t *testing.T) {
+	var got TokenRequest
+	var gotCtx context.Context
+	s := NewService("", issuerFunc(func(ctx context.Context, r TokenRequest) (*Token, error) {
+		got = r
+		gotCtx = ctx
+		return &Token{AccessToken: "access", TokenType: TokenTypeBearer, ExpiresIn: 60, RefreshToken: "refresh"}, nil
+	}), zerolog.Nop())
+
+	rec := post(s, url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"pass"}}, func(r *http.Request) {
+		r.SetBasicAuth("client", url.QueryEscape("se:cret"))
+		r.Header.Set("User-Agent", "test-agent")
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	require.Equal(t, TokenRequest{GrantType: "password", ClientID: "client", ClientSecret: "se:cret", Username: "user", Password: "pass"}, got)
+	p, ok := peer.FromContext(gotCtx)
+	require.True(t, ok)
+	require.Equal(t, "192.0.2.1:1234", p.Addr.String())
+	md, _ := metadata.FromIncomingContext(gotCtx)
+	require.Equal(t, []string{"test-agent"}, md.Get("user-agent"))
+
+	var token Token
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	require.Equal(t, Token{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 60, RefreshToken: "refresh"}, token)
+
+	// credentials in the form
+	rec = post(s, url.Values{"grant_type": {"client_credentials"}, "client_id": {"client"}, "client_secret": {"secret"}}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "secret", got.ClientSecret)
+}
+
+func TestToken_Error(t *testing.T) {
+	s := NewService("", issuerFunc(func(ctx context.Context, r TokenRequest) (*Token, error) {
+		if r.Password == "wrong" {
+			return nil, ErrInvalidGrant.WithDescription("invalid login or password")
+		}
+		return nil, errors.New("database is down")
+	}), zerolog.Nop())
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tokenPath, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = post(s, url.Values{"client_id": {"client"}, "client_secret": {"secret"}}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, "invalid_request", decodeError(t, rec).Code)
+
+	rec = post(s, url.Values{"grant_type": {"password"}}, func(r *http.Request) {
+		r.SetBasicAuth("client", "")
+	})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, `Basic realm="oauth"`, rec.Header().Get("WWW-Authenticate"))
+	require.Equal(t, "invalid_client", decodeError(t, rec).Code)
+
+	rec = post(s, url.Values{"grant_type": {"password"}, "client_secret": {"secret"}}, func(r *http.Request) {
+		r.SetBasicAuth("client", "secret")
+	})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, "invalid_request", decodeError(t, rec).Code)
+
+	rec = post(s, url.Values{"grant_type": {"password"}, "client_id": {"client"}, "client_secret": {"secret"}, "password": {"wrong"}}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, Error{Code: "invalid_grant", Description: "invalid login or password"}, decodeError(t, rec))
+
+	rec = post(s, url.Values{"grant_type": {"password"}, "client_id": {"client"}, "client_secret": {"secret"}}, nil)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, Error{Code: "server_error"}, decodeError(t, rec))
+}
+
+func TestListen_TLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	// free port for the server
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	s := NewService(addr, issuerFunc(func(ctx context.Context, r TokenRequest) (*Token, error) {
+		return &Token{AccessToken: "access", TokenType: TokenTypeBearer}, nil
+	}), zerolog.Nop(), WithTLS(&tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}))
+	go func() { _ = s.Listen() }()
+	defer s.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"client"}, "client_secret": {"secret"}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = client.PostForm("https://"+addr+tokenPath, form)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, resp.TLS)
+}
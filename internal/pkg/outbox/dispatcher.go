@@ -0,0 +1,232 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errUserNotFound = errors.New("user not found")
+	errTokenInvalid = errors.New("token can't be decrypted")
+)
+
+type Config struct {
+	// Interval is pause between polls of the outbox
+	Interval  time.Duration
+	BatchSize int32
+	// MaxAttempts is number of failed deliveries before message is given up
+	MaxAttempts int
+	// RetryDelay is delay after the first failure, it's doubled on every next one up to MaxRetryDelay
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Lease is time claimed messages are hidden from other dispatchers while they are delivered
+	Lease time.Duration
+}
+
+type Option func(d *Dispatcher)
+
+// WithContext sets function preparing context of every poll, e.g. with database connection.
+func WithContext(fn func(ctx context.Context) context.Context) Option {
+	return func(d *Dispatcher) {
+		d.newContext = fn
+	}
+}
+
+// Dispatcher delivers messages queued in the outbox by notifier. Every batch is claimed in short transaction
+// by moving its next attempt after the lease, so several dispatchers don't deliver the same message
+// and no transaction is open while notifier sends.
+type Dispatcher struct {
+	repo       Repository
+	notifier   Notifier
+	box        *secretbox.Box
+	logger     zerolog.Logger
+	config     Config
+	newContext func(ctx context.Context) context.Context
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running int32
+}
+
+// New creates dispatcher, box decrypts tokens of the messages.
+func New(repo Repository, n Notifier, box *secretbox.Box, logger zerolog.Logger, config Config, opts ...Option) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		repo:       repo,
+		notifier:   n,
+		box:        box,
+		logger:     logger,
+		config:     config,
+		newContext: func(ctx context.Context) context.Context { return ctx },
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run polls the outbox until Close, it's run once.
+func (d *Dispatcher) Run() {
+	if !atomic.CompareAndSwapInt32(&d.running, 0, 1) {
+		return
+	}
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+	for {
+		// full batch tells there may be more due messages
+		for d.ctx.Err() == nil {
+			if d.Dispatch(d.newContext(d.ctx)) < int(d.config.BatchSize) {
+				break
+			}
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops polling and waits for the current batch.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	if !atomic.CompareAndSwapInt32(&d.running, 0, 2) {
+		<-d.done
+	}
+}
+
+// Dispatch delivers one batch of due messages and returns its size. Result of every message is saved
+// on its own, message with unsaved result is delivered again after the lease.
+func (d *Dispatcher) Dispatch(ctx context.Context) int {
+	messages, err := d.claim(ctx)
+	if err != nil {
+		d.logger.Error().Err(err).Msg("can't claim outbox messages")
+		return 0
+	}
+
+	for _, m := range messages {
+		d.deliver(ctx, m)
+		if err := d.repo.UpdateOutboxMessage(ctx, m); err != nil {
+			d.logger.Error().Err(err).Int64("id", m.ID).Int64("user_id", m.UserID).Msg("can't save outbox message")
+		}
+	}
+	return len(messages)
+}
+
+// claim locks batch of due messages and postpones them for the lease, the lock is released on return.
+func (d *Dispatcher) claim(ctx context.Context) (model.OutboxMessageList, error) {
+	var messages model.OutboxMessageList
+	err := d.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		messages, err = d.repo.GetPendingOutboxMessages(ctx, d.config.BatchSize, d.config.MaxAttempts)
+		if err != nil {
+			return err
+		}
+
+		leaseUntil := time.Now().Add(d.config.Lease)
+		for _, m := range messages {
+			m.NextAttempt = leaseUntil
+			if err := d.repo.UpdateOutboxMessage(ctx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// deliver sends the message and updates its state, failures are retried with backoff.
+// Token of the message is erased when it's sent or given up.
+func (d *Dispatcher) deliver(ctx context.Context, m *model.OutboxMessage) {
+	err := d.send(ctx, m)
+	if err == nil {
+		now := time.Now()
+		m.Sent = &now
+		m.Error = ""
+		m.Data = withoutToken(m.Data)
+		return
+	}
+
+	m.Attempts++
+	m.Error = err.Error()
+	if errors.Is(err, notifier.ErrNoRecipient) || err == errUserNotFound || err == errTokenInvalid {
+		m.Attempts = d.config.MaxAttempts
+	}
+	if m.Attempts >= d.config.MaxAttempts {
+		m.Data = withoutToken(m.Data)
+		d.logger.Warn().Err(err).Int64("id", m.ID).Int64("user_id", m.UserID).Str("kind", string(m.Kind)).Msg("notification dropped")
+		return
+	}
+	m.NextAttempt = time.Now().Add(d.retryDelay(m.Attempts))
+	d.logger.Info().Err(err).Int64("id", m.ID).Int64("user_id", m.UserID).Int("attempts", m.Attempts).Msg("notification failed")
+}
+
+func (d *Dispatcher) send(ctx context.Context, m *model.OutboxMessage) error {
+	user, err := d.repo.GetUser(ctx, model.UserFilter{ID: m.UserID})
+	if err != nil {
+		return err
+	} else if user == nil {
+		return errUserNotFound
+	}
+
+	msg := notifier.Message{Kind: m.Kind, UserID: user.ID, Login: user.Login, Data: m.Data}
+	if sealed, ok := m.Data[notifier.DataToken]; ok {
+		if d.box == nil {
+			return errTokenInvalid
+		}
+		token, err := d.box.Open(sealed, notifier.TokenAdditionalData(m.UserID, m.Kind))
+		if err != nil {
+			return errTokenInvalid
+		}
+		msg.Token = string(token)
+		msg.ExpiresAt, _ = time.Parse(time.RFC3339, m.Data[notifier.DataExpiresAt])
+		msg.Data = withoutToken(m.Data)
+	}
+	if email, ok := m.Data[notifier.DataEmail]; ok {
+		msg.Email = email
+		msg.Data = withoutToken(msg.Data)
+	} else if user.EmailVerified {
+		msg.Email = user.Email
+	}
+	return d.notifier.Send(msg)
+}
+
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.config.RetryDelay
+	for i := 1; i < attempts && delay < d.config.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if d.config.MaxRetryDelay > 0 && delay > d.config.MaxRetryDelay {
+		delay = d.config.MaxRetryDelay
+	}
+	return delay
+}
+
+// withoutToken returns copy of data without token, its expiration and address, nil if nothing is left.
+func withoutToken(data map[string]string) map[string]string {
+	var rest map[string]string
+	for k, v := range data {
+		if k == notifier.DataToken || k == notifier.DataExpiresAt || k == notifier.DataEmail {
+			continue
+		} else if rest == nil {
+			rest = make(map[string]string, len(data))
+		}
+		rest[k] = v
+	}
+	return rest
+}
@@ -0,0 +1,241 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+	"github.com/sanches1984/msa-auth/internal/pkg/outbox/mocks"
+	"github.com/sanches1984/msa-auth/pkg/secretbox"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type DispatcherSuite struct {
+	suite.Suite
+
+	ctrl     *gomock.Controller
+	repo     *mocks.MockRepository
+	notifier *mocks.MockNotifier
+	box      *secretbox.Box
+	// inTX tells the batch is being claimed, nothing is sent meanwhile
+	inTX bool
+}
+
+func (s *DispatcherSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.repo = mocks.NewMockRepository(s.ctrl)
+	s.notifier = mocks.NewMockNotifier(s.ctrl)
+	box, err := secretbox.New([]byte("key"))
+	s.Require().NoError(err)
+	s.box = box
+}
+
+func (s *DispatcherSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func TestDispatcher(t *testing.T) {
+	suite.Run(t, new(DispatcherSuite))
+}
+
+func (s *DispatcherSuite) newDispatcher() *Dispatcher {
+	return New(s.repo, s.notifier, s.box, zerolog.Nop(), Config{
+		Interval:      time.Millisecond,
+		BatchSize:     10,
+		MaxAttempts:   3,
+		RetryDelay:    time.Minute,
+		MaxRetryDelay: 3 * time.Minute,
+		Lease:         5 * time.Minute,
+	})
+}
+
+// expectBatch expects messages to be claimed for the lease in transaction.
+func (s *DispatcherSuite) expectBatch(ctx context.Context, messages model.OutboxMessageList) {
+	s.repo.EXPECT().WithTransaction(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+		s.inTX = true
+		defer func() { s.inTX = false }()
+		return fn(ctx)
+	}).Times(1)
+	s.repo.EXPECT().GetPendingOutboxMessages(ctx, int32(10), 3).Return(messages, nil).Times(1)
+	for _, m := range messages {
+		s.repo.EXPECT().UpdateOutboxMessage(ctx, m).DoAndReturn(func(ctx context.Context, m *model.OutboxMessage) error {
+			s.True(s.inTX)
+			s.WithinDuration(time.Now().Add(5*time.Minute), m.NextAttempt, time.Second)
+			return nil
+		}).Times(1)
+	}
+}
+
+// expectSaved expects result of the message to be saved after delivery.
+func (s *DispatcherSuite) expectSaved(ctx context.Context, m *model.OutboxMessage) {
+	s.repo.EXPECT().UpdateOutboxMessage(ctx, m).DoAndReturn(func(ctx context.Context, m *model.OutboxMessage) error {
+		s.False(s.inTX)
+		return nil
+	}).Times(1)
+}
+
+func (s *DispatcherSuite) TestDispatch_Success() {
+	ctx := context.Background()
+	message := &model.OutboxMessage{ID: 1, UserID: 123, Kind: notifier.KindNewLogin, Data: map[string]string{"ip": "127.0.0.1"}}
+
+	s.expectBatch(ctx, model.OutboxMessageList{message})
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{
+		ID:            123,
+		Login:         "login",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	}, nil).Times(1)
+	s.notifier.EXPECT().Send(notifier.Message{
+		Kind:   notifier.KindNewLogin,
+		UserID: 123,
+		Login:  "login",
+		Email:  "user@example.com",
+		Data:   map[string]string{"ip": "127.0.0.1"},
+	}).Return(nil).Times(1)
+	s.expectSaved(ctx, message)
+
+	s.Equal(1, s.newDispatcher().Dispatch(ctx))
+	s.NotNil(message.Sent)
+	s.Equal(0, message.Attempts)
+}
+
+func (s *DispatcherSuite) TestDispatch_Token() {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	sealed, err := s.box.Seal([]byte("token"), notifier.TokenAdditionalData(123, notifier.KindPasswordReset))
+	s.Require().NoError(err)
+	message := &model.OutboxMessage{ID: 1, UserID: 123, Kind: notifier.KindPasswordReset, Data: map[string]string{
+		notifier.DataToken:     sealed,
+		notifier.DataExpiresAt: expiresAt.Format(time.RFC3339),
+	}}
+
+	s.expectBatch(ctx, model.OutboxMessageList{message})
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.notifier.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg notifier.Message) error {
+		s.Equal("token", msg.Token)
+		s.True(expiresAt.Equal(msg.ExpiresAt))
+		s.Nil(msg.Data)
+		return nil
+	}).Times(1)
+	s.expectSaved(ctx, message)
+
+	s.Equal(1, s.newDispatcher().Dispatch(ctx))
+	s.NotNil(message.Sent)
+	// sent token isn't kept in the outbox
+	s.Nil(message.Data)
+}
+
+func (s *DispatcherSuite) TestDispatch_EmailVerification() {
+	ctx := context.Background()
+	sealed, err := s.box.Seal([]byte("token"), notifier.TokenAdditionalData(123, notifier.KindEmailVerification))
+	s.Require().NoError(err)
+	message := &model.OutboxMessage{ID: 1, UserID: 123, Kind: notifier.KindEmailVerification, Data: map[string]string{
+		notifier.DataToken:     sealed,
+		notifier.DataExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+		notifier.DataEmail:     "new@example.com",
+	}}
+
+	s.expectBatch(ctx, model.OutboxMessageList{message})
+	user := &model.User{ID: 123, Login: "login", Email: "old@example.com", EmailVerified: true}
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(user, nil).Times(1)
+	s.notifier.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg notifier.Message) error {
+		// verification is sent to the address being verified
+		s.Equal("new@example.com", msg.Email)
+		s.Equal("token", msg.Token)
+		s.Nil(msg.Data)
+		return nil
+	}).Times(1)
+	s.expectSaved(ctx, message)
+
+	s.Equal(1, s.newDispatcher().Dispatch(ctx))
+	s.NotNil(message.Sent)
+	s.Nil(message.Data)
+}
+
+func (s *DispatcherSuite) TestDispatch_TokenOfOtherUser() {
+	ctx := context.Background()
+	sealed, err := s.box.Seal([]byte("token"), notifier.TokenAdditionalData(456, notifier.KindPasswordReset))
+	s.Require().NoError(err)
+	message := &model.OutboxMessage{ID: 1, UserID: 123, Kind: notifier.KindPasswordReset, Data: map[string]string{
+		notifier.DataToken:     sealed,
+		notifier.DataExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+	}}
+
+	s.expectBatch(ctx, model.OutboxMessageList{message})
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(1)
+	s.expectSaved(ctx, message)
+
+	s.Equal(1, s.newDispatcher().Dispatch(ctx))
+	// token moved from other message is never sent
+	s.Nil(message.Sent)
+	s.Equal(3, message.Attempts)
+	s.Nil(message.Data)
+}
+
+func (s *DispatcherSuite) TestDispatch_Retry() {
+	ctx := context.Background()
+	failed := &model.OutboxMessage{ID: 1, UserID: 123, Kind: notifier.KindPasswordChanged, Attempts: 1}
+	dropped := &model.OutboxMessage{ID: 2, UserID: 123, Kind: notifier.KindPasswordChanged, Attempts: 2}
+	unverified := &model.OutboxMessage{ID: 3, UserID: 456, Kind: notifier.KindPasswordChanged}
+	sendErr := errors.New("connection refused")
+
+	s.expectBatch(ctx, model.OutboxMessageList{failed, dropped, unverified})
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(2)
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 456}).Return(&model.User{ID: 456, Login: "other"}, nil).Times(1)
+	s.notifier.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg notifier.Message) error {
+		if msg.UserID == 456 {
+			return notifier.ErrNoRecipient
+		}
+		return sendErr
+	}).Times(3)
+	s.expectSaved(ctx, failed)
+	s.expectSaved(ctx, dropped)
+	s.expectSaved(ctx, unverified)
+
+	s.Equal(3, s.newDispatcher().Dispatch(ctx))
+
+	s.Nil(failed.Sent)
+	s.Equal(2, failed.Attempts)
+	s.Equal("connection refused", failed.Error)
+	s.WithinDuration(time.Now().Add(2*time.Minute), failed.NextAttempt, time.Second)
+
+	// attempts are over
+	s.Nil(dropped.Sent)
+	s.Equal(3, dropped.Attempts)
+
+	// message can't be delivered at all
+	s.Nil(unverified.Sent)
+	s.Equal(3, unverified.Attempts)
+}
+
+func (s *DispatcherSuite) TestDispatch_SaveError() {
+	ctx := context.Background()
+	first := &model.OutboxMessage{ID: 1, UserID: 123, Kind: notifier.KindPasswordChanged}
+	second := &model.OutboxMessage{ID: 2, UserID: 123, Kind: notifier.KindPasswordChanged}
+
+	// unsaved result of one message doesn't stop the batch
+	s.expectBatch(ctx, model.OutboxMessageList{first, second})
+	s.repo.EXPECT().GetUser(ctx, model.UserFilter{ID: 123}).Return(&model.User{ID: 123, Login: "login"}, nil).Times(2)
+	s.notifier.EXPECT().Send(gomock.Any()).Return(nil).Times(2)
+	s.repo.EXPECT().UpdateOutboxMessage(ctx, first).Return(errors.New("connection reset")).Times(1)
+	s.expectSaved(ctx, second)
+
+	s.Equal(2, s.newDispatcher().Dispatch(ctx))
+	s.NotNil(second.Sent)
+}
+
+func (s *DispatcherSuite) TestRunClose() {
+	s.repo.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).Return(nil).MinTimes(1)
+
+	d := s.newDispatcher()
+	go d.Run()
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+
+	// never started dispatcher is closed at once
+	s.newDispatcher().Close()
+}
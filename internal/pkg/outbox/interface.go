@@ -0,0 +1,18 @@
+package outbox
+
+import (
+	"context"
+	"github.com/sanches1984/msa-auth/internal/app/model"
+	"github.com/sanches1984/msa-auth/internal/pkg/notifier"
+)
+
+type Repository interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetUser(ctx context.Context, filter model.UserFilter) (*model.User, error)
+	GetPendingOutboxMessages(ctx context.Context, limit int32, maxAttempts int) (model.OutboxMessageList, error)
+	UpdateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error
+}
+
+type Notifier interface {
+	Send(msg notifier.Message) error
+}
@@ -13,4 +13,5 @@ type ORM interface {
 	Update(ctx context.Context, rec interface{}, columns ...string) error
 	SoftDelete(ctx context.Context, rec dao.DeletedSetter) error
 	HardDeleteWhere(ctx context.Context, rec interface{}, opts []opt.FnOpt) error
+	WithTX(ctx context.Context, fn func(context.Context) error) error
 }
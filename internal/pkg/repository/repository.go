@@ -2,11 +2,13 @@ package repository
 
 import (
 	"context"
+	"github.com/go-pg/pg/v9/orm"
 	"github.com/sanches1984/gopkg-pg-orm/pager"
 	"github.com/sanches1984/gopkg-pg-orm/repository/dao"
 	"github.com/sanches1984/gopkg-pg-orm/repository/opt"
 	"github.com/sanches1984/msa-auth/internal/app/model"
 	uuid "github.com/satori/go.uuid"
+	"time"
 )
 
 type Repository struct {
@@ -17,6 +19,10 @@ func New() *Repository {
 	return &Repository{db: dao.New()}
 }
 
+func (r *Repository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.db.WithTX(ctx, fn)
+}
+
 func (r *Repository) GetUsers(ctx context.Context, filter model.UserFilter, pgr pager.Pager) (model.UserList, error) {
 	var users []*model.User
 	opts := opt.List()
@@ -26,6 +32,15 @@ func (r *Repository) GetUsers(ctx context.Context, filter model.UserFilter, pgr
 	if filter.Login != "" {
 		opts = append(opts, opt.Eq("login", filter.Login))
 	}
+	if filter.Email != "" {
+		opts = append(opts, opt.Eq("email", filter.Email))
+	}
+	switch filter.EmailStatus {
+	case model.EmailStatusVerified:
+		opts = append(opts, opt.Eq("email_verified", true))
+	case model.EmailStatusUnverified:
+		opts = append(opts, opt.NotNull("email"), opt.Eq("email_verified", false))
+	}
 	if !filter.ShowDeleted {
 		opts = append(opts, opt.IsNull("deleted"))
 	}
@@ -58,11 +73,42 @@ func (r *Repository) UpdateUserPassword(ctx context.Context, user *model.User) e
 	return r.db.Update(ctx, user, "password_hash")
 }
 
+func (r *Repository) UpdateUserEmail(ctx context.Context, user *model.User) error {
+	return r.db.Update(ctx, user, "email", "email_verified", "email_verified_at")
+}
+
 func (r *Repository) DeleteUser(ctx context.Context, user *model.User) error {
 	opts := opt.List(opt.Eq("user_id", user.ID))
 	if err := r.db.HardDeleteWhere(ctx, &model.RefreshToken{}, opts); err != nil {
 		return err
 	}
+	if err := r.db.HardDeleteWhere(ctx, &model.RefreshTokenHistory{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.PasswordHistory{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.UserTOTP{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.RecoveryCode{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.WebAuthnCredential{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.PasswordResetToken{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.EmailVerificationToken{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.OutboxMessage{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.UserRole{}, opts); err != nil {
+		return err
+	}
 
 	return r.db.SoftDelete(ctx, user)
 }
@@ -92,12 +138,26 @@ func (r *Repository) GetRefreshToken(ctx context.Context, filter model.RefreshTo
 	return tokens[0], nil
 }
 
+// LockRefreshToken gets refresh token of the session locked till the end of transaction,
+// so concurrent refreshes of the session are serialized.
+func (r *Repository) LockRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) (*model.RefreshToken, error) {
+	var tokens []*model.RefreshToken
+	opts := opt.List(opt.Eq("user_id", filter.UserID), opt.Eq("session_id", filter.SessionID), forUpdate())
+	if err := r.db.FindList(ctx, &tokens, opts); err != nil {
+		return nil, err
+	} else if len(tokens) != 1 {
+		return nil, nil
+	}
+
+	return tokens[0], nil
+}
+
 func (r *Repository) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
 	return r.db.Insert(ctx, token)
 }
 
 func (r *Repository) UpdateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
-	return r.db.Update(ctx, token, "token", "expires_in")
+	return r.db.Update(ctx, token, "token_hash", "expires_in")
 }
 
 func (r *Repository) DeleteRefreshToken(ctx context.Context, filter model.RefreshTokenFilter) error {
@@ -107,5 +167,504 @@ func (r *Repository) DeleteRefreshToken(ctx context.Context, filter model.Refres
 		opts = append(opts, opt.Eq("session_id", filter.SessionID))
 	}
 
+	if err := r.db.HardDeleteWhere(ctx, &model.RefreshTokenHistory{}, opts); err != nil {
+		return err
+	}
 	return r.db.HardDeleteWhere(ctx, &model.RefreshToken{}, opts)
 }
+
+func (r *Repository) GetRefreshTokenHistory(ctx context.Context, filter model.RefreshTokenHistoryFilter) (model.RefreshTokenHistoryList, error) {
+	var history []*model.RefreshTokenHistory
+	opts := opt.List(opt.Eq("user_id", filter.UserID), opt.Eq("session_id", filter.SessionID))
+
+	err := r.db.FindList(ctx, &history, opts)
+	return history, err
+}
+
+func (r *Repository) CreateRefreshTokenHistory(ctx context.Context, history *model.RefreshTokenHistory) error {
+	return r.db.Insert(ctx, history)
+}
+
+func (r *Repository) GetPasswordHistory(ctx context.Context, filter model.PasswordHistoryFilter) (model.PasswordHistoryList, error) {
+	var history []*model.PasswordHistory
+	opts := opt.List(opt.Eq("user_id", filter.UserID), opt.Desc("id"))
+	if filter.Limit > 0 {
+		opts = append(opts, opt.Limit(filter.Limit))
+	}
+
+	err := r.db.FindList(ctx, &history, opts)
+	return history, err
+}
+
+func (r *Repository) CreatePasswordHistory(ctx context.Context, history *model.PasswordHistory) error {
+	return r.db.Insert(ctx, history)
+}
+
+func (r *Repository) GetUserTOTP(ctx context.Context, userID int64) (*model.UserTOTP, error) {
+	var list []*model.UserTOTP
+	if err := r.db.FindList(ctx, &list, opt.List(opt.Eq("user_id", userID))); err != nil {
+		return nil, err
+	} else if len(list) != 1 {
+		return nil, nil
+	}
+
+	return list[0], nil
+}
+
+// CreateUserTOTP replaces totp of the user.
+func (r *Repository) CreateUserTOTP(ctx context.Context, totp *model.UserTOTP) error {
+	if err := r.DeleteUserTOTP(ctx, totp.UserID); err != nil {
+		return err
+	}
+	return r.db.Insert(ctx, totp)
+}
+
+func (r *Repository) UpdateUserTOTP(ctx context.Context, totp *model.UserTOTP) error {
+	return r.db.Update(ctx, totp, "enabled", "last_used_step")
+}
+
+// UseTOTPStep advances last used step of the totp, false is returned when the step or later one is already used,
+// e.g. by concurrent request with the same code. The row is locked only if its step is before the given one.
+func (r *Repository) UseTOTPStep(ctx context.Context, totp *model.UserTOTP, step int64) (bool, error) {
+	var used bool
+	err := r.WithTransaction(ctx, func(ctx context.Context) error {
+		var list []*model.UserTOTP
+		opts := opt.List(opt.Eq("id", totp.ID), opt.Lt("last_used_step", step), forUpdate())
+		if err := r.db.FindList(ctx, &list, opts); err != nil {
+			return err
+		} else if len(list) == 0 {
+			return nil
+		}
+
+		used = true
+		totp.LastUsedStep = step
+		return r.db.Update(ctx, totp, "last_used_step")
+	})
+	return used, err
+}
+
+func (r *Repository) DeleteUserTOTP(ctx context.Context, userID int64) error {
+	return r.db.HardDeleteWhere(ctx, &model.UserTOTP{}, opt.List(opt.Eq("user_id", userID)))
+}
+
+func (r *Repository) GetRecoveryCodes(ctx context.Context, userID int64) (model.RecoveryCodeList, error) {
+	var codes []*model.RecoveryCode
+	err := r.db.FindList(ctx, &codes, opt.List(opt.Eq("user_id", userID)))
+	return codes, err
+}
+
+// CreateRecoveryCodes replaces recovery codes of the user.
+func (r *Repository) CreateRecoveryCodes(ctx context.Context, userID int64, codes model.RecoveryCodeList) error {
+	if err := r.db.HardDeleteWhere(ctx, &model.RecoveryCode{}, opt.List(opt.Eq("user_id", userID))); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if err := r.db.Insert(ctx, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRecoveryCode burns the code, false is returned when it's already burnt by concurrent request.
+// The code is locked before delete, so only one of concurrent requests gets true.
+func (r *Repository) DeleteRecoveryCode(ctx context.Context, code *model.RecoveryCode) (bool, error) {
+	var deleted bool
+	err := r.WithTransaction(ctx, func(ctx context.Context) error {
+		var codes []*model.RecoveryCode
+		if err := r.db.FindList(ctx, &codes, opt.List(opt.Eq("id", code.ID), forUpdate())); err != nil {
+			return err
+		} else if len(codes) == 0 {
+			return nil
+		}
+
+		deleted = true
+		return r.db.HardDeleteWhere(ctx, &model.RecoveryCode{}, opt.List(opt.Eq("id", code.ID)))
+	})
+	return deleted, err
+}
+
+func (r *Repository) GetWebAuthnCredentials(ctx context.Context, filter model.WebAuthnCredentialFilter) (model.WebAuthnCredentialList, error) {
+	var credentials []*model.WebAuthnCredential
+	opts := opt.List()
+	if filter.UserID != 0 {
+		opts = append(opts, opt.Eq("user_id", filter.UserID))
+	}
+	if filter.CredentialID != "" {
+		opts = append(opts, opt.Eq("credential_id", filter.CredentialID))
+	}
+
+	err := r.db.FindList(ctx, &credentials, opts)
+	return credentials, err
+}
+
+func (r *Repository) GetWebAuthnCredential(ctx context.Context, filter model.WebAuthnCredentialFilter) (*model.WebAuthnCredential, error) {
+	credentials, err := r.GetWebAuthnCredentials(ctx, filter)
+	if err != nil {
+		return nil, err
+	} else if len(credentials) != 1 {
+		return nil, nil
+	}
+
+	return credentials[0], nil
+}
+
+func (r *Repository) CreateWebAuthnCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	return r.db.Insert(ctx, credential)
+}
+
+func (r *Repository) UpdateWebAuthnCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	return r.db.Update(ctx, credential, "sign_count", "last_used")
+}
+
+func (r *Repository) GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	var list []*model.PasswordResetToken
+	if err := r.db.FindList(ctx, &list, opt.List(opt.Eq("token_hash", tokenHash))); err != nil {
+		return nil, err
+	} else if len(list) != 1 {
+		return nil, nil
+	}
+
+	return list[0], nil
+}
+
+// CreatePasswordResetToken replaces reset tokens of the user, so only the last requested one works.
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) error {
+	if err := r.DeletePasswordResetTokens(ctx, token.UserID); err != nil {
+		return err
+	}
+	return r.db.Insert(ctx, token)
+}
+
+// DeletePasswordResetToken burns the token, false is returned when it's already burnt by concurrent request.
+// The token is locked before delete, so only one of concurrent requests gets true.
+func (r *Repository) DeletePasswordResetToken(ctx context.Context, token *model.PasswordResetToken) (bool, error) {
+	var deleted bool
+	err := r.WithTransaction(ctx, func(ctx context.Context) error {
+		var tokens []*model.PasswordResetToken
+		if err := r.db.FindList(ctx, &tokens, opt.List(opt.Eq("id", token.ID), forUpdate())); err != nil {
+			return err
+		} else if len(tokens) == 0 {
+			return nil
+		}
+
+		deleted = true
+		return r.db.HardDeleteWhere(ctx, &model.PasswordResetToken{}, opt.List(opt.Eq("id", token.ID)))
+	})
+	return deleted, err
+}
+
+func (r *Repository) DeletePasswordResetTokens(ctx context.Context, userID int64) error {
+	return r.db.HardDeleteWhere(ctx, &model.PasswordResetToken{}, opt.List(opt.Eq("user_id", userID)))
+}
+
+func (r *Repository) GetEmailVerificationToken(ctx context.Context, tokenHash string) (*model.EmailVerificationToken, error) {
+	var list []*model.EmailVerificationToken
+	if err := r.db.FindList(ctx, &list, opt.List(opt.Eq("token_hash", tokenHash))); err != nil {
+		return nil, err
+	} else if len(list) != 1 {
+		return nil, nil
+	}
+
+	return list[0], nil
+}
+
+// CreateEmailVerificationToken replaces verification tokens of the user, so only the last sent one works.
+func (r *Repository) CreateEmailVerificationToken(ctx context.Context, token *model.EmailVerificationToken) error {
+	if err := r.DeleteEmailVerificationTokens(ctx, token.UserID); err != nil {
+		return err
+	}
+	return r.db.Insert(ctx, token)
+}
+
+func (r *Repository) DeleteEmailVerificationTokens(ctx context.Context, userID int64) error {
+	return r.db.HardDeleteWhere(ctx, &model.EmailVerificationToken{}, opt.List(opt.Eq("user_id", userID)))
+}
+
+func (r *Repository) CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error {
+	return r.db.Insert(ctx, message)
+}
+
+// GetPendingOutboxMessages locks due messages with less than maxAttempts failures till the end of transaction,
+// messages locked by another dispatcher are skipped.
+func (r *Repository) GetPendingOutboxMessages(ctx context.Context, limit int32, maxAttempts int) (model.OutboxMessageList, error) {
+	var messages []*model.OutboxMessage
+	opts := opt.List(
+		opt.IsNull("sent"),
+		opt.Le("next_attempt", time.Now()),
+		opt.Lt("attempts", maxAttempts),
+		opt.Asc("id"),
+		opt.Limit(limit),
+		opt.Fn(func(q *orm.Query) (*orm.Query, error) {
+			return q.For("UPDATE SKIP LOCKED"), nil
+		}),
+	)
+
+	err := r.db.FindList(ctx, &messages, opts)
+	return messages, err
+}
+
+func (r *Repository) UpdateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error {
+	return r.db.Update(ctx, message, "attempts", "next_attempt", "sent", "error", "data")
+}
+
+func (r *Repository) GetRoles(ctx context.Context, filter model.RoleFilter) (model.RoleList, error) {
+	var roles []*model.Role
+	opts := opt.List(opt.Asc("name"))
+	if len(filter.Names) != 0 {
+		opts = append(opts, opt.In("name", filter.Names))
+	}
+	if filter.UserID != 0 {
+		opts = append(opts, opt.Fn(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("id IN (SELECT role_id FROM user_roles WHERE user_id = ?)", filter.UserID), nil
+		}))
+	}
+
+	if err := r.db.FindList(ctx, &roles, opts); err != nil {
+		return nil, err
+	}
+	return roles, r.loadRolePermissions(ctx, roles)
+}
+
+func (r *Repository) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	roles, err := r.GetRoles(ctx, model.RoleFilter{Names: []string{name}})
+	if err != nil {
+		return nil, err
+	} else if len(roles) != 1 {
+		return nil, nil
+	}
+
+	return roles[0], nil
+}
+
+func (r *Repository) CreateRole(ctx context.Context, role *model.Role) error {
+	if err := r.db.Insert(ctx, role); err != nil {
+		return err
+	}
+	return r.setRolePermissions(ctx, role)
+}
+
+// UpdateRole updates description and replaces permissions of the role.
+func (r *Repository) UpdateRole(ctx context.Context, role *model.Role) error {
+	if err := r.db.Update(ctx, role, "description"); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.RolePermission{}, opt.List(opt.Eq("role_id", role.ID))); err != nil {
+		return err
+	}
+	return r.setRolePermissions(ctx, role)
+}
+
+func (r *Repository) DeleteRole(ctx context.Context, role *model.Role) error {
+	opts := opt.List(opt.Eq("role_id", role.ID))
+	if err := r.db.HardDeleteWhere(ctx, &model.RolePermission{}, opts); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.UserRole{}, opts); err != nil {
+		return err
+	}
+	return r.db.HardDeleteWhere(ctx, &model.Role{}, opt.List(opt.Eq("id", role.ID)))
+}
+
+func (r *Repository) GetPermissions(ctx context.Context, filter model.PermissionFilter) (model.PermissionList, error) {
+	var permissions []*model.Permission
+	opts := opt.List(opt.Asc("name"))
+	if len(filter.IDs) != 0 {
+		opts = append(opts, opt.In("id", filter.IDs))
+	}
+	if len(filter.Names) != 0 {
+		opts = append(opts, opt.In("name", filter.Names))
+	}
+
+	err := r.db.FindList(ctx, &permissions, opts)
+	return permissions, err
+}
+
+func (r *Repository) CreatePermission(ctx context.Context, permission *model.Permission) error {
+	return r.db.Insert(ctx, permission)
+}
+
+func (r *Repository) DeletePermission(ctx context.Context, permission *model.Permission) error {
+	if err := r.db.HardDeleteWhere(ctx, &model.RolePermission{}, opt.List(opt.Eq("permission_id", permission.ID))); err != nil {
+		return err
+	}
+	return r.db.HardDeleteWhere(ctx, &model.Permission{}, opt.List(opt.Eq("id", permission.ID)))
+}
+
+func (r *Repository) CreateUserRole(ctx context.Context, userRole *model.UserRole) error {
+	return r.db.Insert(ctx, userRole)
+}
+
+func (r *Repository) DeleteUserRole(ctx context.Context, userRole *model.UserRole) error {
+	return r.db.HardDeleteWhere(ctx, &model.UserRole{}, opt.List(opt.Eq("user_id", userRole.UserID), opt.Eq("role_id", userRole.RoleID)))
+}
+
+func (r *Repository) GetServiceAccounts(ctx context.Context, filter model.ServiceAccountFilter) (model.ServiceAccountList, error) {
+	var accounts []*model.ServiceAccount
+	opts := opt.List(opt.Asc("name"))
+	if filter.ID != 0 {
+		opts = append(opts, opt.Eq("id", filter.ID))
+	}
+	if filter.Name != "" {
+		opts = append(opts, opt.Eq("name", filter.Name))
+	}
+
+	err := r.db.FindList(ctx, &accounts, opts)
+	return accounts, err
+}
+
+func (r *Repository) GetServiceAccount(ctx context.Context, filter model.ServiceAccountFilter) (*model.ServiceAccount, error) {
+	accounts, err := r.GetServiceAccounts(ctx, filter)
+	if err != nil {
+		return nil, err
+	} else if len(accounts) != 1 {
+		return nil, nil
+	}
+
+	return accounts[0], nil
+}
+
+func (r *Repository) CreateServiceAccount(ctx context.Context, account *model.ServiceAccount) error {
+	return r.db.Insert(ctx, account)
+}
+
+func (r *Repository) DeleteServiceAccount(ctx context.Context, account *model.ServiceAccount) error {
+	if err := r.db.HardDeleteWhere(ctx, &model.APIKey{}, opt.List(opt.Eq("service_account_id", account.ID))); err != nil {
+		return err
+	}
+	if err := r.db.HardDeleteWhere(ctx, &model.OAuthClient{}, opt.List(opt.Eq("service_account_id", account.ID))); err != nil {
+		return err
+	}
+	return r.db.HardDeleteWhere(ctx, &model.ServiceAccount{}, opt.List(opt.Eq("id", account.ID)))
+}
+
+func (r *Repository) GetAPIKeys(ctx context.Context, filter model.APIKeyFilter) (model.APIKeyList, error) {
+	var keys []*model.APIKey
+	opts := opt.List(opt.Asc("id"))
+	if filter.ID != 0 {
+		opts = append(opts, opt.Eq("id", filter.ID))
+	}
+	if filter.ServiceAccountID != 0 {
+		opts = append(opts, opt.Eq("service_account_id", filter.ServiceAccountID))
+	}
+	if filter.Prefix != "" {
+		opts = append(opts, opt.Eq("prefix", filter.Prefix))
+	}
+
+	err := r.db.FindList(ctx, &keys, opts)
+	return keys, err
+}
+
+func (r *Repository) GetAPIKey(ctx context.Context, filter model.APIKeyFilter) (*model.APIKey, error) {
+	keys, err := r.GetAPIKeys(ctx, filter)
+	if err != nil {
+		return nil, err
+	} else if len(keys) != 1 {
+		return nil, nil
+	}
+
+	return keys[0], nil
+}
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	return r.db.Insert(ctx, key)
+}
+
+func (r *Repository) UpdateAPIKey(ctx context.Context, key *model.APIKey) error {
+	return r.db.Update(ctx, key, "last_used", "revoked")
+}
+
+func (r *Repository) GetOAuthClients(ctx context.Context, filter model.OAuthClientFilter) (model.OAuthClientList, error) {
+	var clients []*model.OAuthClient
+	opts := opt.List(opt.Asc("id"))
+	if filter.ClientID != "" {
+		opts = append(opts, opt.Eq("client_id", filter.ClientID))
+	}
+	if filter.ServiceAccountID != 0 {
+		opts = append(opts, opt.Eq("service_account_id", filter.ServiceAccountID))
+	}
+
+	err := r.db.FindList(ctx, &clients, opts)
+	return clients, err
+}
+
+func (r *Repository) GetOAuthClient(ctx context.Context, filter model.OAuthClientFilter) (*model.OAuthClient, error) {
+	clients, err := r.GetOAuthClients(ctx, filter)
+	if err != nil {
+		return nil, err
+	} else if len(clients) != 1 {
+		return nil, nil
+	}
+
+	return clients[0], nil
+}
+
+func (r *Repository) CreateOAuthClient(ctx context.Context, client *model.OAuthClient) error {
+	return r.db.Insert(ctx, client)
+}
+
+func (r *Repository) UpdateOAuthClient(ctx context.Context, client *model.OAuthClient) error {
+	return r.db.Update(ctx, client, "secret_hash")
+}
+
+func (r *Repository) DeleteOAuthClient(ctx context.Context, client *model.OAuthClient) error {
+	return r.db.HardDeleteWhere(ctx, &model.OAuthClient{}, opt.List(opt.Eq("id", client.ID)))
+}
+
+func (r *Repository) setRolePermissions(ctx context.Context, role *model.Role) error {
+	for _, p := range role.Permissions {
+		if err := r.db.Insert(ctx, &model.RolePermission{RoleID: role.ID, PermissionID: p.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRolePermissions fills permissions of the roles by two queries.
+func (r *Repository) loadRolePermissions(ctx context.Context, roles model.RoleList) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	roleIDs := make([]int64, 0, len(roles))
+	for _, role := range roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	var links []*model.RolePermission
+	if err := r.db.FindList(ctx, &links, opt.List(opt.In("role_id", roleIDs))); err != nil {
+		return err
+	} else if len(links) == 0 {
+		return nil
+	}
+	permissionIDs := make([]int64, 0, len(links))
+	for _, link := range links {
+		permissionIDs = append(permissionIDs, link.PermissionID)
+	}
+
+	permissions, err := r.GetPermissions(ctx, model.PermissionFilter{IDs: permissionIDs})
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*model.Permission, len(permissions))
+	for _, p := range permissions {
+		byID[p.ID] = p
+	}
+	byRole := make(map[int64]*model.Role, len(roles))
+	for _, role := range roles {
+		byRole[role.ID] = role
+	}
+	for _, link := range links {
+		if p, ok := byID[link.PermissionID]; ok {
+			byRole[link.RoleID].Permissions = append(byRole[link.RoleID].Permissions, p)
+		}
+	}
+	return nil
+}
+
+// forUpdate locks selected rows till the end of transaction, rows deleted by concurrent transaction
+// aren't selected after it commits.
+func forUpdate() opt.FnOpt {
+	return opt.Fn(func(q *orm.Query) (*orm.Query, error) {
+		return q.For("UPDATE"), nil
+	})
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"encoding/json"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	"time"
+)
+
+const mfaChallengePrefix = "mfa:"
+const webAuthnChallengePrefix = "webauthn:"
+const challengeTokenSize = 32
+
+// CreateMFAChallenge keeps login waiting for the second factor, token expires in challenge ttl.
+func (s *Storage) CreateMFAChallenge(challenge MFAChallenge) (Token, error) {
+	return s.createChallenge(mfaChallengePrefix, challenge)
+}
+
+func (s *Storage) GetMFAChallenge(token string) (*MFAChallenge, error) {
+	challenge := &MFAChallenge{}
+	if err := s.getChallenge(mfaChallengePrefix, token, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// SpendMFAChallenge deletes the challenge, false is returned when it's already spent by concurrent request.
+func (s *Storage) SpendMFAChallenge(token string) (bool, error) {
+	return s.redis.Remove(mfaChallengePrefix + token)
+}
+
+// CreateWebAuthnChallenge keeps state of webauthn ceremony between its options and result.
+func (s *Storage) CreateWebAuthnChallenge(challenge WebAuthnChallenge) (Token, error) {
+	return s.createChallenge(webAuthnChallengePrefix, challenge)
+}
+
+func (s *Storage) GetWebAuthnChallenge(token string) (*WebAuthnChallenge, error) {
+	challenge := &WebAuthnChallenge{}
+	if err := s.getChallenge(webAuthnChallengePrefix, token, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (s *Storage) DeleteWebAuthnChallenge(token string) error {
+	return s.redis.Delete(webAuthnChallengePrefix + token)
+}
+
+func (s *Storage) createChallenge(prefix string, challenge interface{}) (Token, error) {
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return Token{}, err
+	}
+
+	token, err := random.String(challengeTokenSize)
+	if err != nil {
+		return Token{}, err
+	}
+	if err := s.redis.SetWithTTL(prefix+token, value, s.challengeTTL); err != nil {
+		return Token{}, err
+	}
+
+	return Token{
+		Value:     token,
+		ExpiresIn: int32(time.Now().Add(s.challengeTTL).Unix()),
+	}, nil
+}
+
+func (s *Storage) getChallenge(prefix, token string, challenge interface{}) error {
+	value, err := s.redis.Get(prefix + token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, challenge)
+}
@@ -3,16 +3,23 @@ package storage
 import (
 	"github.com/sanches1984/msa-auth/pkg/jwt"
 	uuid "github.com/satori/go.uuid"
+	"time"
 )
 
 type Redis interface {
 	Get(key string) ([]byte, error)
 	Set(key string, value []byte) error
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+	Replace(key string, value []byte, ttl time.Duration) error
+	TTL(key string) (time.Duration, error)
 	Delete(key string) error
+	Remove(key string) (bool, error)
 }
 
 type JwtService interface {
-	NewAccessToken(userID int64, sessionID uuid.UUID) (jwt.Token, error)
+	NewAccessToken(userID int64, sessionID uuid.UUID, grants jwt.Grants) (jwt.Token, error)
 	NewRefreshToken(userID int64, sessionID uuid.UUID) (jwt.Token, error)
-	ParseToken(token string) (int64, uuid.UUID, error)
+	NewServiceToken(accountID int64, ttl time.Duration) (jwt.Token, error)
+	ParseToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error)
+	ParseClaims(token string) (*jwt.Claims, error)
 }
@@ -6,6 +6,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	jwt "github.com/sanches1984/msa-auth/pkg/jwt"
@@ -64,6 +65,35 @@ func (mr *MockRedisMockRecorder) Get(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRedis)(nil).Get), key)
 }
 
+// Remove mocks base method.
+func (m *MockRedis) Remove(key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockRedisMockRecorder) Remove(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockRedis)(nil).Remove), key)
+}
+
+// Replace mocks base method.
+func (m *MockRedis) Replace(key string, value []byte, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", key, value, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockRedisMockRecorder) Replace(key, value, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockRedis)(nil).Replace), key, value, ttl)
+}
+
 // Set mocks base method.
 func (m *MockRedis) Set(key string, value []byte) error {
 	m.ctrl.T.Helper()
@@ -78,6 +108,35 @@ func (mr *MockRedisMockRecorder) Set(key, value interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockRedis)(nil).Set), key, value)
 }
 
+// SetWithTTL mocks base method.
+func (m *MockRedis) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWithTTL", key, value, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetWithTTL indicates an expected call of SetWithTTL.
+func (mr *MockRedisMockRecorder) SetWithTTL(key, value, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithTTL", reflect.TypeOf((*MockRedis)(nil).SetWithTTL), key, value, ttl)
+}
+
+// TTL mocks base method.
+func (m *MockRedis) TTL(key string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TTL", key)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TTL indicates an expected call of TTL.
+func (mr *MockRedisMockRecorder) TTL(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TTL", reflect.TypeOf((*MockRedis)(nil).TTL), key)
+}
+
 // MockJwtService is a mock of JwtService interface.
 type MockJwtService struct {
 	ctrl     *gomock.Controller
@@ -102,18 +161,18 @@ func (m *MockJwtService) EXPECT() *MockJwtServiceMockRecorder {
 }
 
 // NewAccessToken mocks base method.
-func (m *MockJwtService) NewAccessToken(userID int64, sessionID uuid.UUID) (jwt.Token, error) {
+func (m *MockJwtService) NewAccessToken(userID int64, sessionID uuid.UUID, grants jwt.Grants) (jwt.Token, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "NewAccessToken", userID, sessionID)
+	ret := m.ctrl.Call(m, "NewAccessToken", userID, sessionID, grants)
 	ret0, _ := ret[0].(jwt.Token)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // NewAccessToken indicates an expected call of NewAccessToken.
-func (mr *MockJwtServiceMockRecorder) NewAccessToken(userID, sessionID interface{}) *gomock.Call {
+func (mr *MockJwtServiceMockRecorder) NewAccessToken(userID, sessionID, grants interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewAccessToken", reflect.TypeOf((*MockJwtService)(nil).NewAccessToken), userID, sessionID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewAccessToken", reflect.TypeOf((*MockJwtService)(nil).NewAccessToken), userID, sessionID, grants)
 }
 
 // NewRefreshToken mocks base method.
@@ -131,10 +190,40 @@ func (mr *MockJwtServiceMockRecorder) NewRefreshToken(userID, sessionID interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewRefreshToken", reflect.TypeOf((*MockJwtService)(nil).NewRefreshToken), userID, sessionID)
 }
 
+// NewServiceToken mocks base method.
+func (m *MockJwtService) NewServiceToken(accountID int64, ttl time.Duration) (jwt.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewServiceToken", accountID, ttl)
+	ret0, _ := ret[0].(jwt.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewServiceToken indicates an expected call of NewServiceToken.
+func (mr *MockJwtServiceMockRecorder) NewServiceToken(accountID, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewServiceToken", reflect.TypeOf((*MockJwtService)(nil).NewServiceToken), accountID, ttl)
+}
+
+// ParseClaims mocks base method.
+func (m *MockJwtService) ParseClaims(token string) (*jwt.Claims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseClaims", token)
+	ret0, _ := ret[0].(*jwt.Claims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseClaims indicates an expected call of ParseClaims.
+func (mr *MockJwtServiceMockRecorder) ParseClaims(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseClaims", reflect.TypeOf((*MockJwtService)(nil).ParseClaims), token)
+}
+
 // ParseToken mocks base method.
-func (m *MockJwtService) ParseToken(token string) (int64, uuid.UUID, error) {
+func (m *MockJwtService) ParseToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ParseToken", token)
+	ret := m.ctrl.Call(m, "ParseToken", token, typ)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(uuid.UUID)
 	ret2, _ := ret[2].(error)
@@ -142,7 +231,7 @@ func (m *MockJwtService) ParseToken(token string) (int64, uuid.UUID, error) {
 }
 
 // ParseToken indicates an expected call of ParseToken.
-func (mr *MockJwtServiceMockRecorder) ParseToken(token interface{}) *gomock.Call {
+func (mr *MockJwtServiceMockRecorder) ParseToken(token, typ interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseToken", reflect.TypeOf((*MockJwtService)(nil).ParseToken), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseToken", reflect.TypeOf((*MockJwtService)(nil).ParseToken), token, typ)
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"github.com/sanches1984/msa-auth/pkg/jwt"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -15,3 +16,24 @@ type Token struct {
 	Value     string
 	ExpiresIn int32
 }
+
+// reference is kept in redis by opaque access token instead of JWT claims.
+// UserID is id of service account for tokens of service type.
+type reference struct {
+	UserID    int64         `json:"user_id"`
+	SessionID uuid.UUID     `json:"session_id"`
+	Type      jwt.TokenType `json:"typ"`
+	jwt.Grants
+}
+
+// MFAChallenge is login waiting for the second factor, Data is session data of the login request.
+type MFAChallenge struct {
+	UserID int64  `json:"user_id"`
+	Data   []byte `json:"data"`
+}
+
+// WebAuthnChallenge is state of webauthn ceremony, UserID is 0 for login by discoverable credential.
+type WebAuthnChallenge struct {
+	UserID    int64  `json:"user_id"`
+	Challenge []byte `json:"challenge"`
+}
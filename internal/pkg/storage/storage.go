@@ -1,23 +1,166 @@
 package storage
 
 import (
+	"encoding/json"
+	"errors"
+	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"github.com/sanches1984/msa-auth/pkg/random"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/sanches1984/msa-auth/pkg/tokenhash"
 	uuid "github.com/satori/go.uuid"
+	"strings"
+	"time"
 )
 
+type TokenFormat string
+
+const (
+	TokenFormatJWT    TokenFormat = "jwt"
+	TokenFormatOpaque TokenFormat = "opaque"
+)
+
+const opaqueTokenSize = 32
+const opaqueTokenPrefix = "opaque:"
+const defaultChallengeTTL = 5 * time.Minute
+
+var ErrUnknownTokenFormat = errors.New("unknown token format")
+
 type Storage struct {
-	redis Redis
-	jwt   JwtService
+	redis        Redis
+	jwt          JwtService
+	hasher       *tokenhash.Hasher
+	format       TokenFormat
+	accessTTL    time.Duration
+	challengeTTL time.Duration
+}
+
+type Option func(s *Storage)
+
+// WithTokenHashKey sets key of token hashes kept in database.
+func WithTokenHashKey(key []byte) Option {
+	return func(s *Storage) {
+		s.hasher = tokenhash.New(key)
+	}
+}
+
+// WithOpaqueAccessTokens issues random access tokens instead of JWT, so clients can't read claims.
+// User and session of the token are kept in redis for ttl.
+func WithOpaqueAccessTokens(ttl time.Duration) Option {
+	return func(s *Storage) {
+		s.format = TokenFormatOpaque
+		s.accessTTL = ttl
+	}
+}
+
+// WithChallengeTTL sets lifetime of login challenges, 5 minutes by default.
+func WithChallengeTTL(ttl time.Duration) Option {
+	return func(s *Storage) {
+		s.challengeTTL = ttl
+	}
+}
+
+func New(redis Redis, jwt JwtService, opts ...Option) *Storage {
+	s := &Storage{
+		redis:        redis,
+		jwt:          jwt,
+		hasher:       tokenhash.New(nil),
+		format:       TokenFormatJWT,
+		challengeTTL: defaultChallengeTTL,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// DecodeToken returns user and session of the token. JWT access tokens issued before
+// switching to opaque format are still accepted.
+func (s *Storage) DecodeToken(token string, typ jwt.TokenType) (int64, uuid.UUID, error) {
+	if !s.isOpaque(token) {
+		return s.jwt.ParseToken(token, typ)
+	} else if typ == jwt.TokenTypeRefresh {
+		return 0, uuid.Nil, jwt.ErrInvalidType
+	}
+
+	value, err := s.redis.Get(opaqueTokenPrefix + token)
+	if errors.Is(err, redis.ErrRecordNotFound) {
+		return 0, uuid.Nil, jwt.ErrInvalidToken
+	} else if err != nil {
+		return 0, uuid.Nil, err
+	}
+
+	ref, err := decodeReference(string(value))
+	if err != nil {
+		return 0, uuid.Nil, err
+	} else if ref.Type != typ {
+		return 0, uuid.Nil, jwt.ErrInvalidType
+	}
+	return ref.UserID, ref.SessionID, nil
+}
+
+// DecodeGrants returns grants of the user kept in access token when it was issued.
+func (s *Storage) DecodeGrants(token string) (jwt.Grants, error) {
+	if !s.isOpaque(token) {
+		claims, err := s.jwt.ParseClaims(token)
+		if err != nil {
+			return jwt.Grants{}, err
+		} else if claims.Type != jwt.TokenTypeAccess {
+			return jwt.Grants{}, jwt.ErrInvalidType
+		}
+		return claims.Grants, nil
+	}
+
+	value, err := s.redis.Get(opaqueTokenPrefix + token)
+	if errors.Is(err, redis.ErrRecordNotFound) {
+		return jwt.Grants{}, jwt.ErrInvalidToken
+	} else if err != nil {
+		return jwt.Grants{}, err
+	}
+
+	ref, err := decodeReference(string(value))
+	if err != nil {
+		return jwt.Grants{}, err
+	} else if ref.Type != jwt.TokenTypeAccess {
+		return jwt.Grants{}, jwt.ErrInvalidType
+	}
+	return ref.Grants, nil
 }
 
-func New(redis Redis, jwt JwtService) *Storage {
-	return &Storage{
-		redis: redis,
-		jwt:   jwt,
+// CreateServiceToken issues access token of service account for ttl. It has no session, so it's valid until expired.
+func (s *Storage) CreateServiceToken(accountID int64, ttl time.Duration) (Token, error) {
+	if s.format != TokenFormatOpaque {
+		token, err := s.jwt.NewServiceToken(accountID, ttl)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Value: token.Value, ExpiresIn: token.ExpiresAt}, nil
+	}
+
+	token, err := random.String(opaqueTokenSize)
+	if err != nil {
+		return Token{}, err
+	}
+	ref, err := encodeReference(reference{UserID: accountID, SessionID: uuid.NewV4(), Type: jwt.TokenTypeService})
+	if err != nil {
+		return Token{}, err
 	}
+	if err := s.redis.SetWithTTL(opaqueTokenPrefix+token, ref, ttl); err != nil {
+		return Token{}, err
+	}
+
+	return Token{
+		Value:     token,
+		ExpiresIn: int32(time.Now().Add(ttl).Unix()),
+	}, nil
+}
+
+// HashToken returns keyed hash of token to keep it in database.
+func (s *Storage) HashToken(token string) string {
+	return s.hasher.Hash(token)
 }
 
-func (s *Storage) DecodeToken(token string) (int64, uuid.UUID, error) {
-	return s.jwt.ParseToken(token)
+func (s *Storage) MatchToken(hash, token string) bool {
+	return s.hasher.Match(hash, token)
 }
 
 func (s *Storage) GetSessionData(token string) ([]byte, error) {
@@ -32,9 +175,9 @@ func (s *Storage) GetSessionDataByUUID(sessionID uuid.UUID) ([]byte, error) {
 	return s.GetSessionData(string(token))
 }
 
-func (s *Storage) CreateSession(userID int64, userData []byte) (*Session, error) {
+func (s *Storage) CreateSession(userID int64, grants jwt.Grants, userData []byte) (*Session, error) {
 	sessionID := uuid.NewV4()
-	session, err := s.createNewSession(userID, sessionID)
+	session, err := s.createNewSession(userID, sessionID, grants)
 	if err != nil {
 		return nil, err
 	}
@@ -50,33 +193,37 @@ func (s *Storage) CreateSession(userID int64, userData []byte) (*Session, error)
 	return session, nil
 }
 
-func (s *Storage) RefreshSession(userID int64, sessionID uuid.UUID, userData []byte) (*Session, error) {
-	session, err := s.createNewSession(userID, sessionID)
-	if err != nil {
-		return nil, err
-	}
+// IssueSession issues new tokens of existing session, access token gets current grants of the user.
+// The session keeps its old tokens till RefreshSession.
+func (s *Storage) IssueSession(userID int64, sessionID uuid.UUID, grants jwt.Grants) (*Session, error) {
+	return s.createNewSession(userID, sessionID, grants)
+}
 
-	if err := s.DeleteSessionByUUID(sessionID); err != nil {
-		return nil, err
+// RefreshSession replaces tokens of the session by ones of IssueSession.
+func (s *Storage) RefreshSession(session *Session, userData []byte) error {
+	if err := s.DeleteSessionByUUID(session.ID); err != nil {
+		return err
 	}
 
 	if err := s.redis.Set(session.Access.Value, userData); err != nil {
-		return nil, err
-	}
-
-	if err := s.redis.Set(sessionID.String(), []byte(session.Access.Value)); err != nil {
-		return nil, err
+		return err
 	}
 
-	return session, nil
+	return s.redis.Set(session.ID.String(), []byte(session.Access.Value))
 }
 
+// UpdateSessionData replaces data of existing session keeping its ttl, so deleted session isn't recreated.
+// Missing session is redis.ErrRecordNotFound.
 func (s *Storage) UpdateSessionData(token string, userData []byte) error {
-	return s.redis.Set(token, userData)
+	ttl, err := s.redis.TTL(token)
+	if err != nil {
+		return err
+	}
+	return s.redis.Replace(token, userData, ttl)
 }
 
 func (s *Storage) DeleteSession(token string) error {
-	_, sessionID, err := s.jwt.ParseToken(token)
+	_, sessionID, err := s.DecodeToken(token, jwt.TokenTypeAccess)
 	if err != nil {
 		return err
 	}
@@ -93,8 +240,8 @@ func (s *Storage) DeleteSessionByUUID(sessionID uuid.UUID) error {
 	return s.deleteSessionRecords(sessionID, string(token))
 }
 
-func (s *Storage) createNewSession(userID int64, sessionID uuid.UUID) (*Session, error) {
-	access, err := s.jwt.NewAccessToken(userID, sessionID)
+func (s *Storage) createNewSession(userID int64, sessionID uuid.UUID, grants jwt.Grants) (*Session, error) {
+	access, err := s.newAccessToken(userID, sessionID, grants)
 	if err != nil {
 		return nil, err
 	}
@@ -117,10 +264,55 @@ func (s *Storage) createNewSession(userID int64, sessionID uuid.UUID) (*Session,
 	}, nil
 }
 
+func (s *Storage) newAccessToken(userID int64, sessionID uuid.UUID, grants jwt.Grants) (jwt.Token, error) {
+	if s.format != TokenFormatOpaque {
+		return s.jwt.NewAccessToken(userID, sessionID, grants)
+	}
+
+	token, err := random.String(opaqueTokenSize)
+	if err != nil {
+		return jwt.Token{}, err
+	}
+	ref, err := encodeReference(reference{UserID: userID, SessionID: sessionID, Type: jwt.TokenTypeAccess, Grants: grants})
+	if err != nil {
+		return jwt.Token{}, err
+	}
+	if err := s.redis.SetWithTTL(opaqueTokenPrefix+token, ref, s.accessTTL); err != nil {
+		return jwt.Token{}, err
+	}
+
+	return jwt.Token{
+		Value:     token,
+		ExpiresAt: int32(time.Now().Add(s.accessTTL).Unix()),
+	}, nil
+}
+
 func (s *Storage) deleteSessionRecords(sessionID uuid.UUID, token string) error {
 	// danger!
 	if err := s.redis.Delete(token); err != nil {
 		return err
 	}
+	if s.isOpaque(token) {
+		if err := s.redis.Delete(opaqueTokenPrefix + token); err != nil {
+			return err
+		}
+	}
 	return s.redis.Delete(sessionID.String())
 }
+
+// isOpaque tells opaque tokens from JWT, which always have dot separated parts.
+func (s *Storage) isOpaque(token string) bool {
+	return s.format == TokenFormatOpaque && !strings.Contains(token, ".")
+}
+
+func encodeReference(ref reference) ([]byte, error) {
+	return json.Marshal(ref)
+}
+
+func decodeReference(value string) (reference, error) {
+	var ref reference
+	if err := json.Unmarshal([]byte(value), &ref); err != nil || ref.SessionID == uuid.Nil || ref.Type == "" {
+		return reference{}, jwt.ErrEmptyToken
+	}
+	return ref, nil
+}
@@ -4,9 +4,11 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/sanches1984/msa-auth/internal/pkg/storage/mocks"
 	"github.com/sanches1984/msa-auth/pkg/jwt"
+	"github.com/sanches1984/msa-auth/pkg/redis"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type StorageSuite struct {
@@ -35,9 +37,9 @@ func (s *StorageSuite) TestDecodeToken() {
 	token := "token"
 	user := int64(123)
 	session := uuid.NewV4()
-	s.jwt.EXPECT().ParseToken(token).Return(user, session, nil).Times(1)
+	s.jwt.EXPECT().ParseToken(token, jwt.TokenTypeRefresh).Return(user, session, nil).Times(1)
 
-	userID, sessionID, err := New(s.redis, s.jwt).DecodeToken(token)
+	userID, sessionID, err := New(s.redis, s.jwt).DecodeToken(token, jwt.TokenTypeRefresh)
 	s.NoError(err)
 	s.Equal(user, userID)
 	s.Equal(session, sessionID)
@@ -66,12 +68,12 @@ func (s *StorageSuite) TestGetSessionDataByUUID() {
 func (s *StorageSuite) TestCreateSession() {
 	userID := int64(123)
 	userData := []byte("hello")
-	s.jwt.EXPECT().NewAccessToken(userID, gomock.Any()).Return(jwt.Token{Value: "token1", ExpiresAt: 111}, nil).Times(1)
+	s.jwt.EXPECT().NewAccessToken(userID, gomock.Any(), jwt.Grants{}).Return(jwt.Token{Value: "token1", ExpiresAt: 111}, nil).Times(1)
 	s.jwt.EXPECT().NewRefreshToken(userID, gomock.Any()).Return(jwt.Token{Value: "token2", ExpiresAt: 222}, nil).Times(1)
 	s.redis.EXPECT().Set("token1", userData).Return(nil).Times(1)
 	s.redis.EXPECT().Set(gomock.Any(), []byte("token1")).Return(nil).Times(1)
 
-	session, err := New(s.redis, s.jwt).CreateSession(userID, userData)
+	session, err := New(s.redis, s.jwt).CreateSession(userID, jwt.Grants{}, userData)
 	s.NoError(err)
 	s.Equal(userID, session.UserID)
 	s.Equal("token1", session.Access.Value)
@@ -82,7 +84,8 @@ func (s *StorageSuite) TestRefreshSession() {
 	userID := int64(123)
 	sessionID := uuid.NewV4()
 	userData := []byte("hello")
-	s.jwt.EXPECT().NewAccessToken(userID, sessionID).Return(jwt.Token{Value: "token1", ExpiresAt: 111}, nil).Times(1)
+	grants := jwt.Grants{Roles: []string{"admin"}, Permissions: []string{"users:write"}}
+	s.jwt.EXPECT().NewAccessToken(userID, sessionID, grants).Return(jwt.Token{Value: "token1", ExpiresAt: 111}, nil).Times(1)
 	s.jwt.EXPECT().NewRefreshToken(userID, sessionID).Return(jwt.Token{Value: "token2", ExpiresAt: 222}, nil).Times(1)
 
 	s.redis.EXPECT().Get(sessionID.String()).Return([]byte("old_token"), nil).Times(1)
@@ -92,24 +95,32 @@ func (s *StorageSuite) TestRefreshSession() {
 	s.redis.EXPECT().Set("token1", userData).Return(nil).Times(1)
 	s.redis.EXPECT().Set(sessionID.String(), []byte("token1")).Return(nil).Times(1)
 
-	session, err := New(s.redis, s.jwt).RefreshSession(userID, sessionID, userData)
-	s.NoError(err)
+	storage := New(s.redis, s.jwt)
+	session, err := storage.IssueSession(userID, sessionID, grants)
+	s.Require().NoError(err)
 	s.Equal(userID, session.UserID)
 	s.Equal(sessionID, session.ID)
 	s.Equal("token1", session.Access.Value)
 	s.Equal("token2", session.Refresh.Value)
+	s.NoError(storage.RefreshSession(session, userData))
 }
 
 func (s *StorageSuite) TestUpdateSessionData() {
-	s.redis.EXPECT().Set("token", []byte("hello")).Return(nil).Times(1)
+	s.redis.EXPECT().TTL("token").Return(time.Hour, nil).Times(1)
+	s.redis.EXPECT().Replace("token", []byte("hello"), time.Hour).Return(nil).Times(1)
 	err := New(s.redis, s.jwt).UpdateSessionData("token", []byte("hello"))
 	s.NoError(err)
+
+	// deleted session isn't recreated
+	s.redis.EXPECT().TTL("deleted").Return(time.Duration(0), redis.ErrRecordNotFound).Times(1)
+	err = New(s.redis, s.jwt).UpdateSessionData("deleted", []byte("hello"))
+	s.ErrorIs(err, redis.ErrRecordNotFound)
 }
 
 func (s *StorageSuite) TestDeleteSession() {
 	token := "token"
 	sessionID := uuid.NewV4()
-	s.jwt.EXPECT().ParseToken(token).Return(int64(123), sessionID, nil).Times(1)
+	s.jwt.EXPECT().ParseToken(token, jwt.TokenTypeAccess).Return(int64(123), sessionID, nil).Times(1)
 	s.redis.EXPECT().Delete(token).Return(nil).Times(1)
 	s.redis.EXPECT().Delete(sessionID.String()).Return(nil).Times(1)
 
@@ -127,3 +138,177 @@ func (s *StorageSuite) TestDeleteSessionByUUID() {
 	err := New(s.redis, s.jwt).DeleteSessionByUUID(sessionID)
 	s.NoError(err)
 }
+
+func (s *StorageSuite) TestHashToken() {
+	st := New(s.redis, s.jwt, WithTokenHashKey([]byte("key")))
+	hash := st.HashToken("token")
+	s.NotEqual("token", hash)
+	s.True(st.MatchToken(hash, "token"))
+	s.False(st.MatchToken(hash, "other"))
+	s.False(New(s.redis, s.jwt, WithTokenHashKey([]byte("other"))).MatchToken(hash, "token"))
+}
+
+func (s *StorageSuite) TestOpaqueSession() {
+	userID := int64(123)
+	userData := []byte("hello")
+	grants := jwt.Grants{Roles: []string{"admin"}, Permissions: []string{"users:write"}}
+	var reference []byte
+	s.jwt.EXPECT().NewRefreshToken(userID, gomock.Any()).Return(jwt.Token{Value: "a.b.c", ExpiresAt: 222}, nil).Times(1)
+	s.redis.EXPECT().SetWithTTL(gomock.Any(), gomock.Any(), time.Hour).DoAndReturn(func(key string, value []byte, ttl time.Duration) error {
+		reference = value
+		return nil
+	}).Times(1)
+	s.redis.EXPECT().Set(gomock.Any(), userData).Return(nil).Times(1)
+	s.redis.EXPECT().Set(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	st := New(s.redis, s.jwt, WithOpaqueAccessTokens(time.Hour))
+	session, err := st.CreateSession(userID, grants, userData)
+	s.Require().NoError(err)
+	s.NotContains(session.Access.Value, ".")
+	s.Equal("a.b.c", session.Refresh.Value)
+
+	key := opaqueTokenPrefix + session.Access.Value
+	s.redis.EXPECT().Get(key).Return(reference, nil).Times(1)
+	decodedUserID, sessionID, err := st.DecodeToken(session.Access.Value, jwt.TokenTypeAccess)
+	s.NoError(err)
+	s.Equal(userID, decodedUserID)
+	s.Equal(session.ID, sessionID)
+
+	_, _, err = st.DecodeToken(session.Access.Value, jwt.TokenTypeRefresh)
+	s.ErrorIs(err, jwt.ErrInvalidType)
+
+	s.redis.EXPECT().Get(key).Return(reference, nil).Times(1)
+	decodedGrants, err := st.DecodeGrants(session.Access.Value)
+	s.NoError(err)
+	s.Equal(grants, decodedGrants)
+
+	s.redis.EXPECT().Get(key).Return(reference, nil).Times(1)
+	s.redis.EXPECT().Delete(session.Access.Value).Return(nil).Times(1)
+	s.redis.EXPECT().Delete(key).Return(nil).Times(1)
+	s.redis.EXPECT().Delete(session.ID.String()).Return(nil).Times(1)
+	s.NoError(st.DeleteSession(session.Access.Value))
+
+	s.redis.EXPECT().Get(key).Return(nil, redis.ErrRecordNotFound).Times(1)
+	_, _, err = st.DecodeToken(session.Access.Value, jwt.TokenTypeAccess)
+	s.ErrorIs(err, jwt.ErrInvalidToken)
+}
+
+func (s *StorageSuite) TestOpaqueModeAcceptsJWT() {
+	token := "a.b.c"
+	sessionID := uuid.NewV4()
+	s.jwt.EXPECT().ParseToken(token, jwt.TokenTypeAccess).Return(int64(123), sessionID, nil).Times(1)
+
+	userID, decodedSessionID, err := New(s.redis, s.jwt, WithOpaqueAccessTokens(time.Hour)).DecodeToken(token, jwt.TokenTypeAccess)
+	s.NoError(err)
+	s.Equal(int64(123), userID)
+	s.Equal(sessionID, decodedSessionID)
+}
+
+func (s *StorageSuite) TestOpaqueMalformedReference() {
+	sessionID := uuid.NewV4()
+	s.redis.EXPECT().Get(opaqueTokenPrefix+"token").Return([]byte("123:"+sessionID.String()), nil).Times(1)
+	st := New(s.redis, s.jwt, WithOpaqueAccessTokens(time.Hour))
+
+	_, _, err := st.DecodeToken("token", jwt.TokenTypeAccess)
+	s.ErrorIs(err, jwt.ErrEmptyToken)
+
+	// token type is required
+	s.redis.EXPECT().Get(opaqueTokenPrefix+"untyped").Return([]byte(`{"user_id":123,"session_id":"`+sessionID.String()+`"}`), nil).Times(1)
+	_, _, err = st.DecodeToken("untyped", jwt.TokenTypeAccess)
+	s.ErrorIs(err, jwt.ErrEmptyToken)
+}
+
+func (s *StorageSuite) TestDecodeGrants() {
+	grants := jwt.Grants{Roles: []string{"admin"}}
+	s.jwt.EXPECT().ParseClaims("access").Return(&jwt.Claims{Type: jwt.TokenTypeAccess, Grants: grants}, nil).Times(1)
+	s.jwt.EXPECT().ParseClaims("refresh").Return(&jwt.Claims{Type: jwt.TokenTypeRefresh}, nil).Times(1)
+	st := New(s.redis, s.jwt)
+
+	decoded, err := st.DecodeGrants("access")
+	s.NoError(err)
+	s.Equal(grants, decoded)
+
+	_, err = st.DecodeGrants("refresh")
+	s.ErrorIs(err, jwt.ErrInvalidType)
+}
+
+func (s *StorageSuite) TestCreateServiceToken() {
+	s.jwt.EXPECT().NewServiceToken(int64(7), time.Minute).Return(jwt.Token{Value: "a.b.c", ExpiresAt: 111}, nil).Times(1)
+
+	token, err := New(s.redis, s.jwt).CreateServiceToken(7, time.Minute)
+	s.NoError(err)
+	s.Equal(Token{Value: "a.b.c", ExpiresIn: 111}, token)
+}
+
+func (s *StorageSuite) TestOpaqueServiceToken() {
+	var reference []byte
+	s.redis.EXPECT().SetWithTTL(gomock.Any(), gomock.Any(), time.Minute).DoAndReturn(func(key string, value []byte, ttl time.Duration) error {
+		reference = value
+		return nil
+	}).Times(1)
+	st := New(s.redis, s.jwt, WithOpaqueAccessTokens(time.Hour))
+
+	token, err := st.CreateServiceToken(7, time.Minute)
+	s.Require().NoError(err)
+	s.NotContains(token.Value, ".")
+
+	key := opaqueTokenPrefix + token.Value
+	s.redis.EXPECT().Get(key).Return(reference, nil).Times(3)
+	accountID, _, err := st.DecodeToken(token.Value, jwt.TokenTypeService)
+	s.NoError(err)
+	s.Equal(int64(7), accountID)
+
+	_, _, err = st.DecodeToken(token.Value, jwt.TokenTypeAccess)
+	s.ErrorIs(err, jwt.ErrInvalidType)
+	_, err = st.DecodeGrants(token.Value)
+	s.ErrorIs(err, jwt.ErrInvalidType)
+}
+
+func (s *StorageSuite) TestMFAChallenge() {
+	challenge := MFAChallenge{UserID: 123, Data: []byte("hello")}
+	var key string
+	var value []byte
+	s.redis.EXPECT().SetWithTTL(gomock.Any(), gomock.Any(), time.Minute).DoAndReturn(func(k string, v []byte, ttl time.Duration) error {
+		key, value = k, v
+		return nil
+	}).Times(1)
+
+	st := New(s.redis, s.jwt, WithChallengeTTL(time.Minute))
+	token, err := st.CreateMFAChallenge(challenge)
+	s.Require().NoError(err)
+	s.Equal(mfaChallengePrefix+token.Value, key)
+
+	s.redis.EXPECT().Get(key).Return(value, nil).Times(1)
+	decoded, err := st.GetMFAChallenge(token.Value)
+	s.NoError(err)
+	s.Equal(&challenge, decoded)
+
+	s.redis.EXPECT().Remove(key).Return(true, nil).Times(1)
+	spent, err := st.SpendMFAChallenge(token.Value)
+	s.NoError(err)
+	s.True(spent)
+}
+
+func (s *StorageSuite) TestWebAuthnChallenge() {
+	challenge := WebAuthnChallenge{UserID: 123, Challenge: []byte("challenge")}
+	var key string
+	var value []byte
+	s.redis.EXPECT().SetWithTTL(gomock.Any(), gomock.Any(), defaultChallengeTTL).DoAndReturn(func(k string, v []byte, ttl time.Duration) error {
+		key, value = k, v
+		return nil
+	}).Times(1)
+
+	st := New(s.redis, s.jwt)
+	token, err := st.CreateWebAuthnChallenge(challenge)
+	s.Require().NoError(err)
+	s.Equal(webAuthnChallengePrefix+token.Value, key)
+
+	s.redis.EXPECT().Get(key).Return(value, nil).Times(1)
+	decoded, err := st.GetWebAuthnChallenge(token.Value)
+	s.NoError(err)
+	s.Equal(&challenge, decoded)
+
+	s.redis.EXPECT().Get(webAuthnChallengePrefix+"unknown").Return(nil, redis.ErrRecordNotFound).Times(1)
+	_, err = st.GetWebAuthnChallenge("unknown")
+	s.ErrorIs(err, redis.ErrRecordNotFound)
+}
@@ -0,0 +1,15 @@
+package throttle
+
+import (
+	"time"
+)
+
+type Redis interface {
+	Get(key string) ([]byte, error)
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	Incr(key string) (int64, error)
+	Expire(key string, ttl time.Duration) error
+	TTL(key string) (time.Duration, error)
+	Keys(pattern string) ([]string, error)
+}
@@ -0,0 +1,244 @@
+package throttle
+
+import (
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	prefixFailures = "throttle:failures:"
+	prefixLock     = "throttle:lock:"
+)
+
+// maxDoublings keeps backoff from overflow when there is no threshold
+const maxDoublings = 20
+
+type Kind string
+
+const (
+	KindLogin   Kind = "login"
+	KindIP      Kind = "ip"
+	KindMFA     Kind = "mfa"
+	KindReset   Kind = "reset"
+	KindResetIP Kind = "reset_ip"
+)
+
+// Key identifies counter of failed attempts.
+type Key struct {
+	Kind  Kind
+	Value string
+}
+
+type Config struct {
+	// LoginThreshold (for login, mfa and reset keys) and IPThreshold (for ip keys) are failures before lockout, 0 turns lockout off
+	LoginThreshold int64
+	IPThreshold    int64
+	// BaseDelay is delay after the first failure, it's doubled on every next one up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Lockout   time.Duration
+	// Window is time after the last failure when failures are forgotten
+	Window time.Duration
+}
+
+// Lockout is state of the key: Locked tells threshold is reached, RetryAfter is remaining delay.
+type Lockout struct {
+	Key
+	Failures   int64
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+// Throttler slows down attempts with exponential backoff and locks keys out after too many failures.
+type Throttler struct {
+	redis  Redis
+	config Config
+}
+
+func LoginKey(login string) Key {
+	return Key{Kind: KindLogin, Value: strings.ToLower(login)}
+}
+
+func IPKey(ip string) Key {
+	return Key{Kind: KindIP, Value: ip}
+}
+
+// MFAKey counts wrong second factor codes of the user.
+func MFAKey(userID int64) Key {
+	return Key{Kind: KindMFA, Value: strconv.FormatInt(userID, 10)}
+}
+
+// ResetKey counts password reset requests of the login, they don't affect login attempts.
+func ResetKey(login string) Key {
+	return Key{Kind: KindReset, Value: strings.ToLower(login)}
+}
+
+// ResetIPKey counts password reset requests from the ip apart from its failed logins.
+func ResetIPKey(ip string) Key {
+	return Key{Kind: KindResetIP, Value: ip}
+}
+
+func New(redis Redis, config Config) *Throttler {
+	return &Throttler{redis: redis, config: config}
+}
+
+// Check returns the longest remaining delay of the keys, 0 if attempt is allowed.
+func (t *Throttler) Check(keys ...Key) (time.Duration, error) {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		ttl, err := t.lockTTL(key)
+		if err != nil {
+			return 0, err
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	return retryAfter, nil
+}
+
+// Fail counts failed attempt and delays next attempts of the keys.
+func (t *Throttler) Fail(keys ...Key) ([]Lockout, error) {
+	lockouts := make([]Lockout, 0, len(keys))
+	for _, key := range keys {
+		failures, err := t.redis.Incr(prefixFailures + key.String())
+		if err != nil {
+			return nil, err
+		}
+		if err := t.redis.Expire(prefixFailures+key.String(), t.config.Window); err != nil {
+			return nil, err
+		}
+
+		lockout := Lockout{Key: key, Failures: failures}
+		lockout.RetryAfter, lockout.Locked = t.delay(key.Kind, failures)
+		if lockout.RetryAfter > 0 {
+			value := []byte(strconv.FormatInt(failures, 10))
+			if err := t.redis.SetWithTTL(prefixLock+key.String(), value, lockout.RetryAfter); err != nil {
+				return nil, err
+			}
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, nil
+}
+
+// Reset forgets failures and removes lockout of the keys.
+func (t *Throttler) Reset(keys ...Key) error {
+	for _, key := range keys {
+		if err := t.redis.Delete(prefixFailures + key.String()); err != nil {
+			return err
+		}
+		if err := t.redis.Delete(prefixLock + key.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lockouts returns state of the keys with failures, all such keys if none is given.
+func (t *Throttler) Lockouts(keys ...Key) ([]Lockout, error) {
+	if len(keys) == 0 {
+		var err error
+		if keys, err = t.keys(); err != nil {
+			return nil, err
+		}
+	}
+
+	lockouts := make([]Lockout, 0, len(keys))
+	for _, key := range keys {
+		lockout, err := t.lockout(key)
+		if err != nil {
+			return nil, err
+		}
+		if lockout.Failures > 0 || lockout.RetryAfter > 0 {
+			lockouts = append(lockouts, lockout)
+		}
+	}
+	return lockouts, nil
+}
+
+func (t *Throttler) lockout(key Key) (Lockout, error) {
+	lockout := Lockout{Key: key}
+	data, err := t.redis.Get(prefixFailures + key.String())
+	if err != nil && err != redis.ErrRecordNotFound {
+		return lockout, err
+	} else if err == nil {
+		if lockout.Failures, err = strconv.ParseInt(string(data), 10, 64); err != nil {
+			return lockout, err
+		}
+	}
+
+	if lockout.RetryAfter, err = t.lockTTL(key); err != nil {
+		return lockout, err
+	}
+	threshold := t.threshold(key.Kind)
+	lockout.Locked = threshold > 0 && lockout.Failures >= threshold && lockout.RetryAfter > 0
+	return lockout, nil
+}
+
+func (t *Throttler) keys() ([]Key, error) {
+	seen := make(map[Key]struct{})
+	var keys []Key
+	for _, prefix := range []string{prefixFailures, prefixLock} {
+		names, err := t.redis.Keys(prefix + "*")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			key, ok := parseKey(strings.TrimPrefix(name, prefix))
+			if _, dup := seen[key]; ok && !dup {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (t *Throttler) lockTTL(key Key) (time.Duration, error) {
+	ttl, err := t.redis.TTL(prefixLock + key.String())
+	if err == redis.ErrRecordNotFound {
+		return 0, nil
+	}
+	return ttl, err
+}
+
+// delay returns lockout period once threshold is reached, exponential backoff before.
+func (t *Throttler) delay(kind Kind, failures int64) (time.Duration, bool) {
+	if threshold := t.threshold(kind); threshold > 0 && failures >= threshold {
+		return t.config.Lockout, true
+	}
+	if t.config.BaseDelay <= 0 || failures <= 0 {
+		return 0, false
+	}
+
+	delay := t.config.BaseDelay
+	for i := int64(1); i < failures && i < maxDoublings; i++ {
+		delay *= 2
+	}
+	if t.config.MaxDelay > 0 && delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+	return delay, false
+}
+
+func (t *Throttler) threshold(kind Kind) int64 {
+	if kind == KindIP || kind == KindResetIP {
+		return t.config.IPThreshold
+	}
+	return t.config.LoginThreshold
+}
+
+func (k Key) String() string {
+	return string(k.Kind) + ":" + k.Value
+}
+
+func parseKey(s string) (Key, bool) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return Key{}, false
+	}
+	return Key{Kind: Kind(parts[0]), Value: parts[1]}, true
+}
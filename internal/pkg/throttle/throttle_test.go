@@ -0,0 +1,106 @@
+package throttle
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/sanches1984/msa-auth/internal/pkg/throttle/mocks"
+	"github.com/sanches1984/msa-auth/pkg/redis"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ThrottleSuite struct {
+	suite.Suite
+
+	ctrl  *gomock.Controller
+	redis *mocks.MockRedis
+}
+
+func (s *ThrottleSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.redis = mocks.NewMockRedis(s.ctrl)
+}
+
+func (s *ThrottleSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func TestThrottle(t *testing.T) {
+	suite.Run(t, new(ThrottleSuite))
+}
+
+func (s *ThrottleSuite) newThrottler() *Throttler {
+	return New(s.redis, Config{
+		LoginThreshold: 5,
+		IPThreshold:    20,
+		BaseDelay:      time.Second,
+		MaxDelay:       4 * time.Second,
+		Lockout:        time.Hour,
+		Window:         time.Hour,
+	})
+}
+
+func (s *ThrottleSuite) TestFail() {
+	cases := []struct {
+		key      Key
+		failures int64
+		delay    time.Duration
+		locked   bool
+	}{
+		{key: LoginKey("User"), failures: 1, delay: time.Second},
+		{key: LoginKey("user"), failures: 2, delay: 2 * time.Second},
+		{key: LoginKey("user"), failures: 4, delay: 4 * time.Second},
+		{key: LoginKey("user"), failures: 5, delay: time.Hour, locked: true},
+		{key: IPKey("::1"), failures: 5, delay: 4 * time.Second},
+		{key: IPKey("::1"), failures: 20, delay: time.Hour, locked: true},
+		{key: ResetIPKey("::1"), failures: 20, delay: time.Hour, locked: true},
+	}
+
+	for n, c := range cases {
+		s.redis.EXPECT().Incr(prefixFailures+c.key.String()).Return(c.failures, nil).Times(1)
+		s.redis.EXPECT().Expire(prefixFailures+c.key.String(), time.Hour).Return(nil).Times(1)
+		s.redis.EXPECT().SetWithTTL(prefixLock+c.key.String(), gomock.Any(), c.delay).Return(nil).Times(1)
+
+		lockouts, err := s.newThrottler().Fail(c.key)
+		s.NoErrorf(err, "case %d", n)
+		s.Equalf([]Lockout{{Key: c.key, Failures: c.failures, RetryAfter: c.delay, Locked: c.locked}}, lockouts, "case %d", n)
+	}
+}
+
+func (s *ThrottleSuite) TestCheck() {
+	s.redis.EXPECT().TTL(prefixLock+"login:user").Return(time.Second, nil).Times(1)
+	s.redis.EXPECT().TTL(prefixLock+"ip:127.0.0.1").Return(time.Minute, nil).Times(1)
+
+	retryAfter, err := s.newThrottler().Check(LoginKey("user"), IPKey("127.0.0.1"))
+	s.NoError(err)
+	s.Equal(time.Minute, retryAfter)
+
+	s.redis.EXPECT().TTL(prefixLock+"login:user").Return(time.Duration(0), redis.ErrRecordNotFound).Times(1)
+
+	retryAfter, err = s.newThrottler().Check(LoginKey("user"))
+	s.NoError(err)
+	s.Zero(retryAfter)
+}
+
+func (s *ThrottleSuite) TestReset() {
+	s.redis.EXPECT().Delete(prefixFailures + "login:user").Return(nil).Times(1)
+	s.redis.EXPECT().Delete(prefixLock + "login:user").Return(nil).Times(1)
+
+	s.NoError(s.newThrottler().Reset(LoginKey("user")))
+}
+
+func (s *ThrottleSuite) TestLockouts() {
+	s.redis.EXPECT().Keys(prefixFailures+"*").Return([]string{prefixFailures + "login:user", prefixFailures + "ip:::1"}, nil).Times(1)
+	s.redis.EXPECT().Keys(prefixLock+"*").Return([]string{prefixLock + "login:user"}, nil).Times(1)
+	s.redis.EXPECT().Get(prefixFailures+"login:user").Return([]byte("5"), nil).Times(1)
+	s.redis.EXPECT().TTL(prefixLock+"login:user").Return(time.Minute, nil).Times(1)
+	s.redis.EXPECT().Get(prefixFailures+"ip:::1").Return([]byte("2"), nil).Times(1)
+	s.redis.EXPECT().TTL(prefixLock+"ip:::1").Return(time.Duration(0), redis.ErrRecordNotFound).Times(1)
+
+	lockouts, err := s.newThrottler().Lockouts()
+	s.NoError(err)
+	s.Equal([]Lockout{
+		{Key: LoginKey("user"), Failures: 5, RetryAfter: time.Minute, Locked: true},
+		{Key: IPKey("::1"), Failures: 2},
+	}, lockouts)
+}
@@ -0,0 +1 @@
+ALTER TABLE "refresh_tokens" ALTER COLUMN "token" TYPE TEXT;
\ No newline at end of file
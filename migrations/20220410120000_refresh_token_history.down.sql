@@ -0,0 +1 @@
+DROP TABLE "refresh_token_history";
\ No newline at end of file
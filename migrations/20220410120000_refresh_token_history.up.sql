@@ -0,0 +1,9 @@
+CREATE TABLE "refresh_token_history"
+(
+    "id"            SERIAL       NOT NULL PRIMARY KEY,
+    "user_id"       BIGINT       NOT NULL,
+    "session_id"    UUID         NOT NULL,
+    "token"         TEXT         NOT NULL,
+    "created"       TIMESTAMPTZ  NOT NULL,
+    "updated"       TIMESTAMPTZ  NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "refresh_token_history" DROP CONSTRAINT "fk_refresh_token_history_users";
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX "index_refresh_token_history_user_session";
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX "index_refresh_token_history_user_session" ON "refresh_token_history" ("user_id", "session_id");
\ No newline at end of file
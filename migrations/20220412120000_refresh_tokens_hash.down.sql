@@ -0,0 +1,8 @@
+-- raw tokens can not be restored, sessions have to login again
+DELETE FROM "refresh_token_history";
+ALTER TABLE "refresh_token_history" ALTER COLUMN "token_hash" TYPE TEXT;
+ALTER TABLE "refresh_token_history" RENAME COLUMN "token_hash" TO "token";
+
+DELETE FROM "refresh_tokens";
+ALTER TABLE "refresh_tokens" ALTER COLUMN "token_hash" TYPE TEXT;
+ALTER TABLE "refresh_tokens" RENAME COLUMN "token_hash" TO "token";
\ No newline at end of file
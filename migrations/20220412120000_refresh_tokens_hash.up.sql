@@ -0,0 +1,7 @@
+ALTER TABLE "refresh_tokens" RENAME COLUMN "token" TO "token_hash";
+UPDATE "refresh_tokens" SET "token_hash" = 'sha256:' || encode(sha256(convert_to("token_hash", 'UTF8')), 'hex');
+ALTER TABLE "refresh_tokens" ALTER COLUMN "token_hash" TYPE VARCHAR(255);
+
+ALTER TABLE "refresh_token_history" RENAME COLUMN "token" TO "token_hash";
+UPDATE "refresh_token_history" SET "token_hash" = 'sha256:' || encode(sha256(convert_to("token_hash", 'UTF8')), 'hex');
+ALTER TABLE "refresh_token_history" ALTER COLUMN "token_hash" TYPE VARCHAR(255);
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE "password_history"
+(
+    "id"            SERIAL        NOT NULL PRIMARY KEY,
+    "user_id"       BIGINT        NOT NULL,
+    "password_hash" VARCHAR(255)  NOT NULL,
+    "created"       TIMESTAMPTZ   NOT NULL,
+    "updated"       TIMESTAMPTZ   NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "password_history" DROP CONSTRAINT "fk_password_history_users";
\ No newline at end of file
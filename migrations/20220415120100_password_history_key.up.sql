@@ -0,0 +1,4 @@
+ALTER TABLE "password_history" ADD CONSTRAINT "fk_password_history_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX "index_password_history_user";
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX "index_password_history_user" ON "password_history" ("user_id");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE "user_totp";
\ No newline at end of file
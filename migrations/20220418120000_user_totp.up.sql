@@ -0,0 +1,10 @@
+CREATE TABLE "user_totp"
+(
+    "id"             SERIAL       NOT NULL PRIMARY KEY,
+    "user_id"        BIGINT       NOT NULL,
+    "secret"         TEXT         NOT NULL,
+    "enabled"        BOOLEAN      NOT NULL DEFAULT FALSE,
+    "last_used_step" BIGINT       NOT NULL DEFAULT 0,
+    "created"        TIMESTAMPTZ  NOT NULL,
+    "updated"        TIMESTAMPTZ  NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "user_totp" DROP CONSTRAINT "fk_user_totp_users";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "user_totp" ADD CONSTRAINT "fk_user_totp_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX "uindex_user_totp_user";
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX "uindex_user_totp_user" ON "user_totp" ("user_id");
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE "recovery_codes"
+(
+    "id"        SERIAL        NOT NULL PRIMARY KEY,
+    "user_id"   BIGINT        NOT NULL,
+    "code_hash" VARCHAR(255)  NOT NULL,
+    "created"   TIMESTAMPTZ   NOT NULL
+);
\ No newline at end of file
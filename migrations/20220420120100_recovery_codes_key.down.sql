@@ -0,0 +1 @@
+ALTER TABLE "recovery_codes" DROP CONSTRAINT "fk_recovery_codes_users";
\ No newline at end of file
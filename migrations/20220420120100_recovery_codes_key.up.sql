@@ -0,0 +1,4 @@
+ALTER TABLE "recovery_codes" ADD CONSTRAINT "fk_recovery_codes_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX "index_recovery_codes_user";
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX "index_recovery_codes_user" ON "recovery_codes" ("user_id");
\ No newline at end of file
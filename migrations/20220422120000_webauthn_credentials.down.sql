@@ -0,0 +1 @@
+DROP TABLE "webauthn_credentials";
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE "webauthn_credentials"
+(
+    "id"            SERIAL         NOT NULL PRIMARY KEY,
+    "user_id"       BIGINT         NOT NULL,
+    "credential_id" VARCHAR(1400)  NOT NULL,
+    "public_key"    BYTEA          NOT NULL,
+    "sign_count"    BIGINT         NOT NULL DEFAULT 0,
+    "name"          VARCHAR(255)   NOT NULL DEFAULT '',
+    "last_used"     TIMESTAMPTZ    NULL,
+    "created"       TIMESTAMPTZ    NOT NULL,
+    "updated"       TIMESTAMPTZ    NOT NULL
+);
\ No newline at end of file
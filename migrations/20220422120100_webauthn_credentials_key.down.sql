@@ -0,0 +1 @@
+ALTER TABLE "webauthn_credentials" DROP CONSTRAINT "fk_webauthn_credentials_users";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "webauthn_credentials" ADD CONSTRAINT "fk_webauthn_credentials_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX "uindex_webauthn_credentials_credential";
+DROP INDEX "index_webauthn_credentials_user";
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX "uindex_webauthn_credentials_credential" ON "webauthn_credentials" ("credential_id");
+CREATE INDEX "index_webauthn_credentials_user" ON "webauthn_credentials" ("user_id");
\ No newline at end of file
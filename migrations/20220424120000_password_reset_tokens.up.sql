@@ -0,0 +1,8 @@
+CREATE TABLE "password_reset_tokens"
+(
+    "id"          SERIAL         NOT NULL PRIMARY KEY,
+    "user_id"     BIGINT         NOT NULL,
+    "token_hash"  VARCHAR(255)   NOT NULL,
+    "expires_in"  INTEGER        NOT NULL,
+    "created"     TIMESTAMPTZ    NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "password_reset_tokens" DROP CONSTRAINT "fk_password_reset_tokens_users";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "password_reset_tokens" ADD CONSTRAINT "fk_password_reset_tokens_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
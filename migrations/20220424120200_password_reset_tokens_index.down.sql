@@ -0,0 +1,2 @@
+DROP INDEX "uindex_password_reset_tokens_token";
+DROP INDEX "index_password_reset_tokens_user";
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX "uindex_password_reset_tokens_token" ON "password_reset_tokens" ("token_hash");
+CREATE INDEX "index_password_reset_tokens_user" ON "password_reset_tokens" ("user_id");
\ No newline at end of file
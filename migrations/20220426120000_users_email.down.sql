@@ -0,0 +1,4 @@
+ALTER TABLE "users"
+    DROP COLUMN "email",
+    DROP COLUMN "email_verified",
+    DROP COLUMN "email_verified_at";
\ No newline at end of file
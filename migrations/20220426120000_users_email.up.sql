@@ -0,0 +1,4 @@
+ALTER TABLE "users"
+    ADD COLUMN "email"             VARCHAR(255)   NULL,
+    ADD COLUMN "email_verified"    BOOLEAN        NOT NULL DEFAULT FALSE,
+    ADD COLUMN "email_verified_at" TIMESTAMPTZ    NULL;
\ No newline at end of file
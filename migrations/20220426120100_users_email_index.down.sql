@@ -0,0 +1,2 @@
+DROP INDEX "uindex_users_email";
+DROP INDEX "index_users_email";
\ No newline at end of file
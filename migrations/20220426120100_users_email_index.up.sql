@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX "uindex_users_email" ON "users" ("email") WHERE "deleted" is null AND "email_verified";
+CREATE INDEX "index_users_email" ON "users" ("email");
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE "email_verification_tokens"
+(
+    "id"          SERIAL         NOT NULL PRIMARY KEY,
+    "user_id"     BIGINT         NOT NULL,
+    "email"       VARCHAR(255)   NOT NULL,
+    "token_hash"  VARCHAR(255)   NOT NULL,
+    "expires_in"  INTEGER        NOT NULL,
+    "created"     TIMESTAMPTZ    NOT NULL
+);
\ No newline at end of file
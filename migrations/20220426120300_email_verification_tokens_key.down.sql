@@ -0,0 +1 @@
+ALTER TABLE "email_verification_tokens" DROP CONSTRAINT "fk_email_verification_tokens_users";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "email_verification_tokens" ADD CONSTRAINT "fk_email_verification_tokens_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX "uindex_email_verification_tokens_token";
+DROP INDEX "index_email_verification_tokens_user";
\ No newline at end of file
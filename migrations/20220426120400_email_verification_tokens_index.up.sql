@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX "uindex_email_verification_tokens_token" ON "email_verification_tokens" ("token_hash");
+CREATE INDEX "index_email_verification_tokens_user" ON "email_verification_tokens" ("user_id");
\ No newline at end of file
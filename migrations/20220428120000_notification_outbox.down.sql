@@ -0,0 +1 @@
+DROP TABLE "notification_outbox";
\ No newline at end of file
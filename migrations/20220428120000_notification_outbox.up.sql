@@ -0,0 +1,12 @@
+CREATE TABLE "notification_outbox"
+(
+    "id"            SERIAL         NOT NULL PRIMARY KEY,
+    "user_id"       BIGINT         NOT NULL,
+    "kind"          VARCHAR(50)    NOT NULL,
+    "data"          JSONB          NULL,
+    "attempts"      INTEGER        NOT NULL DEFAULT 0,
+    "next_attempt"  TIMESTAMPTZ    NOT NULL,
+    "sent"          TIMESTAMPTZ    NULL,
+    "error"         TEXT           NOT NULL DEFAULT '',
+    "created"       TIMESTAMPTZ    NOT NULL
+);
\ No newline at end of file
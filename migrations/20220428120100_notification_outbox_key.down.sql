@@ -0,0 +1 @@
+ALTER TABLE "notification_outbox" DROP CONSTRAINT "fk_notification_outbox_users";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "notification_outbox" ADD CONSTRAINT "fk_notification_outbox_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX "index_notification_outbox_pending";
+DROP INDEX "index_notification_outbox_user";
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX "index_notification_outbox_pending" ON "notification_outbox" ("next_attempt") WHERE "sent" is null;
+CREATE INDEX "index_notification_outbox_user" ON "notification_outbox" ("user_id");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE "roles";
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE "permissions";
\ No newline at end of file
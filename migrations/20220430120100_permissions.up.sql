@@ -0,0 +1,7 @@
+CREATE TABLE "permissions"
+(
+    "id"          SERIAL        NOT NULL PRIMARY KEY,
+    "name"        VARCHAR(100)  NOT NULL,
+    "description" VARCHAR(255)  NOT NULL DEFAULT '',
+    "created"     TIMESTAMPTZ   NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "role_permissions" DROP CONSTRAINT "fk_role_permissions_roles";
+ALTER TABLE "role_permissions" DROP CONSTRAINT "fk_role_permissions_permissions";
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE "role_permissions" ADD CONSTRAINT "fk_role_permissions_roles"
+    FOREIGN KEY("role_id") REFERENCES "roles"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
+ALTER TABLE "role_permissions" ADD CONSTRAINT "fk_role_permissions_permissions"
+    FOREIGN KEY("permission_id") REFERENCES "permissions"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE "user_roles"
+(
+    "user_id" BIGINT       NOT NULL,
+    "role_id" BIGINT       NOT NULL,
+    "created" TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY ("user_id", "role_id")
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "user_roles" DROP CONSTRAINT "fk_user_roles_users";
+ALTER TABLE "user_roles" DROP CONSTRAINT "fk_user_roles_roles";
\ No newline at end of file
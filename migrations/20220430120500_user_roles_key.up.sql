@@ -0,0 +1,8 @@
+ALTER TABLE "user_roles" ADD CONSTRAINT "fk_user_roles_users"
+    FOREIGN KEY("user_id") REFERENCES "users"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
+ALTER TABLE "user_roles" ADD CONSTRAINT "fk_user_roles_roles"
+    FOREIGN KEY("role_id") REFERENCES "roles"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
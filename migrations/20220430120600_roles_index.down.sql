@@ -0,0 +1,4 @@
+DROP INDEX "uindex_roles_name";
+DROP INDEX "uindex_permissions_name";
+DROP INDEX "index_role_permissions_permission";
+DROP INDEX "index_user_roles_role";
\ No newline at end of file
@@ -0,0 +1,4 @@
+CREATE UNIQUE INDEX "uindex_roles_name" ON "roles" ("name");
+CREATE UNIQUE INDEX "uindex_permissions_name" ON "permissions" ("name");
+CREATE INDEX "index_role_permissions_permission" ON "role_permissions" ("permission_id");
+CREATE INDEX "index_user_roles_role" ON "user_roles" ("role_id");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE "service_accounts";
\ No newline at end of file
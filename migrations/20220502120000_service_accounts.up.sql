@@ -0,0 +1,8 @@
+CREATE TABLE "service_accounts"
+(
+    "id"          SERIAL        NOT NULL PRIMARY KEY,
+    "name"        VARCHAR(100)  NOT NULL,
+    "description" VARCHAR(255)  NOT NULL DEFAULT '',
+    "created"     TIMESTAMPTZ   NOT NULL,
+    "updated"     TIMESTAMPTZ   NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE "api_keys"
+(
+    "id"                 SERIAL        NOT NULL PRIMARY KEY,
+    "service_account_id" BIGINT        NOT NULL,
+    "prefix"             VARCHAR(32)   NOT NULL,
+    "key_hash"           VARCHAR(255)  NOT NULL,
+    "created"            TIMESTAMPTZ   NOT NULL,
+    "last_used"          TIMESTAMPTZ   NULL,
+    "revoked"            TIMESTAMPTZ   NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "api_keys" DROP CONSTRAINT "fk_api_keys_service_accounts";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "api_keys" ADD CONSTRAINT "fk_api_keys_service_accounts"
+    FOREIGN KEY("service_account_id") REFERENCES "service_accounts"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX "uindex_service_accounts_name";
+DROP INDEX "uindex_api_keys_prefix";
+DROP INDEX "index_api_keys_service_account";
\ No newline at end of file
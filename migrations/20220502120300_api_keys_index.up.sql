@@ -0,0 +1,3 @@
+CREATE UNIQUE INDEX "uindex_service_accounts_name" ON "service_accounts" ("name");
+CREATE UNIQUE INDEX "uindex_api_keys_prefix" ON "api_keys" ("prefix");
+CREATE INDEX "index_api_keys_service_account" ON "api_keys" ("service_account_id");
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE "oauth_clients"
+(
+    "id"                 SERIAL         NOT NULL PRIMARY KEY,
+    "client_id"          VARCHAR(64)    NOT NULL,
+    "name"               VARCHAR(100)   NOT NULL,
+    "secret_hash"        VARCHAR(255)   NOT NULL,
+    "grant_types"        VARCHAR(32)[]  NOT NULL,
+    "service_account_id" BIGINT         NULL,
+    "created"            TIMESTAMPTZ    NOT NULL,
+    "updated"            TIMESTAMPTZ    NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "oauth_clients" DROP CONSTRAINT "fk_oauth_clients_service_accounts";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "oauth_clients" ADD CONSTRAINT "fk_oauth_clients_service_accounts"
+    FOREIGN KEY("service_account_id") REFERENCES "service_accounts"("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX "uindex_oauth_clients_client_id";
+DROP INDEX "index_oauth_clients_service_account";
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX "uindex_oauth_clients_client_id" ON "oauth_clients" ("client_id");
+CREATE INDEX "index_oauth_clients_service_account" ON "oauth_clients" ("service_account_id");
\ No newline at end of file
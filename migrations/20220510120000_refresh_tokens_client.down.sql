@@ -0,0 +1,2 @@
+ALTER TABLE "refresh_tokens"
+    DROP COLUMN "client_id";
\ No newline at end of file
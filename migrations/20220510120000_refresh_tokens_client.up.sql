@@ -0,0 +1,2 @@
+ALTER TABLE "refresh_tokens"
+    ADD COLUMN "client_id" VARCHAR(64) NOT NULL DEFAULT '';
\ No newline at end of file
@@ -2,6 +2,8 @@ package errors
 
 import (
 	"errors"
+	"strings"
+	"time"
 )
 
 var ErrUserNotFound = errors.New("user not found")
@@ -10,3 +12,55 @@ var ErrIncorrectPassword = errors.New("incorrect password")
 var ErrBadRequest = errors.New("bad request")
 var ErrTokenExpired = errors.New("token has expired")
 var ErrTokenInvalid = errors.New("invalid token")
+var ErrAccessTokenRequired = errors.New("access token required")
+var ErrRefreshTokenRequired = errors.New("refresh token required")
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+var ErrInvalidCredentials = errors.New("invalid login or password")
+var ErrIncorrectCode = errors.New("incorrect code")
+var ErrMFANotConfigured = errors.New("mfa is not configured")
+var ErrMFANotEnabled = errors.New("mfa is not enabled")
+var ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+var ErrWebAuthnNotConfigured = errors.New("webauthn is not configured")
+var ErrCredentialInvalid = errors.New("invalid credential")
+var ErrNotifierNotConfigured = errors.New("notifier is not configured")
+var ErrEmailAlreadyVerified = errors.New("email is already verified")
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+var ErrRoleNotFound = errors.New("role not found")
+var ErrPermissionNotFound = errors.New("permission not found")
+var ErrServiceAccountNotFound = errors.New("service account not found")
+var ErrAPIKeyNotFound = errors.New("api key not found")
+var ErrAPIKeyInvalid = errors.New("invalid api key")
+var ErrOAuthClientNotFound = errors.New("oauth client not found")
+
+// FieldViolation describes why request field is invalid.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError lists all invalid fields of the request.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.Field+": "+v.Description)
+	}
+	return "invalid argument: " + strings.Join(descriptions, "; ")
+}
+
+// RetryAfterError tells when the rejected request may be retried.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
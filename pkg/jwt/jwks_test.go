@@ -0,0 +1,78 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestKeyringJWKS(t *testing.T) {
+	keys := generateTestKeys(t)
+	kr := mustKeyring(t, keys[0])
+	require.NoError(t, kr.Retire(NewHMACKey("secret", []byte("secret")), time.Now().Add(time.Hour)))
+	require.NoError(t, kr.Retire(keys[1], time.Now().Add(time.Hour)))
+	require.NoError(t, kr.Retire(keys[2], time.Now().Add(time.Hour)))
+
+	set := kr.JWKS()
+	require.Len(t, set.Keys, 3)
+
+	byID := make(map[string]JWK, len(set.Keys))
+	for _, jwk := range set.Keys {
+		byID[jwk.Kid] = jwk
+	}
+
+	rsaKey := keys[0].Public().(*rsa.PublicKey)
+	require.Equal(t, JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Kid: "RS256",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		E:   "AQAB",
+	}, byID["RS256"])
+
+	ecKey := keys[1].Public().(*ecdsa.PublicKey)
+	x, err := base64.RawURLEncoding.DecodeString(byID["ES256"].X)
+	require.NoError(t, err)
+	require.Len(t, x, 32)
+	require.Equal(t, "EC", byID["ES256"].Kty)
+	require.Equal(t, elliptic.P256().Params().Name, byID["ES256"].Crv)
+	require.Equal(t, 0, ecKey.X.Cmp(new(big.Int).SetBytes(x)))
+
+	edKey := keys[2].Public().(ed25519.PublicKey)
+	require.Equal(t, "OKP", byID["EdDSA"].Kty)
+	require.Equal(t, "Ed25519", byID["EdDSA"].Crv)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(edKey), byID["EdDSA"].X)
+}
+
+func TestServiceAccessKeys(t *testing.T) {
+	keys := generateTestKeys(t)
+	jwt := NewService(time.Hour, time.Hour, mustKeyring(t, NewHMACKey("secret", []byte("secret"))),
+		WithAccessKeys(mustKeyring(t, keys[1])))
+
+	access, err := jwt.NewAccessToken(123, [16]byte{1}, Grants{})
+	require.NoError(t, err)
+	refresh, err := jwt.NewRefreshToken(123, [16]byte{1})
+	require.NoError(t, err)
+
+	_, _, err = jwt.ParseToken(access.Value, TokenTypeAccess)
+	require.NoError(t, err)
+	_, _, err = jwt.ParseToken(refresh.Value, TokenTypeRefresh)
+	require.NoError(t, err)
+
+	// access tokens are verifiable by published keys only
+	verifier := NewService(time.Hour, time.Hour, mustKeyring(t, keys[0]), WithAccessKeys(mustKeyring(t, keys[1])))
+	_, _, err = verifier.ParseToken(access.Value, TokenTypeAccess)
+	require.NoError(t, err)
+	_, _, err = verifier.ParseToken(refresh.Value, TokenTypeRefresh)
+	require.Error(t, err)
+
+	require.Len(t, jwt.JWKS().Keys, 1)
+	require.Equal(t, "ES256", jwt.JWKS().Keys[0].Kid)
+}
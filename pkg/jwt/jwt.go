@@ -10,88 +10,160 @@ import (
 
 var ErrInvalidToken = errors.New("invalid token")
 var ErrEmptyToken = errors.New("token or claims is null")
+var ErrInvalidIssuer = errors.New("invalid token issuer")
+var ErrInvalidAudience = errors.New("invalid token audience")
+var ErrInvalidType = errors.New("invalid token type")
+var ErrTokenExpired = errors.New("token is expired")
+
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
+	// TokenTypeService is access token of service account, its sub is id of the service account
+	TokenTypeService TokenType = "service"
+)
 
-type userJwt struct {
-	UserID    int64     `json:"u"`
-	SessionID uuid.UUID `json:"s"`
+// Claims of issued tokens: sub is user id, sid is session id, typ tells access tokens from refresh tokens.
+// Access tokens also carry grants of the user at the moment of issue.
+type Claims struct {
+	SessionID string    `json:"sid"`
+	Type      TokenType `json:"typ"`
+	Grants
 	jwt.RegisteredClaims
 }
 
+// Grants are names of roles of the user and permissions of these roles.
+type Grants struct {
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
 type Token struct {
 	Value     string
 	ExpiresAt int32
 }
 
 type Service struct {
-	secret     []byte
+	keys       *Keyring
+	accessKeys *Keyring
 	accessTTL  time.Duration
 	refreshTTL time.Duration
+	issuer     string
+	audience   []string
+}
+
+type Option func(s *Service)
+
+// WithAccessKeys signs access tokens by separate keyring, e.g. asymmetric keys published via JWKS.
+func WithAccessKeys(keys *Keyring) Option {
+	return func(s *Service) {
+		s.accessKeys = keys
+	}
 }
 
-func NewService(accessTTL, refreshTTL time.Duration, secret string) *Service {
-	return &Service{
-		secret:     []byte(secret),
+// WithIssuer sets iss claim of issued tokens and requires it in parsed tokens.
+func WithIssuer(issuer string) Option {
+	return func(s *Service) {
+		s.issuer = issuer
+	}
+}
+
+// WithAudience sets aud claim of issued tokens, parsed tokens must have one of audiences.
+func WithAudience(audience ...string) Option {
+	return func(s *Service) {
+		s.audience = audience
+	}
+}
+
+func NewService(accessTTL, refreshTTL time.Duration, keys *Keyring, opts ...Option) *Service {
+	s := &Service{
+		keys:       keys,
+		accessKeys: keys,
 		accessTTL:  accessTTL,
 		refreshTTL: refreshTTL,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *Service) NewAccessToken(userID int64, sessionID uuid.UUID) (Token, error) {
-	return s.newToken(userID, sessionID, s.accessTTL)
+func (s *Service) NewAccessToken(userID int64, sessionID uuid.UUID, grants Grants) (Token, error) {
+	return s.newToken(s.accessKeys, TokenTypeAccess, userID, sessionID, grants, s.accessTTL)
 }
 
 func (s *Service) NewRefreshToken(userID int64, sessionID uuid.UUID) (Token, error) {
-	return s.newToken(userID, sessionID, s.refreshTTL)
+	return s.newToken(s.keys, TokenTypeRefresh, userID, sessionID, Grants{}, s.refreshTTL)
 }
 
-func (s *Service) ParseToken(token string) (int64, uuid.UUID, error) {
-	jwtToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("can't decode jwt token")
-		}
+// NewServiceToken issues access token of service account, which has no session, sid only tells tokens apart.
+func (s *Service) NewServiceToken(accountID int64, ttl time.Duration) (Token, error) {
+	return s.newToken(s.accessKeys, TokenTypeService, accountID, uuid.NewV4(), Grants{}, ttl)
+}
+
+// JWKS returns public keys verifying access tokens.
+func (s *Service) JWKS() JWKS {
+	return s.accessKeys.JWKS()
+}
 
-		return s.secret, nil
-	})
+// ParseToken parses token of the given type, tokens of other types are rejected with ErrInvalidType.
+func (s *Service) ParseToken(token string, typ TokenType) (int64, uuid.UUID, error) {
+	claims, err := s.ParseClaims(token)
 	if err != nil {
 		return 0, uuid.Nil, err
+	} else if claims.Type != typ {
+		return 0, uuid.Nil, ErrInvalidType
 	}
 
-	if jwtToken == nil || jwtToken.Claims == nil {
-		return 0, uuid.Nil, ErrEmptyToken
-	} else if !jwtToken.Valid {
-		return 0, uuid.Nil, ErrInvalidToken
-	} else if err := jwtToken.Claims.Valid(); err != nil {
-		return 0, uuid.Nil, ErrInvalidToken
-	}
-
-	mc, ok := jwtToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, uuid.Nil, ErrEmptyToken
-	}
+	return claims.IDs()
+}
 
-	userID, err := strconv.ParseInt(mc["jti"].(string), 10, 64)
-	if err != nil {
-		return 0, uuid.Nil, ErrEmptyToken
+// ParseClaims verifies token signature, lifetime, issuer, audience and type.
+func (s *Service) ParseClaims(token string) (*Claims, error) {
+	claims := &Claims{}
+	jwtToken, err := jwt.ParseWithClaims(token, claims, s.verifyKey)
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorExpired != 0 {
+		return nil, ErrTokenExpired
+	} else if err != nil {
+		return nil, err
 	}
 
-	sessionID, err := uuid.FromString(mc["sub"].(string))
-	if err != nil {
-		return 0, uuid.Nil, ErrEmptyToken
+	if jwtToken == nil || jwtToken.Claims == nil {
+		return nil, ErrEmptyToken
+	} else if !jwtToken.Valid {
+		return nil, ErrInvalidToken
+	} else if s.issuer != "" && !claims.VerifyIssuer(s.issuer, true) {
+		return nil, ErrInvalidIssuer
+	} else if !s.verifyAudience(claims) {
+		return nil, ErrInvalidAudience
+	} else if claims.Type != TokenTypeAccess && claims.Type != TokenTypeRefresh && claims.Type != TokenTypeService {
+		return nil, ErrInvalidType
 	}
 
-	return userID, sessionID, nil
+	return claims, nil
 }
 
-func (s *Service) newToken(userID int64, sessionID uuid.UUID, ttl time.Duration) (Token, error) {
+func (s *Service) newToken(keys *Keyring, typ TokenType, userID int64, sessionID uuid.UUID, grants Grants, ttl time.Duration) (Token, error) {
 	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		ID:        strconv.FormatInt(userID, 10),
-		Subject:   sessionID.String(),
-		IssuedAt:  &jwt.NumericDate{Time: now},
-		ExpiresAt: &jwt.NumericDate{Time: now.Add(ttl)},
-	}
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := jwtToken.SignedString(s.secret)
+	claims := Claims{
+		SessionID: sessionID.String(),
+		Type:      typ,
+		Grants:    grants,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewV4().String(),
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  s.audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	key := keys.Active()
+	jwtToken := jwt.NewWithClaims(key.Method, claims)
+	jwtToken.Header["kid"] = key.ID
+	token, err := jwtToken.SignedString(key.signKey)
 	if err != nil {
 		return Token{}, err
 	}
@@ -101,3 +173,46 @@ func (s *Service) newToken(userID int64, sessionID uuid.UUID, ttl time.Duration)
 		ExpiresAt: int32(claims.ExpiresAt.Unix()),
 	}, nil
 }
+
+func (s *Service) verifyKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := s.accessKeys.Lookup(id)
+	if !ok {
+		if key, ok = s.keys.Lookup(id); !ok {
+			return nil, ErrKeyNotFound
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("can't decode jwt token")
+	}
+
+	return key.verifyKey, nil
+}
+
+func (s *Service) verifyAudience(claims *Claims) bool {
+	if len(s.audience) == 0 {
+		return true
+	}
+	for _, aud := range s.audience {
+		if claims.VerifyAudience(aud, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// IDs returns user id from sub and session id from sid claims.
+func (c *Claims) IDs() (int64, uuid.UUID, error) {
+	userID, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return 0, uuid.Nil, ErrEmptyToken
+	}
+
+	sessionID, err := uuid.FromString(c.SessionID)
+	if err != nil {
+		return 0, uuid.Nil, ErrEmptyToken
+	}
+
+	return userID, sessionID, nil
+}
@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"github.com/golang-jwt/jwt/v4"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -8,48 +9,213 @@ import (
 )
 
 func TestJWT(t *testing.T) {
-	jwt := NewService(time.Hour, 6*time.Hour, "secret")
+	jwt := newTestService(t, time.Hour, 6*time.Hour)
 
 	user := int64(123)
 	session := uuid.NewV4()
-	token, err := jwt.NewAccessToken(user, session)
+	token, err := jwt.NewAccessToken(user, session, Grants{})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 
-	userID, sessionID, err := jwt.ParseToken(token.Value)
+	userID, sessionID, err := jwt.ParseToken(token.Value, TokenTypeAccess)
 	require.NoError(t, err)
 	require.Equal(t, user, userID)
 	require.Equal(t, session, sessionID)
 }
 
+func TestJWT_Grants(t *testing.T) {
+	jwt := newTestService(t, time.Hour, 6*time.Hour)
+	grants := Grants{Roles: []string{"admin"}, Permissions: []string{"users:read", "users:write"}}
+
+	access, err := jwt.NewAccessToken(123, uuid.NewV4(), grants)
+	require.NoError(t, err)
+	claims, err := jwt.ParseClaims(access.Value)
+	require.NoError(t, err)
+	require.Equal(t, grants, claims.Grants)
+
+	refresh, err := jwt.NewRefreshToken(123, uuid.NewV4())
+	require.NoError(t, err)
+	claims, err = jwt.ParseClaims(refresh.Value)
+	require.NoError(t, err)
+	require.Empty(t, claims.Grants)
+}
+
 func TestJWT_Expired(t *testing.T) {
-	jwt := NewService(time.Second, time.Second, "secret")
+	jwt := newTestService(t, time.Second, time.Second)
 
 	user := int64(123)
 	session := uuid.NewV4()
-	token, err := jwt.NewAccessToken(user, session)
+	token, err := jwt.NewAccessToken(user, session, Grants{})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 
 	time.Sleep(2 * time.Second)
 
-	_, _, err = jwt.ParseToken(token.Value)
-	require.EqualError(t, err, "Token is expired")
+	_, _, err = jwt.ParseToken(token.Value, TokenTypeAccess)
+	require.EqualError(t, err, ErrTokenExpired.Error())
 }
 
 func TestJWTNew(t *testing.T) {
-	jwt := NewService(time.Hour, 6*time.Hour, "secret")
+	jwt := newTestService(t, time.Hour, 6*time.Hour)
 
 	user := int64(123)
 	session := uuid.NewV4()
-	token, err := jwt.NewAccessToken(user, session)
+	token, err := jwt.NewAccessToken(user, session, Grants{})
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 
 	time.Sleep(time.Second)
 
-	tokenNew, err := jwt.NewAccessToken(user, session)
+	tokenNew, err := jwt.NewAccessToken(user, session, Grants{})
 	require.NoError(t, err)
 	require.NotEmpty(t, tokenNew)
 	require.NotEqual(t, token.Value, tokenNew.Value)
 }
+
+func TestJWT_Asymmetric(t *testing.T) {
+	for _, key := range generateTestKeys(t) {
+		keys, err := NewKeyring(key)
+		require.NoError(t, err)
+		jwt := NewService(time.Hour, 6*time.Hour, keys)
+
+		user := int64(123)
+		session := uuid.NewV4()
+		token, err := jwt.NewAccessToken(user, session, Grants{})
+		require.NoError(t, err, key.Method.Alg())
+
+		userID, sessionID, err := jwt.ParseToken(token.Value, TokenTypeAccess)
+		require.NoError(t, err, key.Method.Alg())
+		require.Equal(t, user, userID)
+		require.Equal(t, session, sessionID)
+	}
+}
+
+func TestJWT_RetiredKey(t *testing.T) {
+	keys := generateTestKeys(t)
+	issuer := NewService(time.Hour, 6*time.Hour, mustKeyring(t, keys[0]))
+	token, err := issuer.NewAccessToken(123, uuid.NewV4(), Grants{})
+	require.NoError(t, err)
+
+	retired := mustKeyring(t, keys[1])
+	require.NoError(t, retired.Retire(keys[0], time.Now().Add(time.Hour)))
+	_, _, err = NewService(time.Hour, 6*time.Hour, retired).ParseToken(token.Value, TokenTypeAccess)
+	require.NoError(t, err)
+
+	// the retired key is forgotten after its retain period
+	expired := mustKeyring(t, keys[1])
+	require.NoError(t, expired.Retire(keys[0], time.Now().Add(-time.Second)))
+	_, _, err = NewService(time.Hour, 6*time.Hour, expired).ParseToken(token.Value, TokenTypeAccess)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestJWT_UnknownKey(t *testing.T) {
+	keys := generateTestKeys(t)
+	issuer := NewService(time.Hour, time.Hour, mustKeyring(t, keys[0]))
+	token, err := issuer.NewAccessToken(123, uuid.NewV4(), Grants{})
+	require.NoError(t, err)
+
+	_, _, err = newTestService(t, time.Hour, time.Hour).ParseToken(token.Value, TokenTypeAccess)
+	require.Error(t, err)
+}
+
+func TestJWT_NoKeyID(t *testing.T) {
+	claims := Claims{
+		SessionID: uuid.NewV4().String(),
+		Type:      TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "123",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	// signed by the active key, but without kid
+	_, _, err = newTestService(t, time.Hour, time.Hour).ParseToken(token, TokenTypeAccess)
+	require.Error(t, err)
+}
+
+func newTestService(t *testing.T, accessTTL, refreshTTL time.Duration) *Service {
+	return NewService(accessTTL, refreshTTL, mustKeyring(t, NewHMACKey("secret", []byte("secret"))))
+}
+
+func mustKeyring(t *testing.T, active *Key) *Keyring {
+	keys, err := NewKeyring(active)
+	require.NoError(t, err)
+	return keys
+}
+
+func TestJWT_Claims(t *testing.T) {
+	jwt := NewService(time.Hour, 6*time.Hour, mustKeyring(t, NewHMACKey("secret", []byte("secret"))),
+		WithIssuer("https://auth.example.com"), WithAudience("api"))
+
+	session := uuid.NewV4()
+	access, err := jwt.NewAccessToken(123, session, Grants{})
+	require.NoError(t, err)
+	refresh, err := jwt.NewRefreshToken(123, session)
+	require.NoError(t, err)
+
+	accessClaims, err := jwt.ParseClaims(access.Value)
+	require.NoError(t, err)
+	require.Equal(t, "123", accessClaims.Subject)
+	require.Equal(t, session.String(), accessClaims.SessionID)
+	require.Equal(t, TokenTypeAccess, accessClaims.Type)
+	require.Equal(t, "https://auth.example.com", accessClaims.Issuer)
+	require.Equal(t, []string{"api"}, []string(accessClaims.Audience))
+	require.NotNil(t, accessClaims.NotBefore)
+	_, err = uuid.FromString(accessClaims.ID)
+	require.NoError(t, err)
+
+	refreshClaims, err := jwt.ParseClaims(refresh.Value)
+	require.NoError(t, err)
+	require.Equal(t, TokenTypeRefresh, refreshClaims.Type)
+	require.NotEqual(t, accessClaims.ID, refreshClaims.ID)
+}
+
+func TestJWT_IssuerAudience(t *testing.T) {
+	keys := mustKeyring(t, NewHMACKey("secret", []byte("secret")))
+	issuer := NewService(time.Hour, time.Hour, keys, WithIssuer("https://auth.example.com"), WithAudience("api"))
+	token, err := issuer.NewAccessToken(123, uuid.NewV4(), Grants{})
+	require.NoError(t, err)
+
+	_, _, err = NewService(time.Hour, time.Hour, keys, WithIssuer("https://other.example.com")).ParseToken(token.Value, TokenTypeAccess)
+	require.EqualError(t, err, ErrInvalidIssuer.Error())
+
+	_, _, err = NewService(time.Hour, time.Hour, keys, WithAudience("other")).ParseToken(token.Value, TokenTypeAccess)
+	require.EqualError(t, err, ErrInvalidAudience.Error())
+
+	_, _, err = NewService(time.Hour, time.Hour, keys, WithAudience("other", "api")).ParseToken(token.Value, TokenTypeAccess)
+	require.NoError(t, err)
+}
+
+func TestJWT_TokenType(t *testing.T) {
+	jwt := newTestService(t, time.Hour, time.Hour)
+	session := uuid.NewV4()
+	access, err := jwt.NewAccessToken(123, session, Grants{})
+	require.NoError(t, err)
+	refresh, err := jwt.NewRefreshToken(123, session)
+	require.NoError(t, err)
+
+	_, _, err = jwt.ParseToken(access.Value, TokenTypeRefresh)
+	require.EqualError(t, err, ErrInvalidType.Error())
+	_, _, err = jwt.ParseToken(refresh.Value, TokenTypeAccess)
+	require.EqualError(t, err, ErrInvalidType.Error())
+
+	_, sessionID, err := jwt.ParseToken(refresh.Value, TokenTypeRefresh)
+	require.NoError(t, err)
+	require.Equal(t, session, sessionID)
+}
+
+func TestJWT_ServiceToken(t *testing.T) {
+	jwt := newTestService(t, time.Hour, time.Hour)
+	token, err := jwt.NewServiceToken(7, time.Minute)
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().Add(time.Minute).Unix(), int64(token.ExpiresAt), 5)
+
+	_, _, err = jwt.ParseToken(token.Value, TokenTypeAccess)
+	require.EqualError(t, err, ErrInvalidType.Error())
+
+	accountID, _, err := jwt.ParseToken(token.Value, TokenTypeService)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), accountID)
+}
@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		private crypto.PrivateKey
+		alg     string
+	}{
+		{private: mustRSAKey(t), alg: "RS256"},
+		{private: mustECDSAKey(t, elliptic.P256()), alg: "ES256"},
+		{private: mustECDSAKey(t, elliptic.P384()), alg: "ES384"},
+		{private: mustEd25519Key(t), alg: "EdDSA"},
+	}
+
+	for n, c := range cases {
+		der, err := x509.MarshalPKCS8PrivateKey(c.private)
+		require.NoError(t, err)
+		path := filepath.Join(dir, c.alg+".pem")
+		require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+		key, err := LoadKeyFile("kid", path)
+		require.NoErrorf(t, err, "case %d", n)
+		require.Equalf(t, c.alg, key.Method.Alg(), "case %d", n)
+		require.Truef(t, key.CanSign(), "case %d", n)
+		require.NotNilf(t, key.Public(), "case %d", n)
+
+		der, err = x509.MarshalPKIXPublicKey(key.Public())
+		require.NoError(t, err)
+		public, err := ParseKeyPEM("kid", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		require.NoErrorf(t, err, "case %d", n)
+		require.Equalf(t, c.alg, public.Method.Alg(), "case %d", n)
+		require.Falsef(t, public.CanSign(), "case %d", n)
+	}
+}
+
+func TestParseKeyPEM_Error(t *testing.T) {
+	_, err := ParseKeyPEM("kid", []byte("not a pem"))
+	require.EqualError(t, err, ErrInvalidKeyPEM.Error())
+
+	_, err = ParseKeyPEM("kid", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("x")}))
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+func TestKeyring(t *testing.T) {
+	keys := generateTestKeys(t)
+	public, err := NewPublicKey("public", keys[0].Public())
+	require.NoError(t, err)
+
+	_, err = NewKeyring(public)
+	require.EqualError(t, err, ErrKeyCantSign.Error())
+
+	kr, err := NewKeyring(keys[0])
+	require.NoError(t, err)
+	require.Equal(t, keys[0], kr.Active())
+	require.EqualError(t, kr.Retire(keys[0], time.Now().Add(time.Hour)), ErrDuplicateKey.Error())
+
+	require.NoError(t, kr.Retire(public, time.Now().Add(time.Hour)))
+	require.NoError(t, kr.Retire(keys[1], time.Now().Add(-time.Second)))
+	_, ok := kr.Lookup(keys[1].ID)
+	require.False(t, ok)
+	key, ok := kr.Lookup("public")
+	require.True(t, ok)
+	require.Equal(t, public, key)
+}
+
+func generateTestKeys(t *testing.T) []*Key {
+	privates := []crypto.PrivateKey{mustRSAKey(t), mustECDSAKey(t, elliptic.P256()), mustEd25519Key(t)}
+	keys := make([]*Key, 0, len(privates))
+	for _, private := range privates {
+		key, err := NewPrivateKey("", private)
+		require.NoError(t, err)
+		key.ID = key.Method.Alg()
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func mustECDSAKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func mustEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
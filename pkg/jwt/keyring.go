@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+var ErrKeyCantSign = errors.New("key can't be used for signing")
+var ErrDuplicateKey = errors.New("duplicate key id")
+
+// Keyring signs tokens with the active key and verifies tokens signed by any known key.
+// Retired keys are kept until tokens signed by them expire.
+type Keyring struct {
+	mu      sync.RWMutex
+	active  *Key
+	retired map[string]retiredKey
+}
+
+type retiredKey struct {
+	key   *Key
+	until time.Time
+}
+
+func NewKeyring(active *Key) (*Keyring, error) {
+	if !active.CanSign() {
+		return nil, ErrKeyCantSign
+	}
+
+	return &Keyring{
+		active:  active,
+		retired: make(map[string]retiredKey),
+	}, nil
+}
+
+func (r *Keyring) Active() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+func (r *Keyring) Lookup(id string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lookup(id)
+}
+
+// Retire adds key verifying tokens signed by it until the given time, after it the key is forgotten.
+func (r *Keyring) Retire(key *Key, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lookup(key.ID); ok {
+		return ErrDuplicateKey
+	}
+
+	now := time.Now()
+	for id, rk := range r.retired {
+		if now.After(rk.until) {
+			delete(r.retired, id)
+		}
+	}
+
+	r.retired[key.ID] = retiredKey{key: key, until: until}
+	return nil
+}
+
+func (r *Keyring) lookup(id string) (*Key, bool) {
+	if r.active.ID == id {
+		return r.active, true
+	}
+
+	rk, ok := r.retired[id]
+	if !ok {
+		return nil, false
+	} else if time.Now().After(rk.until) {
+		return nil, false
+	}
+
+	return rk.key, true
+}
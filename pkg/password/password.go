@@ -0,0 +1,212 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+var ErrInvalidHash = errors.New("invalid password hash")
+var ErrInvalidParams = errors.New("invalid password hash params")
+
+type Algorithm string
+
+const (
+	AlgorithmBcrypt   Algorithm = "bcrypt"
+	AlgorithmArgon2id Algorithm = "argon2id"
+)
+
+// Argon2Params of argon2id, memory is in KiB.
+type Argon2Params struct {
+	Memory     uint32
+	Time       uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2Params follows OWASP recommendation.
+var DefaultArgon2Params = Argon2Params{
+	Memory:     19 * 1024,
+	Time:       2,
+	Threads:    1,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+// Hasher hashes passwords with configured algorithm. Hashes carry algorithm and parameters
+// (bcrypt "$2a$" or PHC "$argon2id$" strings), so hashes of any supported algorithm are verified.
+type Hasher struct {
+	algorithm  Algorithm
+	bcryptCost int
+	argon2     Argon2Params
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+type Option func(h *Hasher)
+
+func WithBcrypt(cost int) Option {
+	return func(h *Hasher) {
+		h.algorithm = AlgorithmBcrypt
+		h.bcryptCost = cost
+	}
+}
+
+func WithArgon2id(params Argon2Params) Option {
+	return func(h *Hasher) {
+		h.algorithm = AlgorithmArgon2id
+		h.argon2 = params
+	}
+}
+
+// New creates hasher, bcrypt with default cost is used by default.
+func New(opts ...Option) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:  AlgorithmBcrypt,
+		bcryptCost: bcrypt.DefaultCost,
+		argon2:     DefaultArgon2Params,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	switch h.algorithm {
+	case AlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%w: bcrypt cost %d", ErrInvalidParams, h.bcryptCost)
+		}
+	case AlgorithmArgon2id:
+		if h.argon2.Memory == 0 || h.argon2.Time == 0 || h.argon2.Threads == 0 || h.argon2.SaltLength == 0 || h.argon2.KeyLength == 0 {
+			return nil, fmt.Errorf("%w: argon2id", ErrInvalidParams)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, h.algorithm)
+	}
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmArgon2id {
+		return h.hashArgon2id(password)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify checks password against hash made by any supported algorithm.
+func (h *Hasher) Verify(hash, password string) bool {
+	switch Identify(hash) {
+	case AlgorithmBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case AlgorithmArgon2id:
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	default:
+		return false
+	}
+}
+
+// VerifyDummy takes as long as Verify of the real hash and always fails.
+// It hides absence of the user from timing attacks.
+func (h *Hasher) VerifyDummy(password string) bool {
+	h.dummyOnce.Do(func() {
+		secret := make([]byte, 16)
+		_, _ = rand.Read(secret)
+		h.dummyHash, _ = h.Hash(base64.RawStdEncoding.EncodeToString(secret))
+	})
+	h.Verify(h.dummyHash, password)
+	return false
+}
+
+// NeedsRehash tells if hash is made by other algorithm or with outdated parameters.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if Identify(hash) != h.algorithm {
+		return true
+	}
+
+	if h.algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.bcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.argon2.Memory ||
+		params.Time != h.argon2.Time ||
+		params.Threads != h.argon2.Threads ||
+		uint32(len(salt)) != h.argon2.SaltLength ||
+		uint32(len(key)) != h.argon2.KeyLength
+}
+
+// Identify returns algorithm of the hash, empty for unknown hashes.
+func Identify(hash string) Algorithm {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return AlgorithmBcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return AlgorithmArgon2id
+	default:
+		return ""
+	}
+}
+
+func (h *Hasher) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Time, h.argon2.Memory, h.argon2.Threads, h.argon2.KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id, argon2.Version, h.argon2.Memory, h.argon2.Time, h.argon2.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// decodeArgon2id parses PHC string $argon2id$v=19$m=65536,t=3,p=2$salt$key.
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != string(AlgorithmArgon2id) {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,77 @@
+package password
+
+import (
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+var testArgon2Params = Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+func TestBcrypt(t *testing.T) {
+	h, err := New(WithBcrypt(bcrypt.MinCost))
+	require.NoError(t, err)
+
+	hash, err := h.Hash("secret")
+	require.NoError(t, err)
+	require.Equal(t, AlgorithmBcrypt, Identify(hash))
+	require.True(t, h.Verify(hash, "secret"))
+	require.False(t, h.Verify(hash, "other"))
+	require.False(t, h.NeedsRehash(hash))
+
+	stronger, err := New(WithBcrypt(bcrypt.MinCost + 1))
+	require.NoError(t, err)
+	require.True(t, stronger.Verify(hash, "secret"))
+	require.True(t, stronger.NeedsRehash(hash))
+}
+
+func TestArgon2id(t *testing.T) {
+	h, err := New(WithArgon2id(testArgon2Params))
+	require.NoError(t, err)
+
+	hash, err := h.Hash("secret")
+	require.NoError(t, err)
+	require.Equal(t, AlgorithmArgon2id, Identify(hash))
+	require.Regexp(t, `^\$argon2id\$v=19\$m=1024,t=1,p=1\$[^$]+\$[^$]+$`, hash)
+	require.True(t, h.Verify(hash, "secret"))
+	require.False(t, h.Verify(hash, "other"))
+	require.False(t, h.NeedsRehash(hash))
+
+	params := testArgon2Params
+	params.Time = 2
+	stronger, err := New(WithArgon2id(params))
+	require.NoError(t, err)
+	require.True(t, stronger.Verify(hash, "secret"))
+	require.True(t, stronger.NeedsRehash(hash))
+}
+
+func TestUpgradeAlgorithm(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	h, err := New(WithArgon2id(testArgon2Params))
+	require.NoError(t, err)
+	require.True(t, h.Verify(string(legacy), "secret"))
+	require.True(t, h.NeedsRehash(string(legacy)))
+}
+
+func TestVerifyDummy(t *testing.T) {
+	h, err := New(WithBcrypt(bcrypt.MinCost))
+	require.NoError(t, err)
+	require.False(t, h.VerifyDummy("secret"))
+	require.Equal(t, AlgorithmBcrypt, Identify(h.dummyHash))
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := New(WithBcrypt(100))
+	require.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = New(WithArgon2id(Argon2Params{}))
+	require.ErrorIs(t, err, ErrInvalidParams)
+
+	h, err := New()
+	require.NoError(t, err)
+	require.False(t, h.Verify("", "secret"))
+	require.False(t, h.Verify("$argon2id$v=19$m=x$salt$key", "secret"))
+	require.True(t, h.NeedsRehash("plain"))
+}
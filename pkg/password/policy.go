@@ -0,0 +1,128 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Rule string
+
+const (
+	RuleMinLength Rule = "min_length"
+	RuleMaxLength Rule = "max_length"
+	RuleLowercase Rule = "lowercase"
+	RuleUppercase Rule = "uppercase"
+	RuleDigit     Rule = "digit"
+	RuleSymbol    Rule = "symbol"
+	RuleNoLogin   Rule = "no_login"
+	RuleNotCommon Rule = "not_common"
+)
+
+// minLoginLength is length of the shortest login checked by RuleNoLogin, shorter logins match too many passwords.
+const minLoginLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordsData []byte
+
+var commonPasswords map[string]struct{}
+var commonPasswordsOnce sync.Once
+
+// Policy of new passwords, zero values turn rules off.
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	ForbidLogin   bool
+	ForbidCommon  bool
+}
+
+type Violation struct {
+	Rule        Rule
+	Description string
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:    8,
+		MaxLength:    64,
+		ForbidLogin:  true,
+		ForbidCommon: true,
+	}
+}
+
+// Validate returns all rules broken by password, lengths are counted in characters.
+func (p Policy) Validate(login, password string) []Violation {
+	var violations []Violation
+	add := func(rule Rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Description: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		add(RuleMinLength, "must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, "must be at most %d characters long", p.MaxLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		add(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		add(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(RuleSymbol, "must contain a symbol")
+	}
+
+	if p.ForbidLogin && utf8.RuneCountInString(login) >= minLoginLength &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(login)) {
+		add(RuleNoLogin, "must not contain login")
+	}
+	if p.ForbidCommon && IsCommon(password) {
+		add(RuleNotCommon, "is too common")
+	}
+
+	return violations
+}
+
+// IsCommon tells if password is in the bundled list of common passwords, case is ignored.
+func IsCommon(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		scanner := bufio.NewScanner(bytes.NewReader(commonPasswordsData))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				commonPasswords[strings.ToLower(line)] = struct{}{}
+			}
+		}
+	})
+
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
@@ -0,0 +1,55 @@
+package password
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func rules(violations []Violation) []Rule {
+	result := make([]Rule, 0, len(violations))
+	for _, v := range violations {
+		result = append(result, v.Rule)
+	}
+	return result
+}
+
+func TestPolicy(t *testing.T) {
+	strict := Policy{
+		MinLength:     8,
+		MaxLength:     16,
+		RequireLower:  true,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		ForbidLogin:   true,
+		ForbidCommon:  true,
+	}
+
+	cases := []struct {
+		policy   Policy
+		login    string
+		password string
+		rules    []Rule
+	}{
+		{policy: strict, login: "user", password: "Correct-Horse1", rules: []Rule{}},
+		{policy: strict, login: "user", password: "Ab1!", rules: []Rule{RuleMinLength}},
+		{policy: strict, login: "user", password: "Correct-Horse1-Battery", rules: []Rule{RuleMaxLength}},
+		{policy: strict, login: "user", password: "correcthorse", rules: []Rule{RuleUppercase, RuleDigit, RuleSymbol}},
+		{policy: strict, login: "user", password: "CORRECT-HORSE1", rules: []Rule{RuleLowercase}},
+		{policy: strict, login: "horse", password: "Correct-Horse1", rules: []Rule{RuleNoLogin}},
+		{policy: strict, login: "ab", password: "Correct-Ab-1", rules: []Rule{}},
+		{policy: DefaultPolicy(), login: "user", password: "Password1", rules: []Rule{RuleNotCommon}},
+		{policy: DefaultPolicy(), login: "user", password: "пароль-из-кириллицы", rules: []Rule{}},
+		{policy: Policy{}, login: "user", password: "1", rules: []Rule{}},
+	}
+
+	for n, c := range cases {
+		require.Equalf(t, c.rules, rules(c.policy.Validate(c.login, c.password)), "case %d", n)
+	}
+}
+
+func TestIsCommon(t *testing.T) {
+	require.True(t, IsCommon("qwerty"))
+	require.True(t, IsCommon("QWERTY"))
+	require.False(t, IsCommon("Correct-Horse1"))
+}
@@ -0,0 +1,33 @@
+package random
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+)
+
+// String returns url safe string encoding size random bytes.
+func String(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// codeAlphabet has no symbols easily confused when typed by hand: 0/o, 1/l/i.
+const codeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// Code returns size random symbols of lowercase alphabet without ambiguous ones.
+func Code(size int) (string, error) {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	code := make([]byte, size)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
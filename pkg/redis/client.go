@@ -44,11 +44,43 @@ func (c *Client) Set(key string, value []byte) error {
 	return err
 }
 
+func (c *Client) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := c.do("SET", key, value, "PX", ttl.Milliseconds())
+	return err
+}
+
+// Replace sets value of existing key only, ttl 0 means no expiration. Missing key is ErrRecordNotFound.
+func (c *Client) Replace(key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		value = []byte{}
+	}
+	args := []interface{}{key, value, "XX"}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	reply, err := c.do("SET", args...)
+	if err != nil {
+		return err
+	} else if reply == nil {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
 func (c *Client) Delete(key string) error {
 	_, err := c.do("DEL", key)
 	return err
 }
 
+// Remove deletes the key and tells if it existed, so only one of concurrent callers gets true.
+func (c *Client) Remove(key string) (bool, error) {
+	count, err := redis.Int64(c.do("DEL", key))
+	return count > 0, err
+}
+
 func (c *Client) Get(key string) ([]byte, error) {
 	data, err := c.do("GET", key)
 	if err != nil {
@@ -60,6 +92,60 @@ func (c *Client) Get(key string) ([]byte, error) {
 	return data.([]byte), nil
 }
 
+func (c *Client) Incr(key string) (int64, error) {
+	return redis.Int64(c.do("INCR", key))
+}
+
+func (c *Client) Expire(key string, ttl time.Duration) error {
+	_, err := c.do("PEXPIRE", key, ttl.Milliseconds())
+	return err
+}
+
+// TTL returns remaining time to live of the key, 0 for keys without expiration.
+func (c *Client) TTL(key string) (time.Duration, error) {
+	ms, err := redis.Int64(c.do("PTTL", key))
+	if err != nil {
+		return 0, err
+	}
+	switch ms {
+	case -2:
+		return 0, ErrRecordNotFound
+	case -1:
+		return 0, nil
+	default:
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+}
+
+// Keys returns all keys matching the pattern, iterating them by SCAN.
+func (c *Client) Keys(pattern string) ([]string, error) {
+	var keys []string
+	cursor := int64(0)
+	for {
+		values, err := redis.Values(c.do("SCAN", cursor, "MATCH", pattern, "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 2 {
+			return nil, fmt.Errorf("unexpected scan reply")
+		}
+
+		cursor, err = redis.Int64(values[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		batch, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
 func (c *Client) Close() error {
 	if c.conn != nil {
 		return (*c.conn).Close()
@@ -35,6 +35,53 @@ func TestRedis(t *testing.T) {
 	require.EqualError(t, err, ErrRecordNotFound.Error())
 	require.Nil(t, data)
 
+	err = client.SetWithTTL("my_key2", []byte("hello"), 100*time.Millisecond)
+	require.NoError(t, err)
+
+	data, err = client.Get("my_key2")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = client.Get("my_key2")
+	require.EqualError(t, err, ErrRecordNotFound.Error())
+
+	err = client.Replace("my_key3", []byte("hello"), 0)
+	require.EqualError(t, err, ErrRecordNotFound.Error())
+	err = client.Set("my_key3", []byte("hello"))
+	require.NoError(t, err)
+	err = client.Replace("my_key3", []byte("bye"), 0)
+	require.NoError(t, err)
+	data, err = client.Get("my_key3")
+	require.NoError(t, err)
+	require.Equal(t, "bye", string(data))
+	require.NoError(t, client.Delete("my_key3"))
+
+	count, err := client.Incr("my_counter")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	err = client.Expire("my_counter", time.Minute)
+	require.NoError(t, err)
+
+	ttl, err := client.TTL("my_counter")
+	require.NoError(t, err)
+	require.True(t, ttl > 0 && ttl <= time.Minute)
+
+	keys, err := client.Keys("my_count*")
+	require.NoError(t, err)
+	require.Equal(t, []string{"my_counter"}, keys)
+
+	removed, err := client.Remove("my_counter")
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = client.Remove("my_counter")
+	require.NoError(t, err)
+	require.False(t, removed)
+
+	_, err = client.TTL("my_counter")
+	require.EqualError(t, err, ErrRecordNotFound.Error())
+
 	err = client.Set("my_key1", nil)
 	require.NoError(t, err)
 
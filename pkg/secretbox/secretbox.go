@@ -0,0 +1,63 @@
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const prefixAESGCM = "aes-gcm:"
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Box encrypts secrets kept at rest (e.g. totp secrets) by AES-256-GCM.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates box, encryption key is SHA-256 of the given key.
+func New(key []byte) (*Box, error) {
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext with random nonce, result is bound to additional data,
+// e.g. owner id, so ciphertext can't be moved to other record.
+func (b *Box) Seal(plaintext, additional []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, plaintext, additional)
+	return prefixAESGCM + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Open(ciphertext string, additional []byte) ([]byte, error) {
+	if !strings.HasPrefix(ciphertext, prefixAESGCM) {
+		return nil, ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(ciphertext, prefixAESGCM))
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, sealed, additional)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
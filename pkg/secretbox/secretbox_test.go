@@ -0,0 +1,34 @@
+package secretbox
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBox(t *testing.T) {
+	box, err := New([]byte("key"))
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte("secret"), []byte("1"))
+	require.NoError(t, err)
+	require.NotContains(t, sealed, "secret")
+
+	other, err := box.Seal([]byte("secret"), []byte("1"))
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, other)
+
+	plaintext, err := box.Open(sealed, []byte("1"))
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(plaintext))
+
+	_, err = box.Open(sealed, []byte("2"))
+	require.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	otherBox, err := New([]byte("other"))
+	require.NoError(t, err)
+	_, err = otherBox.Open(sealed, []byte("1"))
+	require.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = box.Open("plain", nil)
+	require.ErrorIs(t, err, ErrInvalidCiphertext)
+}
@@ -0,0 +1,46 @@
+package tokenhash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	prefixHMAC = "hmac-sha256:"
+	// prefixSHA256 marks hashes converted from raw tokens by migration, they are not keyed
+	prefixSHA256 = "sha256:"
+)
+
+// Hasher hashes high entropy secrets (tokens, codes) to keep them at rest.
+type Hasher struct {
+	key []byte
+}
+
+func New(key []byte) *Hasher {
+	return &Hasher{key: key}
+}
+
+func (h *Hasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return prefixHMAC + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Match compares token with stored hash in constant time.
+func (h *Hasher) Match(hash, token string) bool {
+	var expected string
+	switch {
+	case strings.HasPrefix(hash, prefixHMAC):
+		expected = h.Hash(token)
+	case strings.HasPrefix(hash, prefixSHA256):
+		sum := sha256.Sum256([]byte(token))
+		expected = prefixSHA256 + hex.EncodeToString(sum[:])
+	default:
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+}
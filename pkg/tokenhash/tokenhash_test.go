@@ -0,0 +1,31 @@
+package tokenhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHasher(t *testing.T) {
+	h := New([]byte("key"))
+
+	hash := h.Hash("token")
+	require.Len(t, hash, len(prefixHMAC)+64)
+	require.NotContains(t, hash, "token")
+	require.Equal(t, hash, h.Hash("token"))
+	require.NotEqual(t, hash, New([]byte("other")).Hash("token"))
+
+	require.True(t, h.Match(hash, "token"))
+	require.False(t, h.Match(hash, "other"))
+	require.False(t, New([]byte("other")).Match(hash, "token"))
+	require.False(t, h.Match("token", "token"))
+}
+
+func TestHasher_Legacy(t *testing.T) {
+	sum := sha256.Sum256([]byte("token"))
+	hash := "sha256:" + hex.EncodeToString(sum[:])
+
+	require.True(t, New([]byte("key")).Match(hash, "token"))
+	require.False(t, New([]byte("key")).Match(hash, "other"))
+}
@@ -0,0 +1,212 @@
+// Package virtual is software authenticator with ES256 key for tests of WebAuthn ceremonies.
+package virtual
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const credentialIDSize = 16
+
+var ErrNoCredential = errors.New("authenticator has no credential")
+
+type Authenticator struct {
+	// Origin is origin of client data
+	Origin string
+	// UserVerified sets UV flag
+	UserVerified bool
+	// SignCount is counter of the next assertion, it's incremented before signing
+	SignCount uint32
+
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+}
+
+func New(origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := make([]byte, credentialIDSize)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+	return &Authenticator{Origin: origin, UserVerified: true, key: key, credentialID: credentialID}, nil
+}
+
+func (a *Authenticator) CredentialID() []byte {
+	return a.credentialID
+}
+
+// Register answers creation options like navigator.credentials.create and returns JSON PublicKeyCredential.
+func (a *Authenticator) Register(options []byte) ([]byte, error) {
+	var opts struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(opts.PublicKey.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	a.userHandle = userHandle
+
+	authData := a.authenticatorData(opts.PublicKey.RP.ID, 0x40)
+	// aaguid is zero for none attestation
+	authData = append(authData, make([]byte, 16)...)
+	authData = append(authData, byte(len(a.credentialID)>>8), byte(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, a.coseKey()...)
+
+	attestation := encodeMap(3)
+	attestation = append(attestation, encodeText("fmt")...)
+	attestation = append(attestation, encodeText("none")...)
+	attestation = append(attestation, encodeText("attStmt")...)
+	attestation = append(attestation, encodeMap(0)...)
+	attestation = append(attestation, encodeText("authData")...)
+	attestation = append(attestation, encodeBytes(authData)...)
+
+	return a.credential(map[string]string{
+		"clientDataJSON":    a.clientData("webauthn.create", opts.PublicKey.Challenge),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// Login answers request options like navigator.credentials.get and returns JSON PublicKeyCredential.
+func (a *Authenticator) Login(options []byte) ([]byte, error) {
+	var opts struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RPID      string `json:"rpId"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+	if a.userHandle == nil {
+		return nil, ErrNoCredential
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(opts.PublicKey.RPID, 0)
+	clientData := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+	clientDataJSON, _ := base64.RawURLEncoding.DecodeString(clientData)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return a.credential(map[string]string{
+		"clientDataJSON":    clientData,
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *Authenticator) authenticatorData(rpID string, flags byte) []byte {
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, a.SignCount)
+	return append(append(rpIDHash[:], flags), counter...)
+}
+
+func (a *Authenticator) clientData(typ, challenge string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":        typ,
+		"challenge":   strings.TrimRight(challenge, "="),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (a *Authenticator) credential(response map[string]string) ([]byte, error) {
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	return json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+}
+
+// coseKey encodes public key as COSE_Key: kty EC2, alg ES256, crv P-256, x, y.
+func (a *Authenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	key := encodeMap(5)
+	key = append(key, encodeInt(1)...)
+	key = append(key, encodeInt(2)...)
+	key = append(key, encodeInt(3)...)
+	key = append(key, encodeInt(-7)...)
+	key = append(key, encodeInt(-1)...)
+	key = append(key, encodeInt(1)...)
+	key = append(key, encodeInt(-2)...)
+	key = append(key, encodeBytes(x)...)
+	key = append(key, encodeInt(-3)...)
+	key = append(key, encodeBytes(y)...)
+	return key
+}
+
+func encodeHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		head := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(arg))
+		return head
+	default:
+		head := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(arg))
+		return head
+	}
+}
+
+func encodeInt(v int64) []byte {
+	if v < 0 {
+		return encodeHead(1, uint64(-1-v))
+	}
+	return encodeHead(0, uint64(v))
+}
+
+func encodeBytes(b []byte) []byte {
+	return append(encodeHead(2, uint64(len(b))), b...)
+}
+
+func encodeText(s string) []byte {
+	return append(encodeHead(3, uint64(len(s))), s...)
+}
+
+func encodeMap(size int) []byte {
+	return encodeHead(5, uint64(size))
+}
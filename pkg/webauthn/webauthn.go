@@ -0,0 +1,427 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ChallengeSize is size of random challenge of ceremonies in bytes.
+const ChallengeSize = 32
+
+const maxCredentialIDSize = 1023
+
+const (
+	typeCreate    = "webauthn.create"
+	typeGet       = "webauthn.get"
+	typePublicKey = "public-key"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+var (
+	ErrInvalidConfig     = errors.New("webauthn: rp id and origins are required")
+	ErrInvalidResponse   = errors.New("invalid authenticator response")
+	ErrChallengeMismatch = errors.New("challenge mismatch")
+	ErrOriginMismatch    = errors.New("origin is not allowed")
+	ErrRPIDMismatch      = errors.New("rp id mismatch")
+	ErrUserNotPresent    = errors.New("user is not present")
+	ErrUserNotVerified   = errors.New("user is not verified")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrSignCount         = errors.New("signature counter didn't increase, authenticator may be cloned")
+)
+
+// Config of relying party. Origins are full origins of web clients, e.g. https://example.com.
+type Config struct {
+	RPID                    string
+	RPName                  string
+	Origins                 []string
+	Timeout                 time.Duration
+	RequireUserVerification bool
+}
+
+// WebAuthn builds options of registration and assertion ceremonies for browsers and verifies their results.
+// Attestation is not requested, so attestation statements are not verified.
+type WebAuthn struct {
+	config   Config
+	rpIDHash [sha256.Size]byte
+}
+
+func New(config Config) (*WebAuthn, error) {
+	if config.RPID == "" || len(config.Origins) == 0 {
+		return nil, ErrInvalidConfig
+	}
+	if config.RPName == "" {
+		config.RPName = config.RPID
+	}
+	return &WebAuthn{config: config, rpIDHash: sha256.Sum256([]byte(config.RPID))}, nil
+}
+
+// User is account of credential, ID is opaque user handle.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is registered public key credential, PublicKey is COSE_Key.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// Assertion is parsed result of assertion ceremony, it's verified by WebAuthn.VerifyAssertion.
+type Assertion struct {
+	CredentialID      []byte
+	UserHandle        []byte
+	clientDataJSON    []byte
+	authenticatorData []byte
+	signature         []byte
+}
+
+// URLEncoded is binary field encoded by base64url in JSON.
+type URLEncoded []byte
+
+func (u URLEncoded) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(u))
+}
+
+func (u *URLEncoded) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*u = decoded
+	return nil
+}
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          URLEncoded `json:"id"`
+	Name        string     `json:"name"`
+	DisplayName string     `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string     `json:"type"`
+	ID   URLEncoded `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type creationOptions struct {
+	PublicKey struct {
+		Challenge              URLEncoded             `json:"challenge"`
+		RP                     rpEntity               `json:"rp"`
+		User                   userEntity             `json:"user"`
+		PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+		Timeout                int64                  `json:"timeout,omitempty"`
+		ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials,omitempty"`
+		AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+		Attestation            string                 `json:"attestation"`
+	} `json:"publicKey"`
+}
+
+type requestOptions struct {
+	PublicKey struct {
+		Challenge        URLEncoded             `json:"challenge"`
+		Timeout          int64                  `json:"timeout,omitempty"`
+		RPID             string                 `json:"rpId"`
+		AllowCredentials []credentialDescriptor `json:"allowCredentials,omitempty"`
+		UserVerification string                 `json:"userVerification"`
+	} `json:"publicKey"`
+}
+
+type credentialResponse struct {
+	RawID    URLEncoded `json:"rawId"`
+	Type     string     `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncoded `json:"clientDataJSON"`
+		AttestationObject URLEncoded `json:"attestationObject"`
+		AuthenticatorData URLEncoded `json:"authenticatorData"`
+		Signature         URLEncoded `json:"signature"`
+		UserHandle        URLEncoded `json:"userHandle"`
+	} `json:"response"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CreationOptions returns JSON options of navigator.credentials.create, credentials of exclude can't be registered twice.
+func (w *WebAuthn) CreationOptions(user User, challenge []byte, exclude [][]byte) ([]byte, error) {
+	options := creationOptions{}
+	options.PublicKey.Challenge = challenge
+	options.PublicKey.RP = rpEntity{ID: w.config.RPID, Name: w.config.RPName}
+	options.PublicKey.User = userEntity{ID: user.ID, Name: user.Name, DisplayName: user.DisplayName}
+	for _, alg := range SupportedAlgorithms {
+		options.PublicKey.PubKeyCredParams = append(options.PublicKey.PubKeyCredParams, credentialParameter{Type: typePublicKey, Alg: alg})
+	}
+	options.PublicKey.Timeout = w.config.Timeout.Milliseconds()
+	options.PublicKey.ExcludeCredentials = descriptors(exclude)
+	options.PublicKey.AuthenticatorSelection = authenticatorSelection{
+		ResidentKey:      "preferred",
+		UserVerification: w.userVerification(),
+	}
+	options.PublicKey.Attestation = "none"
+	return json.Marshal(options)
+}
+
+// RequestOptions returns JSON options of navigator.credentials.get, empty allow list asks for discoverable credentials.
+func (w *WebAuthn) RequestOptions(challenge []byte, allow [][]byte) ([]byte, error) {
+	options := requestOptions{}
+	options.PublicKey.Challenge = challenge
+	options.PublicKey.Timeout = w.config.Timeout.Milliseconds()
+	options.PublicKey.RPID = w.config.RPID
+	options.PublicKey.AllowCredentials = descriptors(allow)
+	options.PublicKey.UserVerification = w.userVerification()
+	return json.Marshal(options)
+}
+
+// VerifyRegistration checks JSON encoded PublicKeyCredential of registration and returns the new credential.
+func (w *WebAuthn) VerifyRegistration(challenge, response []byte) (*Credential, error) {
+	resp, err := parseCredentialResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.verifyClientData(resp.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, ErrMalformedCBOR
+	}
+	attestation, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	rawData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	data, err := w.verifyAuthenticatorData(rawData)
+	if err != nil {
+		return nil, err
+	} else if data.flags&flagAttested == 0 || !bytes.Equal(data.credentialID, resp.RawID) {
+		return nil, ErrInvalidResponse
+	}
+	if _, err := parsePublicKey(data.publicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        data.credentialID,
+		PublicKey: data.publicKey,
+		SignCount: data.signCount,
+	}, nil
+}
+
+// ParseAssertion parses JSON encoded PublicKeyCredential of assertion to find its credential.
+func ParseAssertion(response []byte) (*Assertion, error) {
+	resp, err := parseCredentialResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Response.AuthenticatorData) == 0 || len(resp.Response.Signature) == 0 {
+		return nil, ErrInvalidResponse
+	}
+
+	return &Assertion{
+		CredentialID:      resp.RawID,
+		UserHandle:        resp.Response.UserHandle,
+		clientDataJSON:    resp.Response.ClientDataJSON,
+		authenticatorData: resp.Response.AuthenticatorData,
+		signature:         resp.Response.Signature,
+	}, nil
+}
+
+// VerifyAssertion checks assertion signed by the credential and returns new signature counter of the credential.
+func (w *WebAuthn) VerifyAssertion(challenge []byte, assertion *Assertion, credential Credential) (uint32, error) {
+	if !bytes.Equal(assertion.CredentialID, credential.ID) {
+		return 0, ErrInvalidResponse
+	}
+	if err := w.verifyClientData(assertion.clientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+	data, err := w.verifyAuthenticatorData(assertion.authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(assertion.clientDataJSON)
+	message := append(append([]byte(nil), assertion.authenticatorData...), clientDataHash[:]...)
+	if !key.verify(message, assertion.signature) {
+		return 0, ErrInvalidSignature
+	}
+
+	// authenticators without counter always return 0
+	if (data.signCount != 0 || credential.SignCount != 0) && data.signCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+	return data.signCount, nil
+}
+
+func (w *WebAuthn) userVerification() string {
+	if w.config.RequireUserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+func (w *WebAuthn) verifyClientData(raw []byte, typ string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ErrInvalidResponse
+	}
+	if data.Type != typ {
+		return ErrInvalidResponse
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	for _, origin := range w.config.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+func (w *WebAuthn) verifyAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	data, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(data.rpIDHash, w.rpIDHash[:]) != 1 {
+		return nil, ErrRPIDMismatch
+	}
+	if data.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+	if w.config.RequireUserVerification && data.flags&flagUserVerified == 0 {
+		return nil, ErrUserNotVerified
+	}
+	return data, nil
+}
+
+func parseCredentialResponse(response []byte) (*credentialResponse, error) {
+	var resp credentialResponse
+	if err := json.Unmarshal(response, &resp); err != nil {
+		return nil, ErrInvalidResponse
+	}
+	if resp.Type != typePublicKey || len(resp.RawID) == 0 || len(resp.RawID) > maxCredentialIDSize || len(resp.Response.ClientDataJSON) == 0 {
+		return nil, ErrInvalidResponse
+	}
+	return &resp, nil
+}
+
+// parseAuthenticatorData parses rp id hash, flags, counter and attested credential data if it's present.
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, ErrInvalidResponse
+	}
+	data := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	rest := raw[37:]
+	if data.flags&flagAttested != 0 {
+		// aaguid and credential id length
+		if len(rest) < 18 {
+			return nil, ErrInvalidResponse
+		}
+		size := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if size == 0 || size > maxCredentialIDSize || len(rest) < size {
+			return nil, ErrInvalidResponse
+		}
+		data.credentialID = rest[:size]
+		rest = rest[size:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		data.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if data.flags&flagExtensions != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return nil, err
+		}
+	}
+	if len(rest) != 0 {
+		return nil, ErrInvalidResponse
+	}
+	return data, nil
+}
+
+func descriptors(ids [][]byte) []credentialDescriptor {
+	list := make([]credentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, credentialDescriptor{Type: typePublicKey, ID: id})
+	}
+	return list
+}
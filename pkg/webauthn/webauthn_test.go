@@ -0,0 +1,164 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"github.com/sanches1984/msa-auth/pkg/webauthn/virtual"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func newTestWebAuthn(t *testing.T) *WebAuthn {
+	w, err := New(Config{RPID: testRPID, Origins: []string{testOrigin}, RequireUserVerification: true})
+	require.NoError(t, err)
+	return w
+}
+
+func register(t *testing.T, w *WebAuthn, authenticator *virtual.Authenticator) *Credential {
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options, err := w.CreationOptions(User{ID: []byte("123"), Name: "login", DisplayName: "login"}, challenge, nil)
+	require.NoError(t, err)
+	response, err := authenticator.Register(options)
+	require.NoError(t, err)
+
+	credential, err := w.VerifyRegistration(challenge, response)
+	require.NoError(t, err)
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	w := newTestWebAuthn(t)
+	authenticator, err := virtual.New(testOrigin)
+	require.NoError(t, err)
+
+	credential := register(t, w, authenticator)
+	require.Equal(t, authenticator.CredentialID(), credential.ID)
+	require.Equal(t, uint32(0), credential.SignCount)
+	_, err = parsePublicKey(credential.PublicKey)
+	require.NoError(t, err)
+}
+
+func TestRegistration_Error(t *testing.T) {
+	w := newTestWebAuthn(t)
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options, err := w.CreationOptions(User{ID: []byte("123"), Name: "login"}, challenge, nil)
+	require.NoError(t, err)
+
+	phishing, err := virtual.New("https://examp1e.com")
+	require.NoError(t, err)
+	response, err := phishing.Register(options)
+	require.NoError(t, err)
+	_, err = w.VerifyRegistration(challenge, response)
+	require.ErrorIs(t, err, ErrOriginMismatch)
+
+	authenticator, err := virtual.New(testOrigin)
+	require.NoError(t, err)
+	response, err = authenticator.Register(options)
+	require.NoError(t, err)
+	other, err := NewChallenge()
+	require.NoError(t, err)
+	_, err = w.VerifyRegistration(other, response)
+	require.ErrorIs(t, err, ErrChallengeMismatch)
+
+	authenticator.UserVerified = false
+	response, err = authenticator.Register(options)
+	require.NoError(t, err)
+	_, err = w.VerifyRegistration(challenge, response)
+	require.ErrorIs(t, err, ErrUserNotVerified)
+
+	_, err = w.VerifyRegistration(challenge, []byte(`{"type":"public-key"}`))
+	require.ErrorIs(t, err, ErrInvalidResponse)
+}
+
+func TestAssertion(t *testing.T) {
+	w := newTestWebAuthn(t)
+	authenticator, err := virtual.New(testOrigin)
+	require.NoError(t, err)
+	credential := register(t, w, authenticator)
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options, err := w.RequestOptions(challenge, [][]byte{credential.ID})
+	require.NoError(t, err)
+	response, err := authenticator.Login(options)
+	require.NoError(t, err)
+
+	assertion, err := ParseAssertion(response)
+	require.NoError(t, err)
+	require.Equal(t, credential.ID, assertion.CredentialID)
+	require.Equal(t, []byte("123"), assertion.UserHandle)
+
+	signCount, err := w.VerifyAssertion(challenge, assertion, *credential)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), signCount)
+
+	// replayed assertion has the same counter
+	credential.SignCount = signCount
+	_, err = w.VerifyAssertion(challenge, assertion, *credential)
+	require.ErrorIs(t, err, ErrSignCount)
+}
+
+func TestAssertion_Error(t *testing.T) {
+	w := newTestWebAuthn(t)
+	authenticator, err := virtual.New(testOrigin)
+	require.NoError(t, err)
+	credential := register(t, w, authenticator)
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options, err := w.RequestOptions(challenge, nil)
+	require.NoError(t, err)
+	response, err := authenticator.Login(options)
+	require.NoError(t, err)
+
+	// credential of another key
+	other, err := virtual.New(testOrigin)
+	require.NoError(t, err)
+	otherCredential := register(t, w, other)
+	assertion, err := ParseAssertion(response)
+	require.NoError(t, err)
+	_, err = w.VerifyAssertion(challenge, assertion, Credential{ID: credential.ID, PublicKey: otherCredential.PublicKey})
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// assertion for another relying party
+	var opts map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(options, &opts))
+	opts["publicKey"]["rpId"] = "evil.com"
+	options, err = json.Marshal(opts)
+	require.NoError(t, err)
+	response, err = authenticator.Login(options)
+	require.NoError(t, err)
+	assertion, err = ParseAssertion(response)
+	require.NoError(t, err)
+	_, err = w.VerifyAssertion(challenge, assertion, *credential)
+	require.ErrorIs(t, err, ErrRPIDMismatch)
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// {1: 2, "a": [-1, h'ff', true]}
+	value, rest, err := decodeCBOR([]byte{0xa2, 0x01, 0x02, 0x61, 'a', 0x83, 0x20, 0x41, 0xff, 0xf5, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00}, rest)
+	require.Equal(t, map[interface{}]interface{}{
+		int64(1): int64(2),
+		"a":      []interface{}{int64(-1), []byte{0xff}, true},
+	}, value)
+
+	for _, data := range [][]byte{
+		{},
+		{0x5f},             // indefinite length
+		{0x43, 0x01},       // short byte string
+		{0x9b, 0xff, 0xff}, // short argument
+		{0xa1, 0x41, 0x01, 0x01},
+		{0xfb, 0, 0, 0, 0, 0, 0, 0, 0}, // float
+	} {
+		_, _, err := decodeCBOR(data)
+		require.ErrorIs(t, err, ErrMalformedCBOR, "%x", data)
+	}
+}
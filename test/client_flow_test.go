@@ -8,20 +8,30 @@ import (
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestClientFlow(t *testing.T) {
+	// manage service requires admin credential, the key is one of AUTH_ADMIN_API_KEYS of the service
+	apiKey := strings.Split(os.Getenv("AUTH_ADMIN_API_KEYS"), ",")[0]
+	if apiKey == "" {
+		t.Skip("AUTH_ADMIN_API_KEYS is not set")
+	}
+
 	conn, err := grpc.Dial("localhost:5000", grpc.WithTransportCredentials(insecure.NewCredentials()))
 	require.NoError(t, err)
 
 	ctx := context.Background()
+	manageCtx := metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
 	authService := auth.NewAuthServiceClient(conn)
 	manageService := auth.NewManageServiceClient(conn)
 
 	// create user
-	user, err := manageService.CreateUser(ctx, &auth.CreateUserRequest{
+	user, err := manageService.CreateUser(manageCtx, &auth.CreateUserRequest{
 		Login:    "user123",
 		Password: "passwd123",
 	})
@@ -29,7 +39,7 @@ func TestClientFlow(t *testing.T) {
 	require.NotEmpty(t, user.UserId)
 
 	// try create the same user
-	_, err = manageService.CreateUser(ctx, &auth.CreateUserRequest{
+	_, err = manageService.CreateUser(manageCtx, &auth.CreateUserRequest{
 		Login:    "user123",
 		Password: "passwd123",
 	})
@@ -41,7 +51,7 @@ func TestClientFlow(t *testing.T) {
 		Password: "passwd111",
 		Data:     []byte("some user data"),
 	})
-	require.EqualError(t, err, "rpc error: code = PermissionDenied desc = incorrect password")
+	require.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid login or password")
 
 	// login user success
 	loginResp, err := authService.Login(ctx, &auth.LoginRequest{
@@ -55,7 +65,7 @@ func TestClientFlow(t *testing.T) {
 
 	// validate token (fail)
 	_, err = authService.ValidateToken(ctx, &auth.ValidateTokenRequest{Token: loginResp.Refresh.Token})
-	require.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid token")
+	require.EqualError(t, err, "rpc error: code = Unauthenticated desc = access token required")
 
 	// validate token (success)
 	validResp, err := authService.ValidateToken(ctx, &auth.ValidateTokenRequest{Token: loginResp.Access.Token})
@@ -63,12 +73,22 @@ func TestClientFlow(t *testing.T) {
 	require.NotEmpty(t, validResp.SessionId)
 	require.NotEmpty(t, validResp.Data)
 
-	// change password
+	// change password (fail)
 	_, err = authService.ChangePassword(ctx, &auth.ChangePasswordRequest{
 		Token:       loginResp.Access.Token,
+		OldPassword: "passwd111",
+		NewPassword: "newpwd123",
+	})
+	require.EqualError(t, err, "rpc error: code = PermissionDenied desc = incorrect password")
+
+	// change password
+	changeResp, err := authService.ChangePassword(ctx, &auth.ChangePasswordRequest{
+		Token:       loginResp.Access.Token,
+		OldPassword: "passwd123",
 		NewPassword: "newpwd123",
 	})
 	require.NoError(t, err)
+	require.Empty(t, changeResp.RevokedSessionId)
 
 	// update user data
 	_, err = authService.UpdateSessionData(ctx, &auth.UpdateSessionDataRequest{
@@ -111,12 +131,12 @@ func TestClientFlow(t *testing.T) {
 	require.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid token")
 
 	// get users list
-	users, err := manageService.GetUsers(ctx, &auth.GetUsersRequest{Login: "user123"})
+	users, err := manageService.GetUsers(manageCtx, &auth.GetUsersRequest{Login: "user123"})
 	require.NoError(t, err)
 	require.Len(t, users.Users, 1)
 	require.Equal(t, users.Users[0].Login, "user123")
 
 	// delete user
-	_, err = manageService.DeleteUser(ctx, &auth.DeleteUserRequest{UserId: user.UserId})
+	_, err = manageService.DeleteUser(manageCtx, &auth.DeleteUserRequest{UserId: user.UserId})
 	require.NoError(t, err)
 }